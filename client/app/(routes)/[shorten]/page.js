@@ -19,12 +19,46 @@ export default function Shorten() {
   }, [pathname]);
 
   const handleRedirect = async () => {
+    const code = pathname.slice(1);
+    // Split links with sticky variants send returning visitors to the
+    // variant they got before
+    const variantKey = "d4u_v_" + code;
     try {
       const res = await POST({
         url: apiUrl + "shortUrl/get",
-        body: { ShortenUrl: pathname.slice(1) },
+        // Links that forward the query string pass it on to the destination
+        body: {
+          ShortenUrl: code,
+          query: window.location.search.slice(1),
+          variant: localStorage.getItem(variantKey) || undefined,
+        },
+        // Targeted links pick a destination by the visitor's device,
+        // language and country
+        forwardVisitor: true,
       });
 
+      if (res.variant) {
+        localStorage.setItem(variantKey, res.variant);
+      }
+      // Links created with an interstitial go through the preview page
+      if (res.interstitial) {
+        window.location.replace(
+          apiUrl + code + "+" + window.location.search
+        );
+        return;
+      }
+      // Links with an app link for this device try the app first and
+      // fall back to the web unless the app took over
+      if (res.app_url) {
+        const fallback = setTimeout(() => {
+          window.location.replace(res.original_url);
+        }, 1500);
+        document.addEventListener("visibilitychange", () => {
+          if (document.hidden) clearTimeout(fallback);
+        });
+        window.location.href = res.app_url;
+        return;
+      }
       if (res.original_url) {
         window.location.replace(res.original_url);
       }
@@ -1,12 +1,32 @@
 "use server";
+import { headers as requestHeaders } from "next/headers";
 import * as Sentry from "@sentry/nextjs";
 
+// Headers describing the visitor, which the API uses to pick targeted
+// destinations
+const VISITOR_HEADERS = [
+  "user-agent",
+  "accept-language",
+  "cf-ipcountry",
+  "x-forwarded-for",
+  "x-real-ip",
+];
+
 export const POST = async ({
   url,
   headers = { "Content-Type": "application/json" },
   body = null,
+  forwardVisitor = false,
 }) => {
   try {
+    if (forwardVisitor) {
+      const incoming = await requestHeaders();
+      headers = { ...headers };
+      for (const name of VISITOR_HEADERS) {
+        const value = incoming.get(name);
+        if (value) headers[name] = value;
+      }
+    }
     const response = await fetch(url, {
       method: "POST",
       headers,
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/dev4dreams/dev4url/internal/config"
+	"github.com/dev4dreams/dev4url/internal/db"
+	"github.com/dev4dreams/dev4url/internal/services/linkcache"
+)
+
+// redisPoolSize is how many connections to the shared cache are kept at most
+const redisPoolSize = 16
+
+// newResolveCache builds the resolve cache, with a shared Redis tier when
+// one is configured
+func newResolveCache(cfg config.CacheConfig, database *db.Database, logger *slog.Logger) (*linkcache.Cache, error) {
+	var shared linkcache.Backend
+	if cfg.RedisURL != "" {
+		redis, err := linkcache.NewRedis(cfg.RedisURL, redisPoolSize)
+		if err != nil {
+			return nil, fmt.Errorf("configuring shared cache: %w", err)
+		}
+		shared = redis
+	}
+
+	logger.Info("Resolve cache ready",
+		slog.Int("size", cfg.Size),
+		slog.Duration("ttl", cfg.TTL),
+		slog.Duration("negative_ttl", cfg.NegativeTTL),
+		slog.Bool("shared", shared != nil))
+	return linkcache.New(
+		linkcache.NewLRU(cfg.Size),
+		shared,
+		database,
+		linkcache.Config{TTL: cfg.TTL, NegativeTTL: cfg.NegativeTTL},
+		logger,
+	), nil
+}
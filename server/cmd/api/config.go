@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dev4dreams/dev4url/internal/config"
+)
+
+// runConfigCommand implements `api config print`. The configuration is
+// printed even when invalid so problems can be inspected in context.
+func runConfigCommand(args []string) {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ExitOnError)
+	flags := config.RegisterFlags(fs)
+	fs.Parse(args[1:])
+
+	cfg, loadErr := config.Load(flags)
+	var validationErr *config.ValidationError
+	if loadErr != nil && !errors.As(loadErr, &validationErr) {
+		fmt.Fprintln(os.Stderr, loadErr)
+		os.Exit(1)
+	}
+
+	out, err := cfg.YAML()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(out)
+
+	if validationErr != nil {
+		fmt.Fprintln(os.Stderr, validationErr)
+		os.Exit(1)
+	}
+}
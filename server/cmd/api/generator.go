@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/dev4dreams/dev4url/internal/config"
+	"github.com/dev4dreams/dev4url/internal/core"
+	"github.com/dev4dreams/dev4url/internal/db"
+	"github.com/dev4dreams/dev4url/internal/services/workerid"
+)
+
+// newCodeGenerator builds the configured short code strategy, adding a check
+// character when enabled. The snowflake strategy may return a worker ID
+// lease the caller must keep and release.
+func newCodeGenerator(ctx context.Context, cfg config.GeneratorConfig, database *db.Database, logger *slog.Logger) (core.CodeGenerator, *workerid.Lease, error) {
+	generator, lease, err := newStrategy(ctx, cfg, database, logger)
+	if err != nil || !cfg.Checksum {
+		return generator, lease, err
+	}
+	return core.WithChecksum(generator), lease, nil
+}
+
+func newStrategy(ctx context.Context, cfg config.GeneratorConfig, database *db.Database, logger *slog.Logger) (core.CodeGenerator, *workerid.Lease, error) {
+	length := cfg.Layout.CodeLength
+
+	switch cfg.Strategy {
+	case core.StrategySnowflake:
+		// Every replica needs its own worker ID or generated codes collide
+		layout := cfg.CodeLayout()
+		workerID, lease, err := workerid.Assign(ctx, cfg, layout.MaxWorkerID(), database, logger)
+		if err != nil {
+			return nil, nil, fmt.Errorf("assigning worker ID: %w", err)
+		}
+		var generator core.CodeGenerator
+		generator, err = core.NewGenerator(workerID, core.WithLayout(layout))
+		if err != nil {
+			return nil, lease, err
+		}
+		if lease != nil {
+			// Stop issuing codes before the ID can pass to another instance
+			generator = workerid.Guard(generator, lease)
+		}
+		logger.Info("Short code generator ready",
+			slog.String("strategy", cfg.Strategy),
+			slog.Int64("worker_id", workerID),
+			slog.String("worker_id_source", cfg.WorkerIDSource),
+			slog.Int("code_length", length),
+			slog.Time("layout_exhausted_at", layout.Exhausted()))
+		return generator, lease, nil
+
+	case core.StrategyRandom:
+		generator, err := core.NewRandomGenerator(length, database)
+		logReady(logger, cfg.Strategy, length)
+		return generator, nil, err
+
+	case core.StrategyFeistel:
+		generator, err := core.NewFeistelGenerator(length, []byte(cfg.Key), database)
+		logReady(logger, cfg.Strategy, length)
+		return generator, nil, err
+
+	case core.StrategyHashids:
+		generator, err := core.NewHashidsGenerator(length, cfg.Key, database)
+		logReady(logger, cfg.Strategy, length)
+		return generator, nil, err
+
+	default:
+		return nil, nil, fmt.Errorf("unknown short code strategy %q", cfg.Strategy)
+	}
+}
+
+func logReady(logger *slog.Logger, strategy string, length int) {
+	logger.Info("Short code generator ready",
+		slog.String("strategy", strategy),
+		slog.Int("code_length", length))
+}
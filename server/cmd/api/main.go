@@ -2,117 +2,367 @@ package main
 
 import (
 	"context"
-
-	"log"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/dev4dreams/dev4url/internal/config"
-	"github.com/dev4dreams/dev4url/internal/core"
 	"github.com/dev4dreams/dev4url/internal/db"
 	"github.com/dev4dreams/dev4url/internal/handlers"
+	"github.com/dev4dreams/dev4url/internal/logging"
+	"github.com/dev4dreams/dev4url/internal/metrics"
 	"github.com/dev4dreams/dev4url/internal/middleware"
+	"github.com/dev4dreams/dev4url/internal/services/clicks"
+	"github.com/dev4dreams/dev4url/internal/services/geoip"
+	"github.com/dev4dreams/dev4url/internal/services/metadata"
+	"github.com/dev4dreams/dev4url/internal/services/rules"
 	"github.com/dev4dreams/dev4url/internal/services/safebrowsing"
+	"github.com/dev4dreams/dev4url/internal/services/targeting"
+	"github.com/dev4dreams/dev4url/internal/tracing"
 	"github.com/dev4dreams/dev4url/internal/utils"
 	"golang.org/x/time/rate"
 )
 
+const usage = `Usage:
+  api [serve] [flags]   start the server (default)
+  api config print      print the resolved configuration with secrets redacted
+
+Run "api serve -h" to list the flags.`
+
 func main() {
+	args := os.Args[1:]
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		serve(args)
+	case "config":
+		runConfigCommand(args)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags := config.RegisterFlags(fs)
+	fs.Parse(args)
+
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.Load(flags)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatal(slog.Default(), "Failed to load config", err)
 	}
 
-	if err = middleware.InitSentry(os.Getenv("SENTRY_DSN")); err != nil {
-		log.Fatalf("Failed to initialize Sentry: %v", err)
-	}
+	// Structured logging, also used by anything calling slog's default logger
+	logger := logging.New(os.Stdout, cfg.Environment, cfg.Log.Level)
+	slog.SetDefault(logger)
 
-	defer middleware.FlushSentry(2 * time.Second)
+	if err = middleware.InitSentry(cfg.Sentry.DSN, cfg.Environment, cfg.Sentry.TraceRate); err != nil {
+		fatal(logger, "Failed to initialize Sentry", err)
+	}
 
-	// Initialize URL shortener
-	generator, err := core.NewGenerator(1)
+	// Initialize OpenTelemetry tracing
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		Endpoint:    cfg.Tracing.OTLPEndpoint,
+		SampleRate:  cfg.Tracing.SampleRate,
+		Environment: cfg.Environment,
+	})
 	if err != nil {
-		log.Fatalf("Failed to create URL generator: %v", err)
+		fatal(logger, "Failed to initialize tracing", err)
 	}
 
-	// Initialize URL validator with default config
+	defer middleware.FlushSentry(2 * time.Second)
+
+	// Initialize URL validator with default config, the configured rules
+	// are swapped in once the database is available
 	validator := utils.NewURLValidator(utils.DefaultConfig())
 
 	// Initialize Safe Browsing service
-	safeBrowsingKey := os.Getenv("GCP_SAFE_BROWSING_API_KEY")
-	safeBrowsingService := safebrowsing.NewSafeBrowsingService(safeBrowsingKey)
-
-	// Initialize URL handler
-	baseURL := os.Getenv("BASE_URL")
+	safeBrowsingService := safebrowsing.NewSafeBrowsingService(cfg.SafeBrowsing.APIKey, logger)
 
 	// Initialize database connection
 	database, err := db.New(&cfg.Database)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		fatal(logger, "Failed to initialize database", err)
 	}
 	defer database.Close()
 
 	// Verify database connection
 	if err := database.VerifyConnection(); err != nil {
-		log.Fatalf("Failed to verify database connection: %v", err)
+		fatal(logger, "Failed to verify database connection", err)
+	}
+
+	// Bring the schema up to date before serving traffic
+	if cfg.Database.AutoMigrate {
+		if err := database.Migrate(context.Background()); err != nil {
+			fatal(logger, "Failed to apply database migrations", err)
+		}
+	}
+
+	// Initialize URL shortener
+	generator, lease, err := newCodeGenerator(context.Background(), cfg.Generator, database, logger)
+	if err != nil {
+		fatal(logger, "Failed to create URL generator", err)
+	}
+	leaseCtx, stopLease := context.WithCancel(context.Background())
+	defer stopLease()
+	var leaseLost <-chan struct{}
+	if lease != nil {
+		go lease.Keep(leaseCtx)
+		leaseLost = lease.Lost()
+	}
+
+	// Load validator rules and keep them fresh; a broken rules file at
+	// startup is fatal, later on the previous rules are kept
+	var blocklist rules.BlocklistStore
+	if cfg.Validator.RulesFromDatabase {
+		blocklist = database
+	}
+	rulesReloader := rules.NewReloader(
+		rules.NewLoader(cfg.Validator.RulesFile, blocklist),
+		validator,
+		cfg.Validator.ReloadInterval,
+		logger,
+	)
+	if err := rulesReloader.Reload(context.Background()); err != nil {
+		fatal(logger, "Failed to load validator rules", err)
+	}
+
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go rulesReloader.Run(reloadCtx, hup)
+
+	// Expose connection pool stats
+	if err := metrics.RegisterDBStats(database.DB); err != nil {
+		fatal(logger, "Failed to register database metrics", err)
+	}
+
+	// Clicks are counted in memory and written in batches
+	clickBuffer := clicks.NewBuffer(database, cfg.Clicks.FlushInterval, cfg.Clicks.MaxCodes, logger)
+	clicksCtx, stopClicks := context.WithCancel(context.Background())
+	defer stopClicks()
+	go clickBuffer.Run(clicksCtx)
+
+	// Redirects read through the cache when it is enabled
+	var resolver handlers.LinkResolver = database
+	var invalidator handlers.CacheInvalidator
+	if cfg.Cache.Size > 0 {
+		cache, err := newResolveCache(cfg.Cache, database, logger)
+		if err != nil {
+			fatal(logger, "Failed to initialize resolve cache", err)
+		}
+		resolver, invalidator = cache, cache
+	}
+
+	// Destination metadata is fetched in the background for link unfurls
+	var metadataService *metadata.Service
+	var refresher handlers.MetadataRefresher
+	if cfg.Metadata.Enabled {
+		fetcher := metadata.NewFetcher(metadata.FetcherConfig{
+			Timeout:   cfg.Metadata.Timeout,
+			MaxBytes:  cfg.Metadata.MaxBytes,
+			UserAgent: cfg.Metadata.UserAgent,
+		})
+		metadataService = metadata.NewService(fetcher, database, cfg.Metadata.Timeout, cfg.Metadata.Concurrency, logger)
+		refresher = metadataService
+	}
+
+	// Targeted links place visitors by a CDN header, then the GeoIP database
+	var countries targeting.CountryLookup
+	if cfg.Targeting.GeoIPDatabase != "" {
+		reader, err := geoip.Open(cfg.Targeting.GeoIPDatabase)
+		if err != nil {
+			fatal(logger, "Failed to open GeoIP database", err)
+		}
+		countries = reader
+	}
+	locator := targeting.NewLocator(cfg.Targeting.CountryHeader, cfg.Targeting.ClientIPHeader, countries)
+
+	// App links may use the allowlisted custom schemes; the association
+	// files let the configured apps open short URLs themselves
+	appValidator := utils.NewAppURLValidator(cfg.DeepLinks.AllowedSchemes)
+	androidApps := make([]handlers.AndroidApp, len(cfg.DeepLinks.AndroidApps))
+	for i, app := range cfg.DeepLinks.AndroidApps {
+		androidApps[i] = handlers.AndroidApp{PackageName: app.PackageName, Fingerprints: app.SHA256CertFingerprints}
+	}
+	appAssociationHandler, err := handlers.NewAppAssociationHandler(cfg.DeepLinks.AppleAppIDs, androidApps)
+	if err != nil {
+		fatal(logger, "Failed to build app association files", err)
 	}
 
 	// Initialize handlers
-	redirectHandler := handlers.NewRedirectHandler(database)
-	createUrlHandler := handlers.NewURLHandler(validator, safeBrowsingService, generator, baseURL, database)
+	redirectHandler := handlers.NewRedirectHandler(database, resolver, clickBuffer, safeBrowsingService, cfg.Generator.CodeFormat(), locator, logger)
+	linksHandler := handlers.NewLinksHandler(database, validator, appValidator, safeBrowsingService, invalidator, refresher, logger)
+	createUrlHandler := handlers.NewURLHandler(validator, appValidator, safeBrowsingService, generator, cfg.BaseURL, database, invalidator, refresher, logger)
+
+	logos, err := loadLogos(cfg.QR.LogoDir)
+	if err != nil {
+		fatal(logger, "Failed to load QR code logos", err)
+	}
+	qrHandler := handlers.NewQRHandler(resolver, cfg.Generator.CodeFormat(), cfg.BaseURL, logos, logger)
+
+	healthHandler, err := handlers.NewHealthHandler(database, safeBrowsingService, cfg.Server.ReadinessTimeout, logger)
+	if err != nil {
+		fatal(logger, "Failed to initialize health checks", err)
+	}
 
 	// Create router/mux
 	mux := http.NewServeMux()
 
 	// Initialize rate limiter
-	// Adjust these values based on your requirements
-	limiter := middleware.NewIPRateLimiter(rate.Limit(3), 5) // 100 requests per second, burst of 10
+	limiter := middleware.NewIPRateLimiter(rate.Limit(cfg.Server.RateLimit), cfg.Server.RateBurst)
+
+	// CORS is applied per route so each route declares its own methods
+	cors := middleware.NewCORS(middleware.CORSConfig{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	})
 
 	// Register routes with middleware
-	mux.Handle("/shortUrl/get", middleware.CORS(
-		limiter.RateLimit(
-			http.HandlerFunc(redirectHandler.HandleRedirect),
-		),
+	mux.Handle("/shortUrl/get", cors.Allow(
+		http.HandlerFunc(redirectHandler.HandleRedirect),
+		http.MethodPost,
+	))
+	mux.Handle("/shortUrl/post", cors.Allow(
+		http.HandlerFunc(createUrlHandler.CreateShortURL),
+		http.MethodPost,
 	))
+	// Browser visits: a redirect, or a preview with "/{code}+"
+	mux.HandleFunc("GET /{code}", redirectHandler.Visit)
+	mux.HandleFunc("GET /{code}/qr", qrHandler.ServeQR)
+	// iOS also looks for the file at the root
+	mux.HandleFunc("GET /.well-known/apple-app-site-association", appAssociationHandler.AppleAppSiteAssociation)
+	mux.HandleFunc("GET /apple-app-site-association", appAssociationHandler.AppleAppSiteAssociation)
+	mux.HandleFunc("GET /.well-known/assetlinks.json", appAssociationHandler.AssetLinks)
 
-	mux.HandleFunc("/shortUrl/post", createUrlHandler.CreateShortURL)
-	handler := middleware.CORS(middleware.SentryHandler(limiter.RateLimit(mux)))
+	// Metrics go on the admin listener when one is configured, so they are
+	// not reachable from the public port. The management API has no
+	// authentication and is only served there.
+	var adminServer *http.Server
+	if cfg.Server.AdminAddress != "" {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("GET /metrics", metrics.Handler())
+		adminMux.HandleFunc("GET /links/{code}", linksHandler.GetLink)
+		adminMux.HandleFunc("GET /links/{code}/stats", linksHandler.GetStats)
+		adminMux.HandleFunc("PATCH /links/{code}", linksHandler.UpdateLink)
+		adminServer = &http.Server{
+			Addr:         cfg.Server.AdminAddress,
+			Handler:      adminMux,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+			IdleTimeout:  cfg.Server.IdleTimeout,
+		}
+	} else {
+		mux.Handle("GET /metrics", metrics.Handler())
+		logger.Info("Management API disabled, it needs server.admin_address")
+	}
+
+	root := newPublicHandler(mux, limiter, healthHandler.Liveness, healthHandler.Readiness, logger)
 
 	// Create server with timeouts
 	server := &http.Server{
-		Addr:         cfg.ServerAddress,
-		Handler:      handler,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:         cfg.Server.Address,
+		Handler:      root,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Starting server on port %s", cfg.ServerAddress)
+		logger.Info("Starting server", slog.String("address", cfg.Server.Address))
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			fatal(logger, "Failed to start server", err)
 		}
 	}()
 
+	if adminServer != nil {
+		go func() {
+			logger.Info("Starting admin server", slog.String("address", cfg.Server.AdminAddress))
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal(logger, "Failed to start admin server", err)
+			}
+		}()
+	}
+
 	// Setup graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Println("Shutting down server...")
+	drainDelay := cfg.Server.ShutdownDrainDelay
+	select {
+	case <-quit:
+	case <-leaseLost:
+		// Another instance may now own our worker ID. The generator already
+		// refuses new codes; stop serving at once rather than drain.
+		logger.Error("Shutting down after losing the worker ID lease")
+		drainDelay = 0
+	}
+
+	// Fail readiness first so traffic drains before connections are refused
+	healthHandler.StartDraining()
+	logger.Info("Draining before shutdown", slog.Duration("delay", drainDelay))
+	time.Sleep(drainDelay)
+
+	logger.Info("Shutting down server")
 
 	// Create shutdown context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	// Attempt graceful shutdown
 	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
+		logger.Error("Server forced to shutdown", slog.Any("error", err))
+	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			logger.Error("Admin server forced to shutdown", slog.Any("error", err))
+		}
+	}
+
+	// Write clicks buffered since the last flush
+	stopClicks()
+	if err := clickBuffer.Flush(ctx); err != nil {
+		logger.Error("Failed to flush click counts on shutdown", slog.Any("error", err))
+	}
+
+	// Let metadata fetches for just created links finish
+	if metadataService != nil {
+		if err := metadataService.Wait(ctx); err != nil {
+			logger.Error("Abandoned metadata fetches on shutdown", slog.Any("error", err))
+		}
+	}
+
+	// Free the worker ID for the next instance instead of waiting out the TTL
+	stopLease()
+	if lease != nil {
+		if err := lease.Release(ctx); err != nil {
+			logger.Error("Failed to release worker ID lease", slog.Any("error", err))
+		}
 	}
 
-	log.Println("Server exited properly")
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("Failed to flush traces", slog.Any("error", err))
+	}
+
+	logger.Info("Server exited properly")
+}
+
+// fatal logs the error and exits, the slog counterpart of log.Fatalf
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, slog.Any("error", err))
+	os.Exit(1)
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxLogoSide bounds logo dimensions, SVG codes embed the logo as is
+const maxLogoSide = 1024
+
+// loadLogos decodes the PNG and JPEG files in dir, keyed by file name
+// without extension
+func loadLogos(dir string) (map[string]image.Image, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	logos := make(map[string]image.Image)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".png" && ext != ".jpg" && ext != ".jpeg") {
+			continue
+		}
+		logo, err := decodeImage(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if b := logo.Bounds(); b.Dx() > maxLogoSide || b.Dy() > maxLogoSide {
+			return nil, fmt.Errorf("logo %s is %dx%d, at most %dx%d is allowed",
+				entry.Name(), b.Dx(), b.Dy(), maxLogoSide, maxLogoSide)
+		}
+		logos[strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))] = logo
+	}
+	return logos, nil
+}
+
+func decodeImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return img, nil
+}
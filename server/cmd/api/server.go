@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/dev4dreams/dev4url/internal/middleware"
+)
+
+// newPublicHandler wraps mux in the middleware every public request goes
+// through and adds the probes in front of it
+func newPublicHandler(mux *http.ServeMux, limiter *middleware.IPRateLimiter, liveness, readiness http.HandlerFunc, logger *slog.Logger) http.Handler {
+	// Tracing is outermost so every layer sees the span; Sentry must wrap
+	// RequestID so the request ID is tagged on the request's hub. Metrics
+	// wrap the rate limiter so rejected requests are counted too.
+	handler := middleware.Tracing(
+		middleware.SentryHandler(
+			middleware.RequestID(logger)(
+				middleware.AccessLog(logger, mux)(
+					middleware.Metrics(mux)(limiter.RateLimit(middleware.TraceRoute(mux))),
+				),
+			),
+		),
+	)
+
+	// Probes bypass rate limiting, tracing and access logs
+	root := http.NewServeMux()
+	root.HandleFunc("GET /healthz", liveness)
+	root.HandleFunc("GET /readyz", readiness)
+	root.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The catch-all pattern set here is no route of mux
+		r.Pattern = ""
+		handler.ServeHTTP(w, r)
+	}))
+	return root
+}
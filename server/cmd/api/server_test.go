@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dev4dreams/dev4url/internal/metrics"
+	"github.com/dev4dreams/dev4url/internal/middleware"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestHandler builds the public handler around a mux with one route,
+// with a limiter allowing a single request
+func newTestHandler(logs *bytes.Buffer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{code}", func(w http.ResponseWriter, r *http.Request) {})
+	probe := func(w http.ResponseWriter, r *http.Request) {}
+	logger := slog.New(slog.NewJSONHandler(logs, nil))
+	return newPublicHandler(mux, middleware.NewIPRateLimiter(0, 1), probe, probe, logger)
+}
+
+func TestPublicHandlerRoutes(t *testing.T) {
+	var logs bytes.Buffer
+	handler := newTestHandler(&logs)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/abc1234", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	var entry struct {
+		Route string `json:"route"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to decode access log: %v", err)
+	}
+	if entry.Route != "GET /{code}" {
+		t.Errorf("Expected the access log to name the matched route, got %q", entry.Route)
+	}
+
+	// The limiter is used up, probes must still answer
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected probes to bypass rate limiting, got %d", rec.Code)
+	}
+}
+
+func TestPublicHandlerMetrics(t *testing.T) {
+	handler := newTestHandler(&bytes.Buffer{})
+
+	served := metrics.HTTPRequests.WithLabelValues("GET /{code}", http.MethodGet, "200")
+	limited := metrics.HTTPRequests.WithLabelValues("GET /{code}", http.MethodGet, "429")
+	servedBefore, limitedBefore := testutil.ToFloat64(served), testutil.ToFloat64(limited)
+
+	for _, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/abc1234", nil))
+		if rec.Code != want {
+			t.Fatalf("Expected %d, got %d", want, rec.Code)
+		}
+	}
+
+	if got := testutil.ToFloat64(served) - servedBefore; got != 1 {
+		t.Errorf("Expected one served request counted under its route, got %v", got)
+	}
+	if got := testutil.ToFloat64(limited) - limitedBefore; got != 1 {
+		t.Errorf("Expected one rate limited request counted under its route, got %v", got)
+	}
+}
@@ -2,81 +2,413 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
-	"strconv"
 	"time"
 
+	"github.com/dev4dreams/dev4url/internal/core"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// Config is the complete service configuration. Values are resolved in
+// order: defaults, YAML file, environment variables, command line flags.
 type Config struct {
-	ServerAddress   string
-	Database        DatabaseConfig
-	SentryDSN       string
-	Environment     string
-	SentryTraceRate float64
+	Environment  string             `yaml:"environment"`
+	BaseURL      string             `yaml:"base_url"` // public origin used to build short URLs
+	Server       ServerConfig       `yaml:"server"`
+	Database     DatabaseConfig     `yaml:"database"`
+	SafeBrowsing SafeBrowsingConfig `yaml:"safe_browsing"`
+	Generator    GeneratorConfig    `yaml:"generator"`
+	Validator    ValidatorConfig    `yaml:"validator"`
+	Cache        CacheConfig        `yaml:"cache"`
+	Clicks       ClicksConfig       `yaml:"clicks"`
+	Metadata     MetadataConfig     `yaml:"metadata"`
+	QR           QRConfig           `yaml:"qr"`
+	Targeting    TargetingConfig    `yaml:"targeting"`
+	DeepLinks    DeepLinksConfig    `yaml:"deep_links"`
+	CORS         CORSConfig         `yaml:"cors"`
+	Log          LogConfig          `yaml:"log"`
+	Sentry       SentryConfig       `yaml:"sentry"`
+	Tracing      TracingConfig      `yaml:"tracing"`
 }
 
+type ServerConfig struct {
+	Address      string        `yaml:"address"`
+	AdminAddress string        `yaml:"admin_address"` // optional listener for /metrics, empty serves it on Address
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// ShutdownDrainDelay is how long /readyz fails before the server stops
+	// accepting connections, giving load balancers time to react
+	ShutdownDrainDelay time.Duration `yaml:"shutdown_drain_delay"`
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout"`
+	ReadinessTimeout   time.Duration `yaml:"readiness_timeout"`
+	RateLimit          float64       `yaml:"rate_limit"` // requests per second per IP
+	RateBurst          int           `yaml:"rate_burst"`
+}
+
+// Database connection modes
+const (
+	// DatabaseModeDirect connects straight to Postgres
+	DatabaseModeDirect = "direct"
+	// DatabaseModePooler connects through a pgbouncer-style transaction
+	// pooler, which rules out server-side prepared statements
+	DatabaseModePooler = "pooler"
+)
+
 type DatabaseConfig struct {
-	URL             string // Full database URL
-	MaxConnections  int
-	MinConnections  int
-	MaxConnLifetime time.Duration
+	URL  string `yaml:"url" secret:"true"` // Full database URL
+	Mode string `yaml:"mode"`              // direct or pooler
+	// SSLMode overrides any sslmode in URL when set
+	SSLMode         string        `yaml:"ssl_mode"`
+	MaxConnections  int           `yaml:"max_connections"`
+	MinConnections  int           `yaml:"min_connections"` // idle connections kept open
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time"`
+	// StatementTimeout bounds every query, zero disables it
+	StatementTimeout time.Duration `yaml:"statement_timeout"`
+	AutoMigrate      bool          `yaml:"auto_migrate"` // apply pending migrations on startup
 }
 
-// getEnvInt helper function to get int values from env with default fallback
-func getEnvInt(key string, defaultVal int) int {
-	if value, exists := os.LookupEnv(key); exists {
-		if intVal, err := strconv.Atoi(value); err == nil {
-			return intVal
-		}
+type SafeBrowsingConfig struct {
+	APIKey string `yaml:"api_key" secret:"true"`
+}
+
+// Worker ID sources for the short code generator
+const (
+	// WorkerIDStatic uses GeneratorConfig.WorkerID as is
+	WorkerIDStatic = "static"
+	// WorkerIDOrdinal takes the ordinal suffix of a StatefulSet pod name
+	WorkerIDOrdinal = "ordinal"
+	// WorkerIDLease leases a free ID from the database
+	WorkerIDLease = "lease"
+)
+
+type GeneratorConfig struct {
+	// Strategy picks how codes are made: snowflake (time ordered, needs a
+	// worker ID), random, feistel (keyed permutation of a database sequence)
+	// or hashids (salted counter). layout.code_length applies to all of them.
+	Strategy string `yaml:"strategy"`
+	// Key keys the feistel permutation or salts hashids; changing it
+	// changes every future code and may collide with existing ones
+	Key string `yaml:"key" secret:"true"`
+	// Checksum appends a check character to every code, one past
+	// layout.code_length, so mistyped codes are rejected without a query
+	Checksum bool `yaml:"checksum"`
+	// WorkerIDSource is static, ordinal or lease; every replica needs a
+	// distinct worker ID or they generate colliding codes
+	WorkerIDSource string `yaml:"worker_id_source"`
+	WorkerID       int    `yaml:"worker_id"`
+	// PodName is parsed by the ordinal source, empty uses the hostname
+	PodName string `yaml:"pod_name"`
+	// LeaseTTL is how long a lease outlives its last heartbeat; heartbeats
+	// are sent every third of it
+	LeaseTTL time.Duration `yaml:"lease_ttl"`
+	// Layout must be identical on every replica
+	Layout LayoutConfig `yaml:"layout"`
+}
+
+// LayoutConfig mirrors core.Layout
+type LayoutConfig struct {
+	Epoch         time.Time     `yaml:"epoch"`
+	Tick          time.Duration `yaml:"tick"` // timestamp resolution
+	TimestampBits int           `yaml:"timestamp_bits"`
+	WorkerBits    int           `yaml:"worker_bits"`
+	SequenceBits  int           `yaml:"sequence_bits"` // IDs per tick per worker is 2^sequence_bits
+	CodeLength    int           `yaml:"code_length"`
+}
+
+// CodeLayout converts the layout settings for core.NewGenerator
+func (g GeneratorConfig) CodeLayout() core.Layout {
+	return core.Layout{
+		Epoch:         g.Layout.Epoch,
+		Tick:          g.Layout.Tick,
+		TimestampBits: g.Layout.TimestampBits,
+		WorkerBits:    g.Layout.WorkerBits,
+		SequenceBits:  g.Layout.SequenceBits,
+		Length:        g.Layout.CodeLength,
+	}
+}
+
+// CodeFormat describes the codes the generator hands out
+func (g GeneratorConfig) CodeFormat() core.CodeFormat {
+	format := core.CodeFormat{Length: g.Layout.CodeLength, Checksum: g.Checksum}
+	if g.Checksum {
+		format.Length++
+	}
+	return format
+}
+
+// CacheConfig tunes the resolve cache in front of the database
+type CacheConfig struct {
+	// Size is how many codes each replica keeps in memory, zero disables
+	// the cache
+	Size int `yaml:"size"`
+	// TTL bounds how stale a replica's copy of an updated or disabled link
+	// can get; the shared cache is invalidated right away
+	TTL         time.Duration `yaml:"ttl"`
+	NegativeTTL time.Duration `yaml:"negative_ttl"` // for unknown codes, zero disables
+	// RedisURL optionally shares the cache between replicas, e.g.
+	// redis://:password@host:6379/0
+	RedisURL string `yaml:"redis_url" secret:"true"`
+}
+
+// ClicksConfig tunes the click count buffer
+type ClicksConfig struct {
+	// FlushInterval is how often buffered clicks are written; clicks from
+	// the last interval are lost if the process is killed
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	// MaxCodes bounds the distinct codes, and variants of split links,
+	// buffered between flushes; clicks on further codes are dropped
+	MaxCodes int `yaml:"max_codes"`
+}
+
+// MetadataConfig tunes the fetching of destination titles and preview
+// images for link unfurls
+type MetadataConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Timeout   time.Duration `yaml:"timeout"`   // per fetch, including redirects
+	MaxBytes  int64         `yaml:"max_bytes"` // of HTML read per page
+	UserAgent string        `yaml:"user_agent"`
+	// Concurrency bounds fetches in flight, links created past it are not
+	// fetched
+	Concurrency int `yaml:"concurrency"`
+}
+
+// QRConfig configures the QR codes served at /{code}/qr
+type QRConfig struct {
+	// LogoDir holds PNG and JPEG logos that can be drawn in the center of
+	// a code, picked by file name without extension: ?logo=events
+	LogoDir string `yaml:"logo_dir"`
+}
+
+// TargetingConfig tells where visitors are, for links with targeting
+// rules
+type TargetingConfig struct {
+	// CountryHeader carries the visitor's country as set by a CDN, such as
+	// CF-IPCountry. Only trust it when the CDN sets it on every request.
+	CountryHeader string `yaml:"country_header"`
+	// GeoIPDatabase is a MaxMind DB file used when the header is missing
+	GeoIPDatabase string `yaml:"geoip_database"`
+	// ClientIPHeader carries the visitor's address for GeoIP lookups,
+	// such as X-Real-IP; empty uses the connection's address
+	ClientIPHeader string `yaml:"client_ip_header"`
+}
+
+// DeepLinksConfig lets links open mobile apps
+type DeepLinksConfig struct {
+	// AllowedSchemes are the custom URL schemes app links may use, such as
+	// myapp; web URLs never need listing
+	AllowedSchemes []string `yaml:"allowed_schemes"`
+	// AppleAppIDs (TEAMID.bundle.id) are served in apple-app-site-association
+	// so the apps open short URLs as universal links
+	AppleAppIDs []string `yaml:"apple_app_ids"`
+	// AndroidApps are served in assetlinks.json for Android App Links
+	AndroidApps []AndroidAppConfig `yaml:"android_apps"`
+}
+
+type AndroidAppConfig struct {
+	PackageName string `yaml:"package_name"`
+	// SHA256CertFingerprints of the signing certificates, as
+	// colon-separated hex pairs
+	SHA256CertFingerprints []string `yaml:"sha256_cert_fingerprints"`
+}
+
+type ValidatorConfig struct {
+	// RulesFile is a YAML/JSON file with blocked domains and patterns,
+	// empty uses the built-in defaults
+	RulesFile string `yaml:"rules_file"`
+	// RulesFromDatabase also loads entries from the blocklist table
+	RulesFromDatabase bool `yaml:"rules_from_database"`
+	// ReloadInterval is how often sources are polled for changes; SIGHUP
+	// forces a reload at any time
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins"` // exact origins or wildcard patterns like https://*.dev4url.cc
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"` // debug, info, warn or error; empty picks by environment
+}
+
+type SentryConfig struct {
+	DSN       string  `yaml:"dsn" secret:"true"`
+	TraceRate float64 `yaml:"trace_rate"`
+}
+
+type TracingConfig struct {
+	OTLPEndpoint string  `yaml:"otlp_endpoint"` // OTLP/HTTP collector URL, empty disables export
+	SampleRate   float64 `yaml:"sample_rate"`
+}
+
+// Default returns the configuration used when nothing overrides it
+func Default() *Config {
+	layout := core.DefaultLayout()
+	return &Config{
+		Environment: "development",
+		Server: ServerConfig{
+			Address:            ":8080",
+			ReadTimeout:        15 * time.Second,
+			WriteTimeout:       15 * time.Second,
+			IdleTimeout:        60 * time.Second,
+			ShutdownDrainDelay: 5 * time.Second,
+			ShutdownTimeout:    30 * time.Second,
+			ReadinessTimeout:   2 * time.Second,
+			RateLimit:          3,
+			RateBurst:          5,
+		},
+		// For transaction pooler, using lower connection pool defaults
+		Database: DatabaseConfig{
+			Mode:             DatabaseModePooler,
+			SSLMode:          "require",
+			MaxConnections:   20,
+			MinConnections:   5,
+			MaxConnLifetime:  30 * time.Minute,
+			MaxConnIdleTime:  5 * time.Minute,
+			StatementTimeout: 5 * time.Second,
+			AutoMigrate:      true,
+		},
+		Generator: GeneratorConfig{
+			Strategy:       core.StrategySnowflake,
+			WorkerIDSource: WorkerIDLease,
+			LeaseTTL:       30 * time.Second,
+			Layout: LayoutConfig{
+				Epoch:         layout.Epoch,
+				Tick:          layout.Tick,
+				TimestampBits: layout.TimestampBits,
+				WorkerBits:    layout.WorkerBits,
+				SequenceBits:  layout.SequenceBits,
+				CodeLength:    layout.Length,
+			},
+		},
+		Validator: ValidatorConfig{
+			ReloadInterval: 30 * time.Second,
+		},
+		Cache: CacheConfig{
+			Size:        10000,
+			TTL:         time.Minute,
+			NegativeTTL: 30 * time.Second,
+		},
+		Clicks: ClicksConfig{
+			FlushInterval: 5 * time.Second,
+			MaxCodes:      100000,
+		},
+		Metadata: MetadataConfig{
+			Enabled:     true,
+			Timeout:     5 * time.Second,
+			MaxBytes:    1 << 20,
+			UserAgent:   "dev4url-unfurl/1.0 (+https://dev4url.cc)",
+			Concurrency: 8,
+		},
+		Targeting: TargetingConfig{
+			CountryHeader: "CF-IPCountry",
+		},
+		CORS: CORSConfig{
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		},
+		Sentry: SentryConfig{
+			TraceRate: 1.0,
+		},
+		Tracing: TracingConfig{
+			SampleRate: 1.0,
+		},
 	}
-	return defaultVal
 }
 
-// getEnvFloat helper function to get float values from env with default fallback
-func getEnvFloat(key string, defaultVal float64) float64 {
-	if value, exists := os.LookupEnv(key); exists {
-		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
-			return floatVal
+// Flags holds the command line flags understood by Load
+type Flags struct {
+	set *flag.FlagSet
+	// ConfigPath is the YAML file to load, falling back to $CONFIG_FILE
+	ConfigPath string
+}
+
+// RegisterFlags adds --config and one flag per overridable setting to fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{set: fs}
+	fs.StringVar(&f.ConfigPath, "config", "", "path to a YAML config file (env CONFIG_FILE)")
+	for _, b := range bindings {
+		if b.flag != "" {
+			fs.String(b.flag, "", b.usage)
 		}
 	}
-	return defaultVal
+	return f
 }
 
-func Load() (*Config, error) {
+// Load resolves the configuration and validates it. Every problem found is
+// reported at once in a *ValidationError; the resolved config is returned
+// alongside it so callers can still print what was loaded.
+func Load(flags *Flags) (*Config, error) {
+	var problems []error
+
 	// Load .env file if present
-	godotenv.Load() // Ignoring error as .env file is optional
-
-	// Database connection pool settings
-	// For transaction pooler, using lower connection pool defaults
-	dbMaxConns := getEnvInt("DB_POOL_MAX_CONNS", 20)
-	dbMinConns := getEnvInt("DB_POOL_MIN_CONNS", 5)
-	dbLifetime := getEnvInt("DB_POOL_MAX_CONN_LIFETIME", 30)
-
-	// Server settings
-	serverPort := os.Getenv("PORT")
-	if serverPort == "" {
-		serverPort = "8080" // default port
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		problems = append(problems, fmt.Errorf(".env: %w", err))
+	}
+
+	cfg := Default()
+
+	path := os.Getenv("CONFIG_FILE")
+	if flags != nil && flags.ConfigPath != "" {
+		path = flags.ConfigPath
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
 	}
 
-	// Sentry settings
-	sentryTraceRate := getEnvFloat("SENTRY_TRACE_RATE", 1.0)
-	environment := os.Getenv("ENVIRONMENT")
-	if environment == "" {
-		environment = "development" // default environment
+	problems = append(problems, cfg.applyEnv()...)
+	if flags != nil {
+		problems = append(problems, cfg.applyFlags(flags.set)...)
 	}
+	problems = append(problems, cfg.validate()...)
 
-	return &Config{
-		ServerAddress: ":" + serverPort,
-		Database: DatabaseConfig{
-			URL:             os.Getenv("SUPABASE_TRANSACTION_POOLER"),
-			MaxConnections:  dbMaxConns,
-			MinConnections:  dbMinConns,
-			MaxConnLifetime: time.Duration(dbLifetime) * time.Minute,
-		},
-		SentryDSN:       os.Getenv("SENTRY_DSN"),
-		Environment:     environment,
-		SentryTraceRate: sentryTraceRate,
-	}, nil
+	if len(problems) > 0 {
+		return cfg, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// loadFile decodes a YAML file over the current values. Unknown keys are
+// rejected so typos don't silently fall back to defaults.
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// ValidationError lists every configuration problem found by Load
+type ValidationError struct {
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	msg := fmt.Sprintf("invalid configuration (%d problems):", len(e.Problems))
+	for _, problem := range e.Problems {
+		msg += "\n  - " + problem.Error()
+	}
+	return msg
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
 }
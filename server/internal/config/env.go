@@ -0,0 +1,262 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// binding maps environment variables and an optional command line flag onto
+// a config field. The first variable in env that is set wins, later names
+// are kept for backwards compatibility.
+type binding struct {
+	env   []string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var bindings = []binding{
+	{env: []string{"ENVIRONMENT", "APP_ENV"}, flag: "env", usage: "deployment environment",
+		set: func(c *Config, v string) error { c.Environment = v; return nil }},
+	{env: []string{"BASE_URL"}, flag: "base-url", usage: "public origin used to build short URLs",
+		set: func(c *Config, v string) error { c.BaseURL = v; return nil }},
+
+	// Server settings
+	{env: []string{"PORT"}, flag: "port", usage: "public listener port",
+		set: func(c *Config, v string) error { c.Server.Address = ":" + v; return nil }},
+	{env: []string{"ADMIN_PORT"}, flag: "admin-port", usage: "admin listener port for /metrics",
+		set: func(c *Config, v string) error { c.Server.AdminAddress = ":" + v; return nil }},
+	{env: []string{"SHUTDOWN_DRAIN_DELAY"},
+		set: func(c *Config, v string) error { return parseDuration(v, time.Second, &c.Server.ShutdownDrainDelay) }},
+	{env: []string{"SHUTDOWN_TIMEOUT"},
+		set: func(c *Config, v string) error { return parseDuration(v, time.Second, &c.Server.ShutdownTimeout) }},
+	{env: []string{"READINESS_TIMEOUT"},
+		set: func(c *Config, v string) error { return parseDuration(v, time.Second, &c.Server.ReadinessTimeout) }},
+	{env: []string{"RATE_LIMIT"},
+		set: func(c *Config, v string) error { return parseFloat(v, &c.Server.RateLimit) }},
+	{env: []string{"RATE_BURST"},
+		set: func(c *Config, v string) error { return parseInt(v, &c.Server.RateBurst) }},
+
+	// Database settings
+	{env: []string{"DATABASE_URL", "SUPABASE_TRANSACTION_POOLER"},
+		set: func(c *Config, v string) error { c.Database.URL = v; return nil }},
+	{env: []string{"DB_MODE"},
+		set: func(c *Config, v string) error { c.Database.Mode = v; return nil }},
+	{env: []string{"DB_SSLMODE"},
+		set: func(c *Config, v string) error { c.Database.SSLMode = v; return nil }},
+	{env: []string{"DB_POOL_MAX_CONNS"},
+		set: func(c *Config, v string) error { return parseInt(v, &c.Database.MaxConnections) }},
+	{env: []string{"DB_POOL_MIN_CONNS"},
+		set: func(c *Config, v string) error { return parseInt(v, &c.Database.MinConnections) }},
+	{env: []string{"DB_POOL_MAX_CONN_LIFETIME"},
+		set: func(c *Config, v string) error { return parseDuration(v, time.Minute, &c.Database.MaxConnLifetime) }},
+	{env: []string{"DB_POOL_MAX_CONN_IDLE_TIME"},
+		set: func(c *Config, v string) error { return parseDuration(v, time.Minute, &c.Database.MaxConnIdleTime) }},
+	{env: []string{"DB_STATEMENT_TIMEOUT"},
+		set: func(c *Config, v string) error { return parseDuration(v, time.Second, &c.Database.StatementTimeout) }},
+	{env: []string{"DB_AUTO_MIGRATE"},
+		set: func(c *Config, v string) error { return parseBool(v, &c.Database.AutoMigrate) }},
+
+	// Safe Browsing settings
+	{env: []string{"GCP_SAFE_BROWSING_API_KEY"},
+		set: func(c *Config, v string) error { c.SafeBrowsing.APIKey = v; return nil }},
+
+	// Generator settings; a bare WORKER_ID implies the static source
+	{env: []string{"CODE_STRATEGY"}, flag: "code-strategy", usage: "short code strategy: snowflake, random, feistel or hashids",
+		set: func(c *Config, v string) error { c.Generator.Strategy = v; return nil }},
+	{env: []string{"CODE_KEY"},
+		set: func(c *Config, v string) error { c.Generator.Key = v; return nil }},
+	{env: []string{"CODE_CHECKSUM"},
+		set: func(c *Config, v string) error { return parseBool(v, &c.Generator.Checksum) }},
+	{env: []string{"WORKER_ID"}, flag: "worker-id", usage: "static short code generator worker ID",
+		set: func(c *Config, v string) error {
+			c.Generator.WorkerIDSource = WorkerIDStatic
+			return parseInt(v, &c.Generator.WorkerID)
+		}},
+	{env: []string{"WORKER_ID_SOURCE"},
+		set: func(c *Config, v string) error { c.Generator.WorkerIDSource = v; return nil }},
+	{env: []string{"POD_NAME"},
+		set: func(c *Config, v string) error { c.Generator.PodName = v; return nil }},
+	{env: []string{"WORKER_LEASE_TTL"},
+		set: func(c *Config, v string) error { return parseDuration(v, time.Second, &c.Generator.LeaseTTL) }},
+
+	// Validator settings
+	{env: []string{"VALIDATOR_RULES_FILE"}, flag: "rules-file", usage: "YAML/JSON file with validator rules",
+		set: func(c *Config, v string) error { c.Validator.RulesFile = v; return nil }},
+	{env: []string{"VALIDATOR_RULES_FROM_DB"},
+		set: func(c *Config, v string) error { return parseBool(v, &c.Validator.RulesFromDatabase) }},
+	{env: []string{"VALIDATOR_RELOAD_INTERVAL"},
+		set: func(c *Config, v string) error { return parseDuration(v, time.Second, &c.Validator.ReloadInterval) }},
+
+	// Resolve cache settings
+	{env: []string{"CACHE_SIZE"},
+		set: func(c *Config, v string) error { return parseInt(v, &c.Cache.Size) }},
+	{env: []string{"CACHE_TTL"},
+		set: func(c *Config, v string) error { return parseDuration(v, time.Second, &c.Cache.TTL) }},
+	{env: []string{"CACHE_NEGATIVE_TTL"},
+		set: func(c *Config, v string) error { return parseDuration(v, time.Second, &c.Cache.NegativeTTL) }},
+	{env: []string{"CACHE_REDIS_URL", "REDIS_URL"},
+		set: func(c *Config, v string) error { c.Cache.RedisURL = v; return nil }},
+
+	// Click counting settings
+	{env: []string{"CLICK_FLUSH_INTERVAL"},
+		set: func(c *Config, v string) error { return parseDuration(v, time.Second, &c.Clicks.FlushInterval) }},
+	{env: []string{"CLICK_BUFFER_MAX_CODES"},
+		set: func(c *Config, v string) error { return parseInt(v, &c.Clicks.MaxCodes) }},
+
+	// Metadata settings
+	{env: []string{"METADATA_ENABLED"},
+		set: func(c *Config, v string) error { return parseBool(v, &c.Metadata.Enabled) }},
+	{env: []string{"METADATA_TIMEOUT"},
+		set: func(c *Config, v string) error { return parseDuration(v, time.Second, &c.Metadata.Timeout) }},
+	{env: []string{"METADATA_MAX_BYTES"},
+		set: func(c *Config, v string) error { return parseInt64(v, &c.Metadata.MaxBytes) }},
+	{env: []string{"METADATA_CONCURRENCY"},
+		set: func(c *Config, v string) error { return parseInt(v, &c.Metadata.Concurrency) }},
+
+	// QR code settings
+	{env: []string{"QR_LOGO_DIR"},
+		set: func(c *Config, v string) error { c.QR.LogoDir = v; return nil }},
+
+	// Targeting settings
+	{env: []string{"TARGETING_COUNTRY_HEADER"},
+		set: func(c *Config, v string) error { c.Targeting.CountryHeader = v; return nil }},
+	{env: []string{"GEOIP_DATABASE"},
+		set: func(c *Config, v string) error { c.Targeting.GeoIPDatabase = v; return nil }},
+	{env: []string{"TARGETING_CLIENT_IP_HEADER"},
+		set: func(c *Config, v string) error { c.Targeting.ClientIPHeader = v; return nil }},
+
+	// Deep link settings
+	{env: []string{"DEEP_LINK_SCHEMES"},
+		set: func(c *Config, v string) error { c.DeepLinks.AllowedSchemes = splitList(v); return nil }},
+	{env: []string{"APPLE_APP_IDS"},
+		set: func(c *Config, v string) error { c.DeepLinks.AppleAppIDs = splitList(v); return nil }},
+
+	// CORS settings
+	{env: []string{"ALLOWED_ORIGINS"},
+		set: func(c *Config, v string) error { c.CORS.AllowedOrigins = splitList(v); return nil }},
+	{env: []string{"CORS_ALLOW_CREDENTIALS"},
+		set: func(c *Config, v string) error { return parseBool(v, &c.CORS.AllowCredentials) }},
+	{env: []string{"CORS_MAX_AGE"},
+		set: func(c *Config, v string) error { return parseDuration(v, time.Second, &c.CORS.MaxAge) }},
+
+	// Observability settings
+	{env: []string{"LOG_LEVEL"}, flag: "log-level", usage: "debug, info, warn or error",
+		set: func(c *Config, v string) error { c.Log.Level = v; return nil }},
+	{env: []string{"SENTRY_DSN"},
+		set: func(c *Config, v string) error { c.Sentry.DSN = v; return nil }},
+	{env: []string{"SENTRY_TRACE_RATE"},
+		set: func(c *Config, v string) error { return parseFloat(v, &c.Sentry.TraceRate) }},
+	{env: []string{"OTEL_EXPORTER_OTLP_ENDPOINT"},
+		set: func(c *Config, v string) error { c.Tracing.OTLPEndpoint = v; return nil }},
+	{env: []string{"TRACE_SAMPLE_RATE"},
+		set: func(c *Config, v string) error { return parseFloat(v, &c.Tracing.SampleRate) }},
+}
+
+// applyEnv overrides fields from the environment, collecting parse errors
+func (c *Config) applyEnv() []error {
+	var problems []error
+	for _, b := range bindings {
+		for _, key := range b.env {
+			value, exists := os.LookupEnv(key)
+			if !exists {
+				continue
+			}
+			if err := b.set(c, value); err != nil {
+				problems = append(problems, fmt.Errorf("env %s: %w", key, err))
+			}
+			break
+		}
+	}
+	return problems
+}
+
+// applyFlags overrides fields from flags that were explicitly set
+func (c *Config) applyFlags(fs *flag.FlagSet) []error {
+	if fs == nil {
+		return nil
+	}
+
+	var problems []error
+	for _, b := range bindings {
+		if b.flag == "" {
+			continue
+		}
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name != b.flag {
+				return
+			}
+			if err := b.set(c, f.Value.String()); err != nil {
+				problems = append(problems, fmt.Errorf("flag --%s: %w", b.flag, err))
+			}
+		})
+	}
+	return problems
+}
+
+func parseInt(value string, dst *int) error {
+	v, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", value)
+	}
+	*dst = v
+	return nil
+}
+
+func parseInt64(value string, dst *int64) error {
+	v, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", value)
+	}
+	*dst = v
+	return nil
+}
+
+func parseFloat(value string, dst *float64) error {
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return fmt.Errorf("%q is not a number", value)
+	}
+	*dst = v
+	return nil
+}
+
+func parseBool(value string, dst *bool) error {
+	v, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("%q is not a boolean", value)
+	}
+	*dst = v
+	return nil
+}
+
+// parseDuration accepts Go durations ("90s") and, for compatibility with
+// older env files, bare integers interpreted in unit
+func parseDuration(value string, unit time.Duration, dst *time.Duration) error {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.Atoi(value); err == nil {
+		*dst = time.Duration(n) * unit
+		return nil
+	}
+	v, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration", value)
+	}
+	*dst = v
+	return nil
+}
+
+// splitList splits comma separated values, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"bytes"
+	"net/url"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+// Redacted returns a copy of the config with fields tagged secret:"true"
+// masked. URLs keep everything but their password.
+func (c *Config) Redacted() *Config {
+	clone := *c
+	clone.CORS.AllowedOrigins = append([]string(nil), c.CORS.AllowedOrigins...)
+	redactStruct(reflect.ValueOf(&clone).Elem())
+	return &clone
+}
+
+func redactStruct(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch {
+		case field.Kind() == reflect.Struct:
+			redactStruct(field)
+		case field.Kind() == reflect.String && t.Field(i).Tag.Get("secret") == "true":
+			field.SetString(redactValue(field.String()))
+		}
+	}
+}
+
+func redactValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	if parsed, err := url.Parse(value); err == nil && parsed.Scheme != "" && parsed.User != nil {
+		if _, hasPassword := parsed.User.Password(); hasPassword {
+			return parsed.Redacted()
+		}
+	}
+	return redacted
+}
+
+// YAML renders the redacted config, as printed by `config print`
+func (c *Config) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/core"
+	"github.com/dev4dreams/dev4url/internal/utils"
+)
+
+// sslModes are the sslmode values lib/pq understands
+var sslModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
+// validate checks every field and returns all problems found
+func (c *Config) validate() []error {
+	var problems []error
+	check := func(ok bool, field, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(c.Environment != "", "environment", "must not be empty")
+	check(isHTTPURL(c.BaseURL), "base_url", "must be an absolute http(s) URL, got %q", c.BaseURL)
+
+	// Server
+	check(c.Server.Address != "", "server.address", "must not be empty")
+	check(c.Server.AdminAddress == "" || c.Server.AdminAddress != c.Server.Address,
+		"server.admin_address", "must differ from server.address")
+	check(c.Server.ReadTimeout > 0, "server.read_timeout", "must be positive")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout", "must be positive")
+	check(c.Server.IdleTimeout > 0, "server.idle_timeout", "must be positive")
+	check(c.Server.ShutdownDrainDelay >= 0, "server.shutdown_drain_delay", "must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout", "must be positive")
+	check(c.Server.ReadinessTimeout > 0, "server.readiness_timeout", "must be positive")
+	check(c.Server.RateLimit > 0, "server.rate_limit", "must be positive")
+	check(c.Server.RateBurst > 0, "server.rate_burst", "must be positive")
+
+	// Database
+	check(c.Database.URL != "", "database.url", "is required")
+	if c.Database.URL != "" {
+		parsed, err := url.Parse(c.Database.URL)
+		check(err == nil && (parsed.Scheme == "postgres" || parsed.Scheme == "postgresql"),
+			"database.url", "must be a postgres:// URL")
+	}
+	check(c.Database.Mode == DatabaseModeDirect || c.Database.Mode == DatabaseModePooler,
+		"database.mode", "must be %q or %q, got %q", DatabaseModeDirect, DatabaseModePooler, c.Database.Mode)
+	check(c.Database.SSLMode == "" || slices.Contains(sslModes, c.Database.SSLMode),
+		"database.ssl_mode", "must be one of %s", strings.Join(sslModes, ", "))
+	check(c.Database.MaxConnections > 0, "database.max_connections", "must be positive")
+	check(c.Database.MinConnections >= 0, "database.min_connections", "must not be negative")
+	check(c.Database.MinConnections <= c.Database.MaxConnections,
+		"database.min_connections", "must not exceed max_connections (%d)", c.Database.MaxConnections)
+	check(c.Database.MaxConnLifetime >= 0, "database.max_conn_lifetime", "must not be negative")
+	check(c.Database.MaxConnIdleTime >= 0, "database.max_conn_idle_time", "must not be negative")
+	check(c.Database.StatementTimeout >= 0, "database.statement_timeout", "must not be negative")
+
+	// Safe Browsing
+	check(c.SafeBrowsing.APIKey != "", "safe_browsing.api_key", "is required")
+
+	// Generator
+	switch c.Generator.Strategy {
+	case core.StrategySnowflake:
+		c.validateSnowflake(check)
+	case core.StrategyRandom:
+		check(c.Generator.Layout.CodeLength >= 6, "generator.layout.code_length",
+			"must be at least 6 for random codes to stay collision-free")
+	case core.StrategyFeistel, core.StrategyHashids:
+		check(c.Generator.Key != "", "generator.key", "is required for the %s strategy", c.Generator.Strategy)
+		check(c.Generator.Layout.CodeLength >= 2 && c.Generator.Layout.CodeLength <= 10,
+			"generator.layout.code_length", "must be between 2 and 10 for the %s strategy", c.Generator.Strategy)
+	default:
+		check(false, "generator.strategy", "must be %q, %q, %q or %q, got %q",
+			core.StrategySnowflake, core.StrategyRandom, core.StrategyFeistel, core.StrategyHashids, c.Generator.Strategy)
+	}
+
+	// Validator
+	check(c.Validator.ReloadInterval > 0, "validator.reload_interval", "must be positive")
+	if c.Validator.RulesFile != "" {
+		_, err := os.Stat(c.Validator.RulesFile)
+		check(err == nil, "validator.rules_file", "%v", err)
+	}
+
+	// Cache
+	check(c.Cache.Size >= 0, "cache.size", "must not be negative")
+	check(c.Cache.Size == 0 || c.Cache.TTL > 0, "cache.ttl", "must be positive")
+	check(c.Cache.NegativeTTL >= 0, "cache.negative_ttl", "must not be negative")
+	if c.Cache.RedisURL != "" {
+		parsed, err := url.Parse(c.Cache.RedisURL)
+		check(err == nil && (parsed.Scheme == "redis" || parsed.Scheme == "rediss") && parsed.Host != "",
+			"cache.redis_url", "must be a redis:// or rediss:// URL")
+		check(c.Cache.Size > 0, "cache.redis_url", "needs cache.size above zero")
+	}
+
+	// Clicks
+	check(c.Clicks.FlushInterval > 0, "clicks.flush_interval", "must be positive")
+	check(c.Clicks.MaxCodes > 0, "clicks.max_codes", "must be positive")
+
+	// Metadata
+	if c.Metadata.Enabled {
+		check(c.Metadata.Timeout > 0, "metadata.timeout", "must be positive")
+		check(c.Metadata.MaxBytes > 0, "metadata.max_bytes", "must be positive")
+		check(c.Metadata.UserAgent != "", "metadata.user_agent", "must not be empty")
+		check(c.Metadata.Concurrency > 0, "metadata.concurrency", "must be positive")
+	}
+
+	// QR codes
+	if c.QR.LogoDir != "" {
+		info, err := os.Stat(c.QR.LogoDir)
+		check(err == nil && info.IsDir(), "qr.logo_dir", "must be a directory")
+	}
+
+	// Targeting
+	if c.Targeting.GeoIPDatabase != "" {
+		info, err := os.Stat(c.Targeting.GeoIPDatabase)
+		check(err == nil && !info.IsDir(), "targeting.geoip_database", "must be a file")
+	}
+
+	// Deep links
+	for _, scheme := range c.DeepLinks.AllowedSchemes {
+		check(utils.IsAppScheme(scheme), "deep_links.allowed_schemes", "%q cannot be used for app links", scheme)
+	}
+	for _, id := range c.DeepLinks.AppleAppIDs {
+		team, bundle, ok := strings.Cut(id, ".")
+		check(ok && len(team) == 10 && bundle != "", "deep_links.apple_app_ids", "%q is not TEAMID.bundle.id", id)
+	}
+	for _, app := range c.DeepLinks.AndroidApps {
+		check(app.PackageName != "", "deep_links.android_apps", "package_name is required")
+		check(len(app.SHA256CertFingerprints) > 0, "deep_links.android_apps",
+			"%s needs sha256_cert_fingerprints", app.PackageName)
+		for _, fingerprint := range app.SHA256CertFingerprints {
+			check(isFingerprint(fingerprint), "deep_links.android_apps",
+				"%q is not a SHA-256 fingerprint like 14:6D:E9:...", fingerprint)
+		}
+	}
+
+	// CORS
+	for _, origin := range c.CORS.AllowedOrigins {
+		check(origin == "*" || isHTTPURL(strings.Replace(origin, "*.", "", 1)),
+			"cors.allowed_origins", "%q is not an origin", origin)
+	}
+	check(c.CORS.MaxAge >= 0, "cors.max_age", "must not be negative")
+
+	// Observability
+	if c.Log.Level != "" {
+		var level slog.Level
+		check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level", "unknown level %q", c.Log.Level)
+	}
+	check(c.Sentry.TraceRate >= 0 && c.Sentry.TraceRate <= 1, "sentry.trace_rate", "must be between 0 and 1")
+	check(c.Tracing.SampleRate >= 0 && c.Tracing.SampleRate <= 1, "tracing.sample_rate", "must be between 0 and 1")
+	check(c.Tracing.OTLPEndpoint == "" || isHTTPURL(c.Tracing.OTLPEndpoint),
+		"tracing.otlp_endpoint", "must be an http(s) URL")
+
+	return problems
+}
+
+// validateSnowflake checks the layout and worker ID settings, which only
+// the snowflake strategy uses
+func (c *Config) validateSnowflake(check func(ok bool, field, format string, args ...any)) {
+	layout := c.Generator.CodeLayout()
+	layoutErr := layout.Validate()
+	check(layoutErr == nil, "generator.layout", "%v", layoutErr)
+	switch c.Generator.WorkerIDSource {
+	case WorkerIDStatic:
+		check(c.Generator.WorkerID >= 0, "generator.worker_id", "must not be negative")
+		check(layoutErr != nil || int64(c.Generator.WorkerID) <= layout.MaxWorkerID(),
+			"generator.worker_id", "must not exceed %d for %d worker bits", layout.MaxWorkerID(), layout.WorkerBits)
+	case WorkerIDOrdinal:
+	case WorkerIDLease:
+		check(c.Generator.LeaseTTL >= 3*time.Second, "generator.lease_ttl", "must be at least 3s")
+	default:
+		check(false, "generator.worker_id_source", "must be %q, %q or %q, got %q",
+			WorkerIDStatic, WorkerIDOrdinal, WorkerIDLease, c.Generator.WorkerIDSource)
+	}
+}
+
+// isFingerprint reports whether value is 32 colon-separated hex pairs
+func isFingerprint(value string) bool {
+	pairs := strings.Split(value, ":")
+	if len(pairs) != 32 {
+		return false
+	}
+	for _, pair := range pairs {
+		if len(pair) != 2 || strings.Trim(pair, "0123456789abcdefABCDEF") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// isHTTPURL reports whether value is an absolute http or https URL
+func isHTTPURL(value string) bool {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
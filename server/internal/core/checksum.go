@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dev4dreams/dev4url/pkg/codec"
+)
+
+// ErrBadChecksum marks a code whose check character does not match, i.e. a
+// typo that cannot exist in the database
+var ErrBadChecksum = errors.New("short code check character mismatch")
+
+// checkWeights are the odd numbers below 58 except 29, so each is coprime
+// to 58 and neighbours differ by 2 or 4
+var checkWeights = func() []int {
+	var weights []int
+	for w := 1; w < len(alphabet); w += 2 {
+		if w != len(alphabet)/2 {
+			weights = append(weights, w)
+		}
+	}
+	return weights
+}()
+
+// checkChar is a weighted sum mod 58. Because the weights are coprime to
+// 58 every single character substitution changes the result, as do
+// adjacent transpositions unless the two characters are exactly 29 apart.
+func checkChar(code string) (byte, bool) {
+	sum := 0
+	for i := 0; i < len(code); i++ {
+		value := codec.Base58.Index(code[i])
+		if value < 0 {
+			return 0, false
+		}
+		sum += checkWeights[i%len(checkWeights)] * value
+	}
+	return alphabet[sum%len(alphabet)], true
+}
+
+// checksumGenerator appends a check character to every generated code
+type checksumGenerator struct {
+	next CodeGenerator
+}
+
+// WithChecksum wraps a generator so its codes end in a check character
+func WithChecksum(next CodeGenerator) CodeGenerator {
+	return checksumGenerator{next: next}
+}
+
+// Generate implements CodeGenerator
+func (g checksumGenerator) Generate(ctx context.Context) (string, error) {
+	code, err := g.next.Generate(ctx)
+	if err != nil {
+		return "", err
+	}
+	check, ok := checkChar(code)
+	if !ok {
+		return "", errors.New("generated code contains characters outside the alphabet")
+	}
+	return code + string(check), nil
+}
+
+// CodeFormat describes the shape of generated codes, letting the resolve
+// path turn away typos without a database query
+type CodeFormat struct {
+	// Length is the full code length including the check character. Codes
+	// of any other length predate the checksum and are not verified.
+	Length   int
+	Checksum bool
+}
+
+// Verify returns ErrBadChecksum when code has the checksummed length but
+// is not a code this format could have produced
+func (f CodeFormat) Verify(code string) error {
+	if !f.Checksum || len(code) != f.Length || len(code) < 2 {
+		return nil
+	}
+	body, check := code[:len(code)-1], code[len(code)-1]
+	if want, ok := checkChar(body); !ok || want != check {
+		return ErrBadChecksum
+	}
+	return nil
+}
+
+// Candidates lists the well-formed codes one typo away from code: a single
+// substituted character or two swapped neighbours
+func (f CodeFormat) Candidates(code string) []string {
+	if !f.Checksum || len(code) != f.Length {
+		return nil
+	}
+
+	var candidates []string
+	seen := make(map[string]bool)
+	consider := func(candidate []byte) {
+		s := string(candidate)
+		if s != code && !seen[s] && f.Verify(s) == nil {
+			seen[s] = true
+			candidates = append(candidates, s)
+		}
+	}
+
+	buf := []byte(code)
+	for i := range buf {
+		original := buf[i]
+		for j := 0; j < len(alphabet); j++ {
+			buf[i] = alphabet[j]
+			consider(buf)
+		}
+		buf[i] = original
+	}
+	for i := 0; i+1 < len(buf); i++ {
+		buf[i], buf[i+1] = buf[i+1], buf[i]
+		consider(buf)
+		buf[i], buf[i+1] = buf[i+1], buf[i]
+	}
+	return candidates
+}
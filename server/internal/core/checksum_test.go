@@ -0,0 +1,93 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestChecksumGenerator(t *testing.T) {
+	ctx := context.Background()
+	generator, err := NewHashidsGenerator(7, "checksum", &counter{})
+	if err != nil {
+		t.Fatalf("NewHashidsGenerator() unexpected error: %v", err)
+	}
+	checked := WithChecksum(generator)
+	format := CodeFormat{Length: 8, Checksum: true}
+
+	for i := 0; i < 100; i++ {
+		code, err := checked.Generate(ctx)
+		if err != nil {
+			t.Fatalf("Generate() unexpected error: %v", err)
+		}
+		if len(code) != 8 {
+			t.Fatalf("Generate() = %q, want 8 characters", code)
+		}
+		if err := format.Verify(code); err != nil {
+			t.Fatalf("Verify(%q) unexpected error: %v", code, err)
+		}
+	}
+}
+
+func TestChecksumCatchesTypos(t *testing.T) {
+	format := CodeFormat{Length: 8, Checksum: true}
+	check, _ := checkChar("abcDEF7")
+	code := "abcDEF7" + string(check)
+
+	for i := 0; i < len(code); i++ {
+		for j := 0; j < len(alphabet); j++ {
+			if alphabet[j] == code[i] {
+				continue
+			}
+			typo := code[:i] + string(alphabet[j]) + code[i+1:]
+			if err := format.Verify(typo); !errors.Is(err, ErrBadChecksum) {
+				t.Fatalf("Verify(%q) = %v, want ErrBadChecksum", typo, err)
+			}
+		}
+	}
+
+	for i := 0; i+1 < len(code); i++ {
+		if code[i] == code[i+1] {
+			continue
+		}
+		typo := code[:i] + string(code[i+1]) + string(code[i]) + code[i+2:]
+		if err := format.Verify(typo); !errors.Is(err, ErrBadChecksum) {
+			t.Errorf("Verify(%q) = %v, want ErrBadChecksum for swapped neighbours", typo, err)
+		}
+	}
+
+	if err := format.Verify("abc0DEF7"); !errors.Is(err, ErrBadChecksum) {
+		t.Errorf("Expected a character outside the alphabet to fail, got %v", err)
+	}
+}
+
+func TestChecksumSkipsOtherLengths(t *testing.T) {
+	format := CodeFormat{Length: 8, Checksum: true}
+	if err := format.Verify("abc1234"); err != nil {
+		t.Errorf("Expected a legacy 7 character code to pass, got %v", err)
+	}
+	if err := (CodeFormat{Length: 8}).Verify("abcdefgh"); err != nil {
+		t.Errorf("Expected no verification without a checksum, got %v", err)
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	format := CodeFormat{Length: 8, Checksum: true}
+	check, _ := checkChar("abcDEF7")
+	code := "abcDEF7" + string(check)
+	typo := code[:3] + "x" + code[4:]
+
+	candidates := format.Candidates(typo)
+	found := false
+	for _, candidate := range candidates {
+		if err := format.Verify(candidate); err != nil {
+			t.Errorf("Candidate %q fails its checksum", candidate)
+		}
+		if candidate == code {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Candidates(%q) = %v, want them to include %q", typo, candidates, code)
+	}
+}
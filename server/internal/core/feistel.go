@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	"github.com/dev4dreams/dev4url/pkg/codec"
+)
+
+const (
+	feistelRounds = 4
+	// maxFeistelLength keeps 58^length within a uint64 with room to spare
+	maxFeistelLength = 10
+)
+
+// FeistelGenerator maps a database sequence through a keyed Feistel
+// permutation of the code space. Codes are unique as long as the sequence
+// is, yet consecutive links get unrelated codes.
+type FeistelGenerator struct {
+	length   int
+	size     uint64 // number of codes, 58^length
+	halfBits uint
+	key      []byte
+	source   SequenceSource
+}
+
+// NewFeistelGenerator creates a generator of length character codes keyed
+// with key, which must stay the same for the lifetime of the deployment
+func NewFeistelGenerator(length int, key []byte, source SequenceSource) (*FeistelGenerator, error) {
+	if length <= 0 || length > maxFeistelLength {
+		return nil, fmt.Errorf("%w: feistel length must be between 1 and %d", ErrInvalidLayout, maxFeistelLength)
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("%w: feistel key is required", ErrInvalidLayout)
+	}
+
+	size := uint64(1)
+	for i := 0; i < length; i++ {
+		size *= uint64(len(alphabet))
+	}
+	// The network permutes an even number of bits covering the code space;
+	// values landing outside it are walked forward until they fit
+	width := uint(bits.Len64(size - 1))
+	width += width % 2
+
+	return &FeistelGenerator{
+		length:   length,
+		size:     size,
+		halfBits: width / 2,
+		key:      key,
+		source:   source,
+	}, nil
+}
+
+// Generate implements CodeGenerator
+func (g *FeistelGenerator) Generate(ctx context.Context) (string, error) {
+	n, err := g.source.NextCodeSequence(ctx)
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || uint64(n) >= g.size {
+		return "", fmt.Errorf("%w: sequence %d exceeds %d codes", ErrCodeSpaceExhausted, n, g.size)
+	}
+	return codec.Base58.EncodePadded(g.permute(uint64(n)), g.length)
+}
+
+// permute is a bijection on [0, size)
+func (g *FeistelGenerator) permute(x uint64) uint64 {
+	for {
+		x = g.encrypt(x)
+		if x < g.size {
+			return x
+		}
+	}
+}
+
+// unpermute inverts permute
+func (g *FeistelGenerator) unpermute(x uint64) uint64 {
+	for {
+		x = g.decrypt(x)
+		if x < g.size {
+			return x
+		}
+	}
+}
+
+func (g *FeistelGenerator) encrypt(x uint64) uint64 {
+	mask := uint64(1)<<g.halfBits - 1
+	left, right := x>>g.halfBits, x&mask
+	for round := 0; round < feistelRounds; round++ {
+		left, right = right, left^(g.round(round, right)&mask)
+	}
+	return left<<g.halfBits | right
+}
+
+func (g *FeistelGenerator) decrypt(x uint64) uint64 {
+	mask := uint64(1)<<g.halfBits - 1
+	left, right := x>>g.halfBits, x&mask
+	for round := feistelRounds - 1; round >= 0; round-- {
+		left, right = right^(g.round(round, left)&mask), left
+	}
+	return left<<g.halfBits | right
+}
+
+// round is the keyed round function
+func (g *FeistelGenerator) round(round int, half uint64) uint64 {
+	var msg [9]byte
+	msg[0] = byte(round)
+	binary.BigEndian.PutUint64(msg[1:], half)
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write(msg[:])
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
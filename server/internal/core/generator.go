@@ -0,0 +1,40 @@
+package core
+
+import (
+	"context"
+	"errors"
+)
+
+// Short code strategies selectable per deployment
+const (
+	StrategySnowflake = "snowflake"
+	StrategyRandom    = "random"
+	StrategyFeistel   = "feistel"
+	StrategyHashids   = "hashids"
+)
+
+var (
+	ErrCodeSpaceExhausted = errors.New("short code space exhausted")
+	ErrTooManyCollisions  = errors.New("no free short code found")
+)
+
+// CodeGenerator produces short codes for new links
+type CodeGenerator interface {
+	Generate(ctx context.Context) (string, error)
+}
+
+// CodeChecker reports whether a code is already taken
+type CodeChecker interface {
+	ShortURLExists(ctx context.Context, code string) (bool, error)
+}
+
+// SequenceSource hands out increasing, never reused numbers, e.g. a
+// database sequence shared by every replica
+type SequenceSource interface {
+	NextCodeSequence(ctx context.Context) (int64, error)
+}
+
+// Generate implements CodeGenerator
+func (g *Generator) Generate(ctx context.Context) (string, error) {
+	return g.GenerateShortURL()
+}
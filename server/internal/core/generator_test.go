@@ -0,0 +1,154 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// counter is an in-memory SequenceSource
+type counter struct {
+	mu sync.Mutex
+	n  int64
+}
+
+func (c *counter) NextCodeSequence(ctx context.Context) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n++
+	return c.n, nil
+}
+
+// takenCodes is a CodeChecker reporting the first taken calls as collisions
+type takenCodes struct {
+	taken int
+	calls int
+}
+
+func (t *takenCodes) ShortURLExists(ctx context.Context, code string) (bool, error) {
+	t.calls++
+	return t.calls <= t.taken, nil
+}
+
+func TestRandomGenerator(t *testing.T) {
+	ctx := context.Background()
+	generator, err := NewRandomGenerator(8, nil)
+	if err != nil {
+		t.Fatalf("NewRandomGenerator() unexpected error: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		code, err := generator.Generate(ctx)
+		if err != nil {
+			t.Fatalf("Generate() unexpected error: %v", err)
+		}
+		if len(code) != 8 || strings.Trim(code, alphabet) != "" {
+			t.Fatalf("Generate() = %q, want 8 alphabet characters", code)
+		}
+		if seen[code] {
+			t.Fatalf("Duplicate random code %q", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestRandomGeneratorRetriesCollisions(t *testing.T) {
+	ctx := context.Background()
+
+	store := &takenCodes{taken: 2}
+	generator, _ := NewRandomGenerator(7, store)
+	if _, err := generator.Generate(ctx); err != nil {
+		t.Fatalf("Generate() unexpected error: %v", err)
+	}
+	if store.calls != 3 {
+		t.Errorf("Expected 3 lookups for 2 collisions, got %d", store.calls)
+	}
+
+	generator, _ = NewRandomGenerator(7, &takenCodes{taken: DefaultRandomAttempts})
+	if _, err := generator.Generate(ctx); !errors.Is(err, ErrTooManyCollisions) {
+		t.Errorf("Generate() error = %v, want ErrTooManyCollisions", err)
+	}
+}
+
+func TestFeistelIsAPermutation(t *testing.T) {
+	generator, err := NewFeistelGenerator(2, []byte("test key"), &counter{})
+	if err != nil {
+		t.Fatalf("NewFeistelGenerator() unexpected error: %v", err)
+	}
+
+	seen := make(map[uint64]bool, generator.size)
+	for x := uint64(0); x < generator.size; x++ {
+		y := generator.permute(x)
+		if y >= generator.size {
+			t.Fatalf("permute(%d) = %d is outside the code space", x, y)
+		}
+		if seen[y] {
+			t.Fatalf("permute(%d) = %d collides", x, y)
+		}
+		seen[y] = true
+		if back := generator.unpermute(y); back != x {
+			t.Fatalf("unpermute(permute(%d)) = %d", x, back)
+		}
+	}
+}
+
+func TestFeistelGenerator(t *testing.T) {
+	ctx := context.Background()
+	generator, err := NewFeistelGenerator(7, []byte("test key"), &counter{})
+	if err != nil {
+		t.Fatalf("NewFeistelGenerator() unexpected error: %v", err)
+	}
+
+	first, _ := generator.Generate(ctx)
+	second, _ := generator.Generate(ctx)
+	if len(first) != 7 || len(second) != 7 || first == second {
+		t.Errorf("Expected two distinct 7 character codes, got %q and %q", first, second)
+	}
+	if first[:5] == second[:5] {
+		t.Errorf("Consecutive codes share a prefix: %q and %q", first, second)
+	}
+
+	other, _ := NewFeistelGenerator(7, []byte("other key"), &counter{})
+	if code, _ := other.Generate(ctx); code == first {
+		t.Errorf("Different keys produced the same first code %q", code)
+	}
+
+	small, _ := NewFeistelGenerator(1, []byte("test key"), &counter{n: int64(len(alphabet)) - 1})
+	if _, err := small.Generate(ctx); !errors.Is(err, ErrCodeSpaceExhausted) {
+		t.Errorf("Generate() past the code space error = %v, want ErrCodeSpaceExhausted", err)
+	}
+}
+
+func TestHashidsGenerator(t *testing.T) {
+	ctx := context.Background()
+	generator, err := NewHashidsGenerator(6, "test salt", &counter{})
+	if err != nil {
+		t.Fatalf("NewHashidsGenerator() unexpected error: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := int64(1); i <= 2000; i++ {
+		code, err := generator.Generate(ctx)
+		if err != nil {
+			t.Fatalf("Generate() unexpected error: %v", err)
+		}
+		if len(code) < 6 {
+			t.Fatalf("Generate() = %q, shorter than 6", code)
+		}
+		if seen[code] {
+			t.Fatalf("Duplicate code %q for counter %d", code, i)
+		}
+		seen[code] = true
+		if n, err := generator.decode(code); err != nil || n != uint64(i) {
+			t.Fatalf("decode(%q) = %d, %v, want %d", code, n, err, i)
+		}
+	}
+
+	other, _ := NewHashidsGenerator(6, "other salt", &counter{})
+	if other.encode(1) == generator.encode(1) {
+		t.Errorf("Different salts produced the same code %q", other.encode(1))
+	}
+}
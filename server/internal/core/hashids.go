@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// HashidsGenerator obfuscates a database counter the way hashids does: the
+// alphabet is shuffled with a salt, and each code starts with a "lottery"
+// character that reshuffles the alphabet for the remaining digits, so
+// consecutive counters give unrelated looking codes. This hides ordering
+// from casual observers but is not encryption; use FeistelGenerator when
+// that matters.
+type HashidsGenerator struct {
+	minLength int
+	alphabet  string // salted shuffle of the base58 alphabet
+	salt      string
+	source    SequenceSource
+}
+
+// NewHashidsGenerator creates a generator whose codes are at least
+// minLength characters, growing as the counter does
+func NewHashidsGenerator(minLength int, salt string, source SequenceSource) (*HashidsGenerator, error) {
+	if minLength < 2 {
+		return nil, fmt.Errorf("%w: hashids length must be at least 2", ErrInvalidLayout)
+	}
+	if salt == "" {
+		return nil, fmt.Errorf("%w: hashids salt is required", ErrInvalidLayout)
+	}
+	return &HashidsGenerator{
+		minLength: minLength,
+		alphabet:  consistentShuffle(alphabet, salt),
+		salt:      salt,
+		source:    source,
+	}, nil
+}
+
+// Generate implements CodeGenerator
+func (g *HashidsGenerator) Generate(ctx context.Context) (string, error) {
+	n, err := g.source.NextCodeSequence(ctx)
+	if err != nil {
+		return "", err
+	}
+	if n < 0 {
+		return "", fmt.Errorf("%w: negative sequence %d", ErrCodeSpaceExhausted, n)
+	}
+	return g.encode(uint64(n)), nil
+}
+
+func (g *HashidsGenerator) encode(n uint64) string {
+	base := uint64(len(g.alphabet))
+	lottery := g.alphabet[n%base]
+	digits := consistentShuffle(g.alphabet, string(lottery)+g.salt)
+
+	var buf [16]byte // 58^11 > 2^64
+	i := len(buf)
+	for {
+		i--
+		buf[i] = digits[n%base]
+		n /= base
+		if n == 0 {
+			break
+		}
+	}
+	for len(buf)-i < g.minLength-1 {
+		i--
+		buf[i] = digits[0]
+	}
+	return string(lottery) + string(buf[i:])
+}
+
+// decode inverts encode
+func (g *HashidsGenerator) decode(code string) (uint64, error) {
+	if len(code) < g.minLength {
+		return 0, errors.New("code shorter than the minimum length")
+	}
+	digits := consistentShuffle(g.alphabet, code[:1]+g.salt)
+	var n uint64
+	for i := 1; i < len(code); i++ {
+		pos := -1
+		for j := 0; j < len(digits); j++ {
+			if digits[j] == code[i] {
+				pos = j
+				break
+			}
+		}
+		if pos < 0 {
+			return 0, errors.New("invalid character in code")
+		}
+		n = n*uint64(len(digits)) + uint64(pos)
+	}
+	if g.encode(n) != code {
+		return 0, errors.New("code was not produced by this salt")
+	}
+	return n, nil
+}
+
+// consistentShuffle is the hashids salted Fisher-Yates shuffle
+func consistentShuffle(alphabet, salt string) string {
+	if salt == "" {
+		return alphabet
+	}
+	chars := []byte(alphabet)
+	for i, v, p := len(chars)-1, 0, 0; i > 0; i-- {
+		v %= len(salt)
+		p += int(salt[v])
+		j := (int(salt[v]) + v + p) % i
+		chars[i], chars[j] = chars[j], chars[i]
+		v++
+	}
+	return string(chars)
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/dev4dreams/dev4url/pkg/codec"
+)
+
+var (
+	ErrInvalidLayout     = errors.New("invalid ID layout")
+	ErrTimestampOverflow = errors.New("timestamp does not fit the ID layout")
+	// ErrCodeOverflow is returned when an ID needs more characters than the
+	// code length allows
+	ErrCodeOverflow = codec.ErrOverflow
+)
+
+// Layout describes how an ID is packed from timestamp, worker ID and
+// sequence, and how many base58 characters encode it. Every replica must
+// share the layout; changing it can make new codes collide with old ones.
+type Layout struct {
+	Epoch time.Time
+	// Tick is the timestamp resolution; each worker issues at most
+	// 2^SequenceBits IDs per tick
+	Tick          time.Duration
+	TimestampBits int
+	WorkerBits    int
+	SequenceBits  int
+	Length        int // characters per code
+}
+
+// DefaultLayout packs 29 bits of seconds since 2024 (~17 years), 8 bits of
+// worker ID (256 workers) and 4 bits of sequence (16 IDs per second per
+// worker) into 41 bits, which 7 base58 characters just hold
+func DefaultLayout() Layout {
+	return Layout{
+		Epoch:         time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Tick:          time.Second,
+		TimestampBits: 29,
+		WorkerBits:    8,
+		SequenceBits:  4,
+		Length:        7,
+	}
+}
+
+// Validate checks that the layout is usable and that every ID it can
+// produce encodes in Length characters
+func (l Layout) Validate() error {
+	if l.Epoch.IsZero() {
+		return fmt.Errorf("%w: epoch is required", ErrInvalidLayout)
+	}
+	if l.Tick <= 0 {
+		return fmt.Errorf("%w: tick must be positive", ErrInvalidLayout)
+	}
+	if l.TimestampBits <= 0 || l.WorkerBits < 0 || l.SequenceBits < 0 {
+		return fmt.Errorf("%w: timestamp bits must be positive, worker and sequence bits not negative", ErrInvalidLayout)
+	}
+	if bits := l.totalBits(); bits > 63 {
+		return fmt.Errorf("%w: %d bits exceed the 63 available", ErrInvalidLayout, bits)
+	}
+	if l.Length <= 0 {
+		return fmt.Errorf("%w: length must be positive", ErrInvalidLayout)
+	}
+	if need := minLength(l.totalBits()); l.Length < need {
+		return fmt.Errorf("%w: %d bits need %d base58 characters, length is %d",
+			ErrInvalidLayout, l.totalBits(), need, l.Length)
+	}
+	return nil
+}
+
+func (l Layout) totalBits() int {
+	return l.TimestampBits + l.WorkerBits + l.SequenceBits
+}
+
+// minLength is the number of base58 characters needed for any value of
+// the given bit width (at most 63)
+func minLength(bits int) int {
+	return codec.Base58.Width(1<<bits - 1)
+}
+
+// MaxWorkerID is the highest worker ID the layout holds
+func (l Layout) MaxWorkerID() int64 {
+	return -1 ^ (-1 << l.WorkerBits)
+}
+
+func (l Layout) maxSequence() int64 {
+	return -1 ^ (-1 << l.SequenceBits)
+}
+
+func (l Layout) maxTimestamp() int64 {
+	return -1 ^ (-1 << l.TimestampBits)
+}
+
+// Exhausted returns when the timestamp bits run out, or the zero time if
+// that is too far out to represent
+func (l Layout) Exhausted() time.Time {
+	ticks := l.maxTimestamp() + 1
+	if ticks > math.MaxInt64/int64(l.Tick) {
+		return time.Time{}
+	}
+	return l.tickStart(ticks)
+}
+
+// ticks converts t to ticks since the epoch, -1 before it
+func (l Layout) ticks(t time.Time) int64 {
+	if t.Before(l.Epoch) {
+		return -1
+	}
+	return int64(t.Sub(l.Epoch) / l.Tick)
+}
+
+// tickStart is the time at which tick begins
+func (l Layout) tickStart(tick int64) time.Time {
+	return l.Epoch.Add(time.Duration(tick) * l.Tick)
+}
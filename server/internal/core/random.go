@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/dev4dreams/dev4url/internal/metrics"
+)
+
+// DefaultRandomAttempts bounds how many colliding codes RandomGenerator
+// draws before giving up
+const DefaultRandomAttempts = 5
+
+// RandomGenerator draws codes uniformly from crypto/rand, so they carry no
+// ordering and cannot be enumerated. Collisions are retried against the
+// store; the unique index on short_url still backs up the check.
+type RandomGenerator struct {
+	length   int
+	attempts int
+	store    CodeChecker
+}
+
+// NewRandomGenerator creates a generator of length character codes
+func NewRandomGenerator(length int, store CodeChecker) (*RandomGenerator, error) {
+	if length <= 0 {
+		return nil, fmt.Errorf("%w: length must be positive", ErrInvalidLayout)
+	}
+	return &RandomGenerator{length: length, attempts: DefaultRandomAttempts, store: store}, nil
+}
+
+// Generate implements CodeGenerator
+func (g *RandomGenerator) Generate(ctx context.Context) (string, error) {
+	for attempt := 0; attempt < g.attempts; attempt++ {
+		code, err := randomCode(g.length)
+		if err != nil {
+			return "", err
+		}
+		if g.store == nil {
+			return code, nil
+		}
+		taken, err := g.store.ShortURLExists(ctx, code)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return code, nil
+		}
+		metrics.GeneratorCollisions.Inc()
+	}
+	return "", fmt.Errorf("%w after %d attempts", ErrTooManyCollisions, g.attempts)
+}
+
+// randomCode draws length characters without modulo bias by rejecting
+// bytes past the largest multiple of the alphabet size
+func randomCode(length int) (string, error) {
+	const limit = 256 - 256%len(alphabet)
+
+	code := make([]byte, 0, length)
+	buf := make([]byte, length+length/2)
+	for len(code) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("reading random bytes: %w", err)
+		}
+		for _, b := range buf {
+			if int(b) < limit && len(code) < length {
+				code = append(code, alphabet[int(b)%len(alphabet)])
+			}
+		}
+	}
+	return string(code), nil
+}
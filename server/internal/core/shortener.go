@@ -2,54 +2,106 @@ package core
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"time"
-)
-
-const (
-	// Characters carefully chosen to avoid ambiguity
-	alphabet = "123456789abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
-
-	// Bit lengths
-	timestampBits = 29 // ~17 years
-	workerBits    = 8  // 256 workers
-	sequenceBits  = 4  // 16 sequences per second
-
-	maxWorkerID = -1 ^ (-1 << workerBits)
-	maxSequence = -1 ^ (-1 << sequenceBits)
 
-	// For bit shifting
-	workerShift    = sequenceBits
-	timestampShift = sequenceBits + workerBits
+	"github.com/dev4dreams/dev4url/internal/metrics"
+	"github.com/dev4dreams/dev4url/pkg/codec"
 )
 
-var (
-	// Custom epoch (2024-01-01 00:00:00 UTC)
-	epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano() / 1e6
+// Characters carefully chosen to avoid ambiguity
+const alphabet = codec.Base58Chars
 
+var (
 	ErrInvalidWorkerID     = errors.New("worker ID exceeds maximum")
 	ErrClockMovedBackwards = errors.New("clock moved backwards")
 )
 
+// DefaultMaxClockSkew is how far the clock may step backwards before
+// NextID gives up with ErrClockMovedBackwards
+const DefaultMaxClockSkew = 10 * time.Millisecond
+
+// Clock is the generator's time source
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// monotonicClock reports wall time as its start time plus the elapsed
+// monotonic time, so NTP steps and manual clock changes never move it
+// backwards within a process
+type monotonicClock struct {
+	start time.Time
+}
+
+func (c monotonicClock) Now() time.Time {
+	return c.start.Add(time.Since(c.start))
+}
+
+func (c monotonicClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// Option customizes a Generator
+type Option func(*Generator)
+
+// WithClock replaces the default monotonic clock, mostly for tests
+func WithClock(clock Clock) Option {
+	return func(g *Generator) { g.clock = clock }
+}
+
+// WithMaxClockSkew sets how large a backward clock step is absorbed by
+// reusing the last timestamp instead of failing
+func WithMaxClockSkew(skew time.Duration) Option {
+	return func(g *Generator) { g.maxSkew = skew }
+}
+
+// WithLayout replaces DefaultLayout
+func WithLayout(layout Layout) Option {
+	return func(g *Generator) { g.layout = layout }
+}
+
 // Generator handles the generation of unique IDs
 type Generator struct {
-	mu        sync.Mutex
+	mu       sync.Mutex
+	clock    Clock
+	layout   Layout
+	maxSkew  time.Duration
+	lastSeen time.Time // latest clock reading
+	// timestamp is the tick of the last ID, counted from the layout's epoch
 	timestamp int64
 	workerID  int64
 	sequence  int64
 }
 
-// NewGenerator creates a new Generator instance
-func NewGenerator(workerID int64) (*Generator, error) {
-	if workerID < 0 || workerID > maxWorkerID {
+// NewGenerator creates a new Generator instance. The layout is validated
+// up front so a code never needs truncating.
+func NewGenerator(workerID int64, opts ...Option) (*Generator, error) {
+	g := &Generator{
+		clock:     monotonicClock{start: time.Now()},
+		layout:    DefaultLayout(),
+		maxSkew:   DefaultMaxClockSkew,
+		timestamp: -1,
+		workerID:  workerID,
+		sequence:  0,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	if err := g.layout.Validate(); err != nil {
+		return nil, err
+	}
+	if workerID < 0 || workerID > g.layout.MaxWorkerID() {
 		return nil, ErrInvalidWorkerID
 	}
+	return g, nil
+}
 
-	return &Generator{
-		timestamp: 0,
-		workerID:  workerID,
-		sequence:  0,
-	}, nil
+// Layout returns the layout IDs are packed with
+func (g *Generator) Layout() Layout {
+	return g.layout
 }
 
 // NextID generates a new unique ID
@@ -57,93 +109,72 @@ func (g *Generator) NextID() (int64, error) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	timestamp := time.Now().UnixNano() / 1e6
+	now := g.clock.Now()
+	if g.lastSeen.Sub(now) > g.maxSkew {
+		return 0, ErrClockMovedBackwards
+	}
+	if now.After(g.lastSeen) {
+		g.lastSeen = now
+	}
 
+	timestamp := g.layout.ticks(now)
 	if timestamp < g.timestamp {
-		return 0, ErrClockMovedBackwards
+		// Small step back: keep issuing from the last timestamp's
+		// sequence, waiting for the clock to catch up once it runs out
+		metrics.GeneratorClockBackwards.Inc()
+		timestamp = g.timestamp
 	}
 
 	if timestamp == g.timestamp {
-		g.sequence = (g.sequence + 1) & maxSequence
+		g.sequence = (g.sequence + 1) & g.layout.maxSequence()
 		if g.sequence == 0 {
-			// Sequence exhausted, wait for next millisecond
-			for timestamp <= g.timestamp {
-				timestamp = time.Now().UnixNano() / 1e6
-			}
+			// Sequence exhausted, wait for the next tick
+			metrics.GeneratorSequenceWaits.Inc()
+			timestamp = g.waitAfter(g.timestamp)
 		}
 	} else {
 		g.sequence = 0
 	}
 
+	if timestamp < 0 || timestamp > g.layout.maxTimestamp() {
+		return 0, fmt.Errorf("%w: %s is outside %s to %s", ErrTimestampOverflow,
+			now.UTC().Format(time.RFC3339), g.layout.Epoch.Format(time.RFC3339),
+			g.layout.Exhausted().Format(time.RFC3339))
+	}
 	g.timestamp = timestamp
 
-	id := ((timestamp - epoch) << timestampShift) |
-		(g.workerID << workerShift) |
+	id := (timestamp << (g.layout.SequenceBits + g.layout.WorkerBits)) |
+		(g.workerID << g.layout.SequenceBits) |
 		g.sequence
 
 	return id, nil
 }
 
-// GenerateShortURL generates a 7-character short URL
+// waitAfter sleeps until the clock passes the last tick and returns the
+// new tick
+func (g *Generator) waitAfter(last int64) int64 {
+	for {
+		now := g.clock.Now()
+		if tick := g.layout.ticks(now); tick > last {
+			return tick
+		}
+		g.clock.Sleep(g.layout.tickStart(last + 1).Sub(now))
+	}
+}
+
+// GenerateShortURL generates a short URL of the layout's length
 func (g *Generator) GenerateShortURL() (string, error) {
 	id, err := g.NextID()
 	if err != nil {
 		return "", err
 	}
-	return encodeToBase58(uint64(id)), nil
+	return codec.Base58.EncodePadded(uint64(id), g.layout.Length)
 }
 
 // Validate if a received short URL is legitimate
 func (g *Generator) IsValidShortURL(shortURL string) bool {
-	_, err := decodeFromBase58(shortURL)
-	return err == nil
-}
-
-// encodeToBase58 converts a number to base58 string
-func encodeToBase58(num uint64) string {
-	if num == 0 {
-		return string(alphabet[0])
-	}
-
-	// Pre-allocate slice with capacity of 7 for efficiency
-	chars := make([]byte, 0, 7)
-	base := uint64(len(alphabet))
-
-	for num > 0 {
-		chars = append([]byte{alphabet[num%base]}, chars...)
-		num = num / base
-	}
-
-	// Pad to ensure 7 characters
-	for len(chars) < 7 {
-		chars = append([]byte{alphabet[0]}, chars...)
-	}
-
-	// Ensure we don't exceed 7 characters
-	if len(chars) > 7 {
-		chars = chars[len(chars)-7:]
-	}
-
-	return string(chars)
-}
-
-// decodeFromBase58 converts a base58 string back to number
-func decodeFromBase58(encoded string) (uint64, error) {
-	var num uint64
-	base := uint64(len(alphabet))
-
-	for _, char := range encoded {
-		pos := -1
-		for i, c := range alphabet {
-			if c == char {
-				pos = i
-				break
-			}
-		}
-		if pos == -1 {
-			return 0, errors.New("invalid character in encoded string")
-		}
-		num = num*base + uint64(pos)
+	if len(shortURL) != g.layout.Length {
+		return false
 	}
-	return num, nil
+	return codec.Base58.Valid(shortURL)
 }
@@ -1,11 +1,36 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/dev4dreams/dev4url/pkg/codec"
 )
 
+// fakeClock is a manually driven Clock; Sleep advances it
+type fakeClock struct {
+	now    time.Time
+	slept  time.Duration
+	sleeps int
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.slept += d
+	c.sleeps++
+	c.now = c.now.Add(d)
+}
+
+// newFakeClock starts a clock within DefaultLayout's range; generators
+// using it never block on the real clock when a sequence runs out
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+}
+
 func TestNewGenerator(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -54,7 +79,7 @@ func TestNewGenerator(t *testing.T) {
 }
 
 func TestGenerateShortURL(t *testing.T) {
-	generator, err := NewGenerator(1)
+	generator, err := NewGenerator(1, WithClock(newFakeClock()))
 	if err != nil {
 		t.Fatalf("Failed to create generator: %v", err)
 	}
@@ -78,7 +103,7 @@ func TestGenerateShortURL(t *testing.T) {
 }
 
 func TestURLUniqueness(t *testing.T) {
-	generator, err := NewGenerator(1)
+	generator, err := NewGenerator(1, WithClock(newFakeClock()))
 	if err != nil {
 		t.Fatalf("Failed to create generator: %v", err)
 	}
@@ -101,7 +126,7 @@ func TestURLUniqueness(t *testing.T) {
 }
 
 func TestConcurrentGeneration(t *testing.T) {
-	generator, err := NewGenerator(1)
+	generator, err := NewGenerator(1, WithClock(newFakeClock()))
 	if err != nil {
 		t.Fatalf("Failed to create generator: %v", err)
 	}
@@ -134,7 +159,7 @@ func TestConcurrentGeneration(t *testing.T) {
 }
 
 func TestNoAmbiguousCharacters(t *testing.T) {
-	generator, err := NewGenerator(1)
+	generator, err := NewGenerator(1, WithClock(newFakeClock()))
 	if err != nil {
 		t.Fatalf("Failed to create generator: %v", err)
 	}
@@ -170,7 +195,7 @@ func isValidCharacter(char rune) bool {
 }
 
 func TestURLLength(t *testing.T) {
-	generator, err := NewGenerator(1)
+	generator, err := NewGenerator(1, WithClock(newFakeClock()))
 	if err != nil {
 		t.Fatalf("Failed to create generator: %v", err)
 	}
@@ -188,3 +213,191 @@ func TestURLLength(t *testing.T) {
 		}
 	}
 }
+
+func TestSequenceExhaustionSleeps(t *testing.T) {
+	clock := newFakeClock()
+	generator, err := NewGenerator(1, WithClock(clock))
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	seen := make(map[int64]bool)
+	for i := int64(0); i <= generator.layout.maxSequence()+1; i++ {
+		id, err := generator.NextID()
+		if err != nil {
+			t.Fatalf("NextID() unexpected error: %v", err)
+		}
+		if seen[id] {
+			t.Fatalf("Duplicate ID %d", id)
+		}
+		seen[id] = true
+	}
+
+	if clock.sleeps != 1 || clock.slept != time.Second {
+		t.Errorf("Expected one 1s sleep after exhausting the sequence, got %d sleeps totalling %v", clock.sleeps, clock.slept)
+	}
+}
+
+func TestSmallBackwardStepIsAbsorbed(t *testing.T) {
+	clock := newFakeClock()
+	generator, err := NewGenerator(1, WithClock(clock), WithMaxClockSkew(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	first, err := generator.NextID()
+	if err != nil {
+		t.Fatalf("NextID() unexpected error: %v", err)
+	}
+
+	// Step back 3ms: IDs keep coming from the last timestamp's sequence
+	clock.now = clock.now.Add(-3 * time.Millisecond)
+	seen := map[int64]bool{first: true}
+	for i := int64(0); i < generator.layout.maxSequence()*2; i++ {
+		id, err := generator.NextID()
+		if err != nil {
+			t.Fatalf("NextID() unexpected error after small backward step: %v", err)
+		}
+		if seen[id] || id < first {
+			t.Fatalf("ID %d is a duplicate or lower than the first ID %d", id, first)
+		}
+		seen[id] = true
+	}
+
+	// Running out of sequence waited for the clock to pass the last timestamp
+	if clock.slept < 3*time.Millisecond {
+		t.Errorf("Expected the generator to sleep until the clock caught up, slept %v", clock.slept)
+	}
+}
+
+func TestLargeBackwardStepFails(t *testing.T) {
+	clock := newFakeClock()
+	generator, err := NewGenerator(1, WithClock(clock), WithMaxClockSkew(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+
+	if _, err := generator.NextID(); err != nil {
+		t.Fatalf("NextID() unexpected error: %v", err)
+	}
+
+	clock.now = clock.now.Add(-time.Second)
+	if _, err := generator.NextID(); !errors.Is(err, ErrClockMovedBackwards) {
+		t.Errorf("NextID() error = %v, want ErrClockMovedBackwards", err)
+	}
+	if clock.sleeps != 0 {
+		t.Errorf("Expected no sleeping on a large backward step, got %d sleeps", clock.sleeps)
+	}
+}
+
+func TestMonotonicClockNeverGoesBack(t *testing.T) {
+	clock := monotonicClock{start: time.Now()}
+	last := clock.Now()
+	for i := 0; i < 1000; i++ {
+		now := clock.Now()
+		if now.Before(last) {
+			t.Fatalf("Clock went backwards from %v to %v", last, now)
+		}
+		last = now
+	}
+}
+
+func TestLayoutValidate(t *testing.T) {
+	if err := DefaultLayout().Validate(); err != nil {
+		t.Fatalf("DefaultLayout() is invalid: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Layout)
+	}{
+		{name: "millisecond timestamps in 7 characters", modify: func(l *Layout) { l.Tick = time.Millisecond; l.TimestampBits = 37 }},
+		{name: "more than 63 bits", modify: func(l *Layout) { l.TimestampBits = 52; l.Length = 11 }},
+		{name: "zero tick", modify: func(l *Layout) { l.Tick = 0 }},
+		{name: "zero length", modify: func(l *Layout) { l.Length = 0 }},
+		{name: "no epoch", modify: func(l *Layout) { l.Epoch = time.Time{} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout := DefaultLayout()
+			tt.modify(&layout)
+			if err := layout.Validate(); !errors.Is(err, ErrInvalidLayout) {
+				t.Errorf("Validate() error = %v, want ErrInvalidLayout", err)
+			}
+			if _, err := NewGenerator(1, WithLayout(layout)); err == nil {
+				t.Error("NewGenerator() accepted an invalid layout")
+			}
+		})
+	}
+
+	// The smallest length that fits is accepted
+	layout := DefaultLayout()
+	layout.Tick = time.Millisecond
+	layout.TimestampBits = 40
+	layout.Length = 9
+	if err := layout.Validate(); err != nil {
+		t.Errorf("Validate() unexpected error for 52 bits in 9 characters: %v", err)
+	}
+	layout.TimestampBits = 41
+	if err := layout.Validate(); err == nil {
+		t.Error("Validate() accepted 53 bits in 9 characters")
+	}
+}
+
+func TestCustomLayout(t *testing.T) {
+	clock := newFakeClock()
+	layout := Layout{
+		Epoch:         clock.now.Add(-time.Hour),
+		Tick:          time.Millisecond,
+		TimestampBits: 40,
+		WorkerBits:    10,
+		SequenceBits:  12,
+		Length:        11,
+	}
+	generator, err := NewGenerator(1023, WithClock(clock), WithLayout(layout))
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	if _, err := NewGenerator(1024, WithLayout(layout)); !errors.Is(err, ErrInvalidWorkerID) {
+		t.Errorf("Expected ErrInvalidWorkerID for worker 1024 with 10 worker bits, got %v", err)
+	}
+
+	code, err := generator.GenerateShortURL()
+	if err != nil {
+		t.Fatalf("GenerateShortURL() unexpected error: %v", err)
+	}
+	if len(code) != 11 || !generator.IsValidShortURL(code) {
+		t.Errorf("Expected a valid 11 character code, got %q", code)
+	}
+
+	id, err := codec.Base58.Decode(code)
+	if err != nil {
+		t.Fatalf("Decode() unexpected error: %v", err)
+	}
+	if got := id >> 22; got != uint64(time.Hour/time.Millisecond) {
+		t.Errorf("Expected timestamp %d ticks, got %d", time.Hour/time.Millisecond, got)
+	}
+	if got := (id >> 12) & 1023; got != 1023 {
+		t.Errorf("Expected worker ID 1023, got %d", got)
+	}
+}
+
+func TestTimestampOverflow(t *testing.T) {
+	clock := newFakeClock()
+	layout := DefaultLayout()
+	layout.Epoch = clock.now.Add(-layout.Tick << layout.TimestampBits)
+
+	generator, err := NewGenerator(1, WithClock(clock), WithLayout(layout))
+	if err != nil {
+		t.Fatalf("Failed to create generator: %v", err)
+	}
+	if _, err := generator.GenerateShortURL(); !errors.Is(err, ErrTimestampOverflow) {
+		t.Errorf("GenerateShortURL() error = %v, want ErrTimestampOverflow", err)
+	}
+
+	layout.Epoch = clock.now.Add(time.Hour)
+	generator, _ = NewGenerator(1, WithClock(clock), WithLayout(layout))
+	if _, err := generator.GenerateShortURL(); !errors.Is(err, ErrTimestampOverflow) {
+		t.Errorf("GenerateShortURL() before the epoch error = %v, want ErrTimestampOverflow", err)
+	}
+}
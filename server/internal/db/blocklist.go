@@ -0,0 +1,30 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dev4dreams/dev4url/internal/models"
+)
+
+// ListBlocklist returns every blocklist entry
+func (db *Database) ListBlocklist(ctx context.Context) ([]models.BlocklistEntry, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `SELECT kind, value FROM blocklist ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blocklist: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.BlocklistEntry
+	for rows.Next() {
+		var entry models.BlocklistEntry
+		if err := rows.Scan(&entry.Kind, &entry.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan blocklist entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/internal/tracing"
+	"github.com/lib/pq"
+)
+
+// ShortURLExists reports whether a short code is already taken, active or
+// not
+func (db *Database) ShortURLExists(ctx context.Context, code string) (bool, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	err := db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM urls WHERE short_url = $1)`, code,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check short code: %w", err)
+	}
+	return exists, nil
+}
+
+// NextCodeSequence returns the next value of the short code sequence
+func (db *Database) NextCodeSequence(ctx context.Context) (int64, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var n int64
+	if err := db.QueryRowContext(ctx, `SELECT nextval('short_code_seq')`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to read short code sequence: %w", err)
+	}
+	return n, nil
+}
+
+// ActiveShortURLs returns which of the given codes exist and are active, in
+// one query
+func (db *Database) ActiveShortURLs(ctx context.Context, codes []string) ([]string, error) {
+	ctx, span := startSpan(ctx, "db.active_short_urls", "SELECT")
+	defer span.End()
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT short_url FROM urls WHERE short_url = ANY($1) AND active = true`,
+		pq.Array(codes),
+	)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, fmt.Errorf("failed to look up short codes: %w", err)
+	}
+	defer rows.Close()
+
+	var found []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			tracing.RecordError(span, err)
+			return nil, fmt.Errorf("failed to read short code: %w", err)
+		}
+		found = append(found, code)
+	}
+	if err := rows.Err(); err != nil {
+		tracing.RecordError(span, err)
+		return nil, fmt.Errorf("failed to look up short codes: %w", err)
+	}
+	return found, nil
+}
+
+// addClicksBatch caps the keys per statement, well under Postgres' limit
+// of 65535 parameters
+const addClicksBatch = 1000
+
+// AddClicks adds click counts with one statement per batch of keys,
+// counting each code's total on its row and variant clicks in
+// link_variant_clicks. Keys are sorted so concurrent flushes from several
+// replicas lock rows in the same order. On error the counts not yet
+// written are returned.
+func (db *Database) AddClicks(ctx context.Context, counts map[models.ClickKey]int64) (map[models.ClickKey]int64, error) {
+	ctx, span := startSpan(ctx, "db.add_clicks", "UPDATE")
+	defer span.End()
+
+	keys := make([]models.ClickKey, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b models.ClickKey) int {
+		return cmp.Or(cmp.Compare(a.Code, b.Code), cmp.Compare(a.Variant, b.Variant))
+	})
+
+	for start := 0; start < len(keys); start += addClicksBatch {
+		batch := keys[start:min(start+addClicksBatch, len(keys))]
+		if err := db.addClicks(ctx, batch, counts); err != nil {
+			tracing.RecordError(span, err)
+			unwritten := make(map[models.ClickKey]int64, len(keys)-start)
+			for _, key := range keys[start:] {
+				unwritten[key] = counts[key]
+			}
+			return unwritten, fmt.Errorf("failed to add clicks: %w", err)
+		}
+	}
+	return nil, nil
+}
+
+func (db *Database) addClicks(ctx context.Context, keys []models.ClickKey, counts map[models.ClickKey]int64) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var values strings.Builder
+	args := make([]any, 0, 3*len(keys))
+	for i, key := range keys {
+		if i > 0 {
+			values.WriteString(", ")
+		}
+		fmt.Fprintf(&values, "($%d::text, $%d::text, $%d::bigint)", 3*i+1, 3*i+2, 3*i+3)
+		args = append(args, key.Code, key.Variant, counts[key])
+	}
+
+	// A code appears once per variant, so rows are updated with its total
+	_, err := db.ExecContext(ctx, `
+		WITH v(code, variant, n) AS (VALUES `+values.String()+`),
+		totals AS (
+			UPDATE urls
+			SET
+				clicks = urls.clicks + t.n,
+				last_accessed_at = NOW()
+			FROM (SELECT code, SUM(n)::bigint AS n FROM v GROUP BY code) AS t
+			WHERE urls.short_url = t.code
+		)
+		INSERT INTO link_variant_clicks (short_url, variant, clicks)
+		SELECT code, variant, n FROM v WHERE variant <> ''
+		ON CONFLICT (short_url, variant)
+		DO UPDATE SET clicks = link_variant_clicks.clicks + EXCLUDED.clicks`,
+		args...,
+	)
+	return err
+}
+
+// VariantClicks returns the clicks each variant of a short URL served,
+// including variants since removed
+func (db *Database) VariantClicks(ctx context.Context, shortURL string) (map[string]int64, error) {
+	ctx, span := startSpan(ctx, "db.variant_clicks", "SELECT")
+	defer span.End()
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT variant, clicks FROM link_variant_clicks WHERE short_url = $1`, shortURL)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, fmt.Errorf("failed to read variant clicks: %w", err)
+	}
+	defer rows.Close()
+
+	clicks := make(map[string]int64)
+	for rows.Next() {
+		var variant string
+		var n int64
+		if err := rows.Scan(&variant, &n); err != nil {
+			tracing.RecordError(span, err)
+			return nil, fmt.Errorf("failed to read variant clicks: %w", err)
+		}
+		clicks[variant] = n
+	}
+	if err := rows.Err(); err != nil {
+		tracing.RecordError(span, err)
+		return nil, fmt.Errorf("failed to read variant clicks: %w", err)
+	}
+	return clicks, nil
+}
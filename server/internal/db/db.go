@@ -1,23 +1,31 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
+	"time"
 
 	"github.com/dev4dreams/dev4url/internal/config"
 	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/internal/tracing"
 	_ "github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // DatabaseInterface defines the behavior for database operations
 type DatabaseInterface interface {
-	CreateURL(payload *models.CreateUrlPayload) (*models.URLResponse, error)
+	CreateURL(ctx context.Context, payload *models.CreateUrlPayload) (*models.URLResponse, error)
+	ResolveLink(ctx context.Context, shortURL string) (*models.ResolvedLink, error)
 	Close() error
 	VerifyConnection() error
 }
 
 type Database struct {
 	*sql.DB
+	statementTimeout time.Duration
 }
 
 // Config holds database configuration
@@ -31,58 +39,199 @@ type Config struct {
 }
 
 // New creates a new database connection
-func New(config *config.DatabaseConfig) (*Database, error) {
-	connStr := config.URL + "?sslmode=require&pool_mode=transaction&statement_cache_mode=describe"
+func New(cfg *config.DatabaseConfig) (*Database, error) {
+	connStr, err := connectionString(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, fmt.Errorf("error opening database: %w", err)
 	}
 
+	// Apply connection pool settings
+	db.SetMaxOpenConns(cfg.MaxConnections)
+	db.SetMaxIdleConns(cfg.MinConnections)
+	db.SetConnMaxLifetime(cfg.MaxConnLifetime)
+	db.SetConnMaxIdleTime(cfg.MaxConnIdleTime)
+
 	// Test the connection
 	if err = db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("error connecting to the database: %w", err)
 	}
 
-	return &Database{db}, nil
+	return &Database{DB: db, statementTimeout: cfg.StatementTimeout}, nil
+}
+
+// connectionString adapts the configured URL to the connection mode
+func connectionString(cfg *config.DatabaseConfig) (string, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid database URL: %w", err)
+	}
+
+	query := u.Query()
+	if cfg.SSLMode != "" {
+		query.Set("sslmode", cfg.SSLMode)
+	}
+
+	switch cfg.Mode {
+	case config.DatabaseModePooler:
+		// A transaction pooler may hand consecutive round trips to different
+		// server connections, so statements must not be prepared server-side.
+		// With binary_parameters lib/pq sends parse, bind and execute together.
+		query.Set("binary_parameters", "yes")
+	case config.DatabaseModeDirect:
+		// Poolers reject startup options, so the server-side timeout is only
+		// set on direct connections; withTimeout covers both modes
+		if cfg.StatementTimeout > 0 {
+			query.Set("options", fmt.Sprintf("-c statement_timeout=%d", cfg.StatementTimeout.Milliseconds()))
+		}
+	default:
+		return "", fmt.Errorf("unknown database mode %q", cfg.Mode)
+	}
+
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// withTimeout bounds a single query by the configured statement timeout
+func (db *Database) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.statementTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, db.statementTimeout)
+}
+
+// startSpan starts a client span describing a query against the urls table
+func startSpan(ctx context.Context, name, operation string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name,
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation", operation),
+		attribute.String("db.sql.table", "urls"),
+	)
 }
 
 // CreateURL inserts a new URL record into the database
-func (db *Database) CreateURL(url *models.CreateUrlPayload) (*models.URLResponse, error) {
-	var response models.URLResponse
+func (db *Database) CreateURL(ctx context.Context, url *models.CreateUrlPayload) (*models.URLResponse, error) {
+	ctx, span := startSpan(ctx, "db.create_url", "INSERT")
+	defer span.End()
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
 
 	query := `
         INSERT INTO urls (
             short_url,
             original_url,
-            custom_url
+            custom_url,
+            interstitial,
+            utm,
+            forward_query,
+            targets,
+            variants,
+            sticky_variants,
+            app_links,
+            active_from,
+            active_until,
+            before_url,
+            after_url
         ) VALUES (
-            $1, $2, $3
+            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
         )
-        RETURNING id, created_at, short_url, original_url,
-                  custom_url, clicks, active, updated_at`
+        RETURNING ` + urlColumns
 
-	err := db.QueryRow(
+	utm, err := utmValue(url.UTM)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := targetsValue(url.Targets)
+	if err != nil {
+		return nil, err
+	}
+	variants, err := variantsValue(url.Variants)
+	if err != nil {
+		return nil, err
+	}
+	appLinks, err := appLinksValue(url.AppLinks)
+	if err != nil {
+		return nil, err
+	}
+	activeFrom, activeUntil, beforeURL, afterURL := scheduleValues(url.Schedule)
+	response, err := scanURL(db.QueryRowContext(
+		ctx,
 		query,
 		url.ShortenUrl,
 		url.OriginalUrl,
 		url.CustomUrl,
-	).Scan(
-		&response.ID,
-		&response.CreatedAt,
-		&response.ShortURL,
-		&response.OriginalURL,
-		&response.CustomURL,
-		&response.Clicks,
-		&response.Active,
-		&response.UpdatedAt,
-	)
-
+		url.Interstitial,
+		utm,
+		url.ForwardQuery,
+		targets,
+		variants,
+		url.StickyVariants,
+		appLinks,
+		activeFrom,
+		activeUntil,
+		beforeURL,
+		afterURL,
+	))
 	if err != nil {
+		tracing.RecordError(span, err)
 		return nil, fmt.Errorf("failed to create URL: %w", err)
 	}
 
-	return &response, nil
+	return response, nil
+}
+
+// ResolveLink returns what a redirect needs to know about an active short
+// URL. It returns sql.ErrNoRows when the code is unknown or inactive. The
+// schedule is returned rather than applied, so cached links go live and
+// expire on time. Clicks are counted separately, see AddClicks.
+func (db *Database) ResolveLink(ctx context.Context, shortURL string) (*models.ResolvedLink, error) {
+	ctx, span := startSpan(ctx, "db.resolve_link", "SELECT")
+	defer span.End()
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var link models.ResolvedLink
+	var utm, targets, variants, appLinks []byte
+	var activeFrom, activeUntil sql.NullTime
+	var beforeURL, afterURL sql.NullString
+	err := db.QueryRowContext(ctx, `
+		SELECT original_url, interstitial, utm, forward_query, targets, variants, sticky_variants, app_links,
+			active_from, active_until, before_url, after_url
+		FROM urls
+		WHERE short_url = $1 AND active = true`,
+		shortURL,
+	).Scan(&link.OriginalURL, &link.Interstitial, &utm, &link.ForwardQuery, &targets, &variants,
+		&link.StickyVariants, &appLinks, &activeFrom, &activeUntil, &beforeURL, &afterURL)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			tracing.RecordError(span, err)
+		}
+		return nil, err
+	}
+
+	if link.UTM, err = scanUTM(utm); err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	if link.Targets, err = scanTargets(targets); err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	if link.Variants, err = scanVariants(variants); err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	if link.AppLinks, err = scanAppLinks(appLinks); err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	link.Schedule = scanSchedule(activeFrom, activeUntil, beforeURL, afterURL)
+	return &link, nil
 }
 
 // VerifyConnection checks if the database connection is still alive
@@ -0,0 +1,325 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/internal/tracing"
+)
+
+// urlColumns are scanned by scanURL
+const urlColumns = `id, created_at, short_url, original_url,
+                  custom_url, clicks, active, interstitial, utm, forward_query, targets, variants, sticky_variants, app_links,
+                  active_from, active_until, before_url, after_url, updated_at,
+                  meta_title, meta_description, meta_image, meta_favicon, meta_fetched_at`
+
+func scanURL(row *sql.Row) (*models.URLResponse, error) {
+	var response models.URLResponse
+	var title, description, image, favicon sql.NullString
+	var fetchedAt sql.NullTime
+	var utm, targets, variants, appLinks []byte
+	var activeFrom, activeUntil sql.NullTime
+	var beforeURL, afterURL sql.NullString
+	err := row.Scan(
+		&response.ID,
+		&response.CreatedAt,
+		&response.ShortURL,
+		&response.OriginalURL,
+		&response.CustomURL,
+		&response.Clicks,
+		&response.Active,
+		&response.Interstitial,
+		&utm,
+		&response.ForwardQuery,
+		&targets,
+		&variants,
+		&response.StickyVariants,
+		&appLinks,
+		&activeFrom,
+		&activeUntil,
+		&beforeURL,
+		&afterURL,
+		&response.UpdatedAt,
+		&title,
+		&description,
+		&image,
+		&favicon,
+		&fetchedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.UTM, err = scanUTM(utm); err != nil {
+		return nil, err
+	}
+	if response.Targets, err = scanTargets(targets); err != nil {
+		return nil, err
+	}
+	if response.Variants, err = scanVariants(variants); err != nil {
+		return nil, err
+	}
+	if response.AppLinks, err = scanAppLinks(appLinks); err != nil {
+		return nil, err
+	}
+	response.Schedule = scanSchedule(activeFrom, activeUntil, beforeURL, afterURL)
+	if fetchedAt.Valid {
+		response.Metadata = &models.LinkMetadata{
+			Title:       title.String,
+			Description: description.String,
+			Image:       image.String,
+			Favicon:     favicon.String,
+			FetchedAt:   fetchedAt.Time,
+		}
+	}
+	return &response, nil
+}
+
+// utmValue stores UTM parameters as JSON, or NULL for none
+func utmValue(utm *models.UTMParams) (any, error) {
+	if utm == nil || *utm == (models.UTMParams{}) {
+		return nil, nil
+	}
+	data, err := json.Marshal(utm)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func scanUTM(data []byte) (*models.UTMParams, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var utm models.UTMParams
+	if err := json.Unmarshal(data, &utm); err != nil {
+		return nil, fmt.Errorf("invalid utm column: %w", err)
+	}
+	return &utm, nil
+}
+
+// targetsValue stores targeting rules as JSON, or NULL for none
+func targetsValue(targets []models.TargetRule) (any, error) {
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(targets)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func scanTargets(data []byte) ([]models.TargetRule, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var targets []models.TargetRule
+	if err := json.Unmarshal(data, &targets); err != nil {
+		return nil, fmt.Errorf("invalid targets column: %w", err)
+	}
+	return targets, nil
+}
+
+// variantsValue stores variants as JSON, or NULL for none
+func variantsValue(variants []models.Variant) (any, error) {
+	if len(variants) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(variants)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func scanVariants(data []byte) ([]models.Variant, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var variants []models.Variant
+	if err := json.Unmarshal(data, &variants); err != nil {
+		return nil, fmt.Errorf("invalid variants column: %w", err)
+	}
+	return variants, nil
+}
+
+// appLinksValue stores app links as JSON, or NULL for none
+func appLinksValue(links *models.AppLinks) (any, error) {
+	if links == nil || *links == (models.AppLinks{}) {
+		return nil, nil
+	}
+	data, err := json.Marshal(links)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func scanAppLinks(data []byte) (*models.AppLinks, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var links models.AppLinks
+	if err := json.Unmarshal(data, &links); err != nil {
+		return nil, fmt.Errorf("invalid app_links column: %w", err)
+	}
+	return &links, nil
+}
+
+// scheduleValues stores a schedule in its four columns, NULL where unset
+func scheduleValues(schedule *models.Schedule) (activeFrom, activeUntil, beforeURL, afterURL any) {
+	if schedule == nil {
+		return nil, nil, nil, nil
+	}
+	if schedule.ActiveFrom != nil {
+		activeFrom = *schedule.ActiveFrom
+	}
+	if schedule.ActiveUntil != nil {
+		activeUntil = *schedule.ActiveUntil
+	}
+	if schedule.BeforeURL != "" {
+		beforeURL = schedule.BeforeURL
+	}
+	if schedule.AfterURL != "" {
+		afterURL = schedule.AfterURL
+	}
+	return activeFrom, activeUntil, beforeURL, afterURL
+}
+
+// scanSchedule returns nil for a link without a schedule
+func scanSchedule(activeFrom, activeUntil sql.NullTime, beforeURL, afterURL sql.NullString) *models.Schedule {
+	if !activeFrom.Valid && !activeUntil.Valid && !beforeURL.Valid && !afterURL.Valid {
+		return nil
+	}
+	schedule := &models.Schedule{BeforeURL: beforeURL.String, AfterURL: afterURL.String}
+	if activeFrom.Valid {
+		schedule.ActiveFrom = &activeFrom.Time
+	}
+	if activeUntil.Valid {
+		schedule.ActiveUntil = &activeUntil.Time
+	}
+	return schedule
+}
+
+// GetURL returns a short URL record, active or not. It returns
+// sql.ErrNoRows when the code is unknown.
+func (db *Database) GetURL(ctx context.Context, shortURL string) (*models.URLResponse, error) {
+	ctx, span := startSpan(ctx, "db.get_url", "SELECT")
+	defer span.End()
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	response, err := scanURL(db.QueryRowContext(ctx,
+		`SELECT `+urlColumns+` FROM urls WHERE short_url = $1`, shortURL))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			tracing.RecordError(span, err)
+		}
+		return nil, err
+	}
+	return response, nil
+}
+
+// UpdateURL changes the destination and settings of a short URL, leaving
+// nil fields alone; empty UTM parameters, targets, variants, app links or
+// schedule clear them. A new destination clears the metadata fetched for
+// the old one. It returns sql.ErrNoRows
+// when the code is unknown.
+func (db *Database) UpdateURL(ctx context.Context, shortURL string, update *models.UpdateUrlRequest) (*models.URLResponse, error) {
+	ctx, span := startSpan(ctx, "db.update_url", "UPDATE")
+	defer span.End()
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	utm, err := utmValue(update.UTM)
+	if err != nil {
+		return nil, err
+	}
+	appLinks, err := appLinksValue(update.AppLinks)
+	if err != nil {
+		return nil, err
+	}
+	activeFrom, activeUntil, beforeURL, afterURL := scheduleValues(update.Schedule)
+	var targets, variants any
+	if update.Targets != nil {
+		if targets, err = targetsValue(*update.Targets); err != nil {
+			return nil, err
+		}
+	}
+	if update.Variants != nil {
+		if variants, err = variantsValue(*update.Variants); err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := scanURL(db.QueryRowContext(ctx, `
+		UPDATE urls
+		SET
+			original_url = COALESCE($2, original_url),
+			active = COALESCE($3, active),
+			interstitial = COALESCE($4, interstitial),
+			utm = CASE WHEN $5::boolean THEN $6::jsonb ELSE utm END,
+			forward_query = COALESCE($7, forward_query),
+			targets = CASE WHEN $8::boolean THEN $9::jsonb ELSE targets END,
+			variants = CASE WHEN $10::boolean THEN $11::jsonb ELSE variants END,
+			sticky_variants = COALESCE($12, sticky_variants),
+			app_links = CASE WHEN $13::boolean THEN $14::jsonb ELSE app_links END,
+			active_from = CASE WHEN $15::boolean THEN $16::timestamptz ELSE active_from END,
+			active_until = CASE WHEN $15::boolean THEN $17::timestamptz ELSE active_until END,
+			before_url = CASE WHEN $15::boolean THEN $18::text ELSE before_url END,
+			after_url = CASE WHEN $15::boolean THEN $19::text ELSE after_url END,
+			meta_title = CASE WHEN $2::text IS NULL OR $2 = original_url THEN meta_title END,
+			meta_description = CASE WHEN $2::text IS NULL OR $2 = original_url THEN meta_description END,
+			meta_image = CASE WHEN $2::text IS NULL OR $2 = original_url THEN meta_image END,
+			meta_favicon = CASE WHEN $2::text IS NULL OR $2 = original_url THEN meta_favicon END,
+			meta_fetched_at = CASE WHEN $2::text IS NULL OR $2 = original_url THEN meta_fetched_at END,
+			updated_at = NOW()
+		WHERE short_url = $1
+		RETURNING `+urlColumns,
+		shortURL, update.OriginalURL, update.Active, update.Interstitial,
+		update.UTM != nil, utm, update.ForwardQuery,
+		update.Targets != nil, targets,
+		update.Variants != nil, variants, update.StickyVariants,
+		update.AppLinks != nil, appLinks,
+		update.Schedule != nil, activeFrom, activeUntil, beforeURL, afterURL,
+	))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			tracing.RecordError(span, err)
+			return nil, fmt.Errorf("failed to update URL: %w", err)
+		}
+		return nil, err
+	}
+	return response, nil
+}
+
+// SetLinkMetadata stores the metadata fetched from destination on a short
+// URL. It is dropped when the link has been pointed elsewhere since the
+// fetch started.
+func (db *Database) SetLinkMetadata(ctx context.Context, shortURL, destination string, meta *models.LinkMetadata) error {
+	ctx, span := startSpan(ctx, "db.set_link_metadata", "UPDATE")
+	defer span.End()
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE urls
+		SET
+			meta_title = $2,
+			meta_description = $3,
+			meta_image = $4,
+			meta_favicon = $5,
+			meta_fetched_at = $6
+		WHERE short_url = $1 AND original_url = $7`,
+		shortURL, meta.Title, meta.Description, meta.Image, meta.Favicon, meta.FetchedAt, destination,
+	)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return fmt.Errorf("failed to store link metadata: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+-- Baseline schema. Uses IF NOT EXISTS so databases created before
+-- migrations were tracked are adopted as-is.
+CREATE TABLE IF NOT EXISTS urls (
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    short_url        VARCHAR(32) NOT NULL UNIQUE,
+    original_url     TEXT NOT NULL,
+    custom_url       VARCHAR(64),
+    clicks           BIGINT NOT NULL DEFAULT 0,
+    active           BOOLEAN NOT NULL DEFAULT TRUE,
+    last_accessed_at TIMESTAMPTZ
+);
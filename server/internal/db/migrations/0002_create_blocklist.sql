@@ -0,0 +1,10 @@
+-- Blocklist entries managed by Trust & Safety. Picked up by the validator
+-- rules reloader without a deploy.
+CREATE TABLE IF NOT EXISTS blocklist (
+    id         BIGSERIAL PRIMARY KEY,
+    kind       VARCHAR(16) NOT NULL CHECK (kind IN ('domain', 'pattern')),
+    value      TEXT NOT NULL,
+    reason     TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (kind, value)
+);
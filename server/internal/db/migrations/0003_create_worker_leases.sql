@@ -0,0 +1,9 @@
+-- Snowflake worker IDs leased by running instances. A lease whose
+-- expires_at has passed may be taken over, so crashed instances free
+-- their ID once the TTL runs out.
+CREATE TABLE IF NOT EXISTS worker_leases (
+    worker_id   INTEGER PRIMARY KEY,
+    holder      TEXT NOT NULL,
+    acquired_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at  TIMESTAMPTZ NOT NULL
+);
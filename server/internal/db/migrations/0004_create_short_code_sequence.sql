@@ -0,0 +1,3 @@
+-- Counter behind the feistel and hashids short code strategies. Values are
+-- never reused, so codes derived from them stay unique across replicas.
+CREATE SEQUENCE IF NOT EXISTS short_code_seq START WITH 1;
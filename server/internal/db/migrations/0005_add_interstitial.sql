@@ -0,0 +1,3 @@
+-- Links whose creator wants every visitor to see the preview page before
+-- continuing to the destination.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS interstitial BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,8 @@
+-- Destination page metadata, fetched in the background after creation and
+-- served to link preview bots. All NULL until a fetch succeeds.
+ALTER TABLE urls
+    ADD COLUMN IF NOT EXISTS meta_title       TEXT,
+    ADD COLUMN IF NOT EXISTS meta_description TEXT,
+    ADD COLUMN IF NOT EXISTS meta_image       TEXT,
+    ADD COLUMN IF NOT EXISTS meta_favicon     TEXT,
+    ADD COLUMN IF NOT EXISTS meta_fetched_at  TIMESTAMPTZ;
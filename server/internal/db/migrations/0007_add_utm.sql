@@ -0,0 +1,5 @@
+-- Default UTM parameters added to the destination on redirect, and whether
+-- the visitor's query string is forwarded to it.
+ALTER TABLE urls
+    ADD COLUMN IF NOT EXISTS utm           JSONB,
+    ADD COLUMN IF NOT EXISTS forward_query BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,4 @@
+-- Ordered targeting rules sending visitors to alternate destinations by
+-- country, OS, device or language.
+ALTER TABLE urls
+    ADD COLUMN IF NOT EXISTS targets JSONB;
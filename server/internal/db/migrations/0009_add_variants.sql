@@ -0,0 +1,12 @@
+-- Weighted destinations split between visitors, and the clicks each
+-- variant served.
+ALTER TABLE urls
+    ADD COLUMN IF NOT EXISTS variants        JSONB,
+    ADD COLUMN IF NOT EXISTS sticky_variants BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS link_variant_clicks (
+    short_url VARCHAR(32) NOT NULL,
+    variant   VARCHAR(64) NOT NULL,
+    clicks    BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (short_url, variant)
+);
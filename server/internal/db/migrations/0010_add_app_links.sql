@@ -0,0 +1,3 @@
+-- App deep links tried on mobile before the web destination.
+ALTER TABLE urls
+    ADD COLUMN IF NOT EXISTS app_links JSONB;
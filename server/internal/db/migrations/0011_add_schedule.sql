@@ -0,0 +1,8 @@
+-- Scheduled activation windows. Outside its window a link sends visitors
+-- to the before or after URL, if set; active still turns it off at any
+-- time.
+ALTER TABLE urls
+    ADD COLUMN IF NOT EXISTS active_from  TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS active_until TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS before_url   TEXT,
+    ADD COLUMN IF NOT EXISTS after_url    TEXT;
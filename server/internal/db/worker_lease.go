@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AcquireWorkerLease claims the lowest worker ID in [0, maxID] that is free
+// or whose lease expired. ok is false when every ID is held, or when
+// another instance claimed the same ID first; callers retry.
+func (db *Database) AcquireWorkerLease(ctx context.Context, holder string, maxID int64, ttl time.Duration) (workerID int64, ok bool, err error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	// Two instances racing for the same ID both hit the conflict clause;
+	// only the first sees an expired row, the other gets no row back
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO worker_leases (worker_id, holder, expires_at)
+		SELECT id, $1, NOW() + make_interval(secs => $2)
+		FROM generate_series(0, $3::integer) AS id
+		WHERE NOT EXISTS (
+			SELECT 1 FROM worker_leases l WHERE l.worker_id = id AND l.expires_at > NOW()
+		)
+		ORDER BY id
+		LIMIT 1
+		ON CONFLICT (worker_id) DO UPDATE
+			SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at, acquired_at = NOW()
+			WHERE worker_leases.expires_at <= NOW()
+		RETURNING worker_id`,
+		holder, ttl.Seconds(), maxID,
+	).Scan(&workerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to acquire worker lease: %w", err)
+	}
+	return workerID, true, nil
+}
+
+// RenewWorkerLease extends a lease held by holder. ok is false when the
+// lease is no longer held by holder.
+func (db *Database) RenewWorkerLease(ctx context.Context, workerID int64, holder string, ttl time.Duration) (ok bool, err error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE worker_leases SET expires_at = NOW() + make_interval(secs => $3)
+		WHERE worker_id = $1 AND holder = $2 AND expires_at > NOW()`,
+		workerID, holder, ttl.Seconds(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to renew worker lease: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to renew worker lease: %w", err)
+	}
+	return rows == 1, nil
+}
+
+// ReleaseWorkerLease frees a lease held by holder so the ID can be reused
+// straight away
+func (db *Database) ReleaseWorkerLease(ctx context.Context, workerID int64, holder string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx,
+		`DELETE FROM worker_leases WHERE worker_id = $1 AND holder = $2`,
+		workerID, holder,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release worker lease: %w", err)
+	}
+	return nil
+}
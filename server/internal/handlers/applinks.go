@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dev4dreams/dev4url/internal/logging"
+	"github.com/dev4dreams/dev4url/internal/metrics"
+	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/internal/services/safebrowsing"
+	"github.com/dev4dreams/dev4url/internal/services/targeting"
+	"github.com/dev4dreams/dev4url/internal/utils"
+)
+
+// appLinkFallbackDelay is how long the app link page waits for the app to
+// open before going on to the web
+const appLinkFallbackDelay = 1500
+
+type appLinkPage struct {
+	AppURL   template.URL
+	Fallback string
+	Nonce    string
+	Delay    int
+}
+
+// checkAppLinks validates app links given at creation or update. Web URLs,
+// the fallback always, go through the same checks as the original URL;
+// custom scheme URLs must use an allowlisted scheme. It answers like
+// checkTargets.
+func checkAppLinks(
+	w http.ResponseWriter,
+	r *http.Request,
+	validator utils.URLValidatorInterface,
+	safeBrowsing safebrowsing.SafeBrowsingChecker,
+	appValidator *utils.AppURLValidator,
+	links *models.AppLinks,
+	logger *slog.Logger,
+) string {
+	if links == nil {
+		return ""
+	}
+
+	var web []string
+	for _, p := range [][2]string{{"ios", links.IOS}, {"android", links.Android}} {
+		switch {
+		case p[1] == "":
+		case isWebURL(p[1]):
+			web = append(web, p[1])
+		default:
+			if err := appValidator.ValidateAppURL(p[1]); err != nil {
+				http.Error(w, fmt.Sprintf("app_links.%s: %v", p[0], err), http.StatusBadRequest)
+				return metrics.OutcomeValidation
+			}
+		}
+	}
+	if links.Fallback != "" {
+		web = append(web, links.Fallback)
+	}
+	return checkDestinations(w, r, validator, safeBrowsing, "app_links", web, logger)
+}
+
+// isWebURL reports whether rawURL is meant for a browser rather than an app
+func isWebURL(rawURL string) bool {
+	scheme, _, _ := strings.Cut(rawURL, ":")
+	scheme = strings.ToLower(scheme)
+	return scheme == "http" || scheme == "https"
+}
+
+// appLinkFor returns the app link for the platform of the visitor making r,
+// or "" when the link has none for it
+func appLinkFor(r *http.Request, links *models.AppLinks) string {
+	if links == nil {
+		return ""
+	}
+	os, _ := targeting.ParseUserAgent(r.UserAgent())
+	switch os {
+	case targeting.OSIOS:
+		return links.IOS
+	case targeting.OSAndroid:
+		return links.Android
+	}
+	return ""
+}
+
+// renderAppLink sends the visitor to their app. A universal link or App
+// Link is a plain redirect, the system opens the app or the page. A custom
+// scheme gets a page trying the app and moving on to fallback when nothing
+// took over.
+func (h *RedirectHandler) renderAppLink(w http.ResponseWriter, r *http.Request, appURL, fallback string) {
+	if isWebURL(appURL) {
+		http.Redirect(w, r, appURL, http.StatusFound)
+		return
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		// Without a script the page could only ever fall back
+		logging.FromContext(r.Context(), h.logger).Error("Failed to generate page nonce", slog.Any("error", err))
+		http.Redirect(w, r, fallback, http.StatusFound)
+		return
+	}
+	page := appLinkPage{
+		// Validated against the scheme allowlist at creation
+		AppURL:   template.URL(appURL),
+		Fallback: fallback,
+		Nonce:    base64.StdEncoding.EncodeToString(nonce),
+		Delay:    appLinkFallbackDelay,
+	}
+	h.renderPageWithCSP(w, r, http.StatusOK, "app_link", page,
+		fmt.Sprintf("default-src 'none'; style-src 'unsafe-inline'; script-src 'nonce-%s'; frame-ancestors 'none'", page.Nonce))
+}
+
+// AndroidApp is an app allowed to open short URLs as Android App Links
+type AndroidApp struct {
+	PackageName  string
+	Fingerprints []string
+}
+
+// AppAssociationHandler serves the files through which iOS and Android let
+// apps open short URLs directly
+type AppAssociationHandler struct {
+	appleAppSiteAssociation []byte
+	assetLinks              []byte
+}
+
+// NewAppAssociationHandler builds both files once. A platform without
+// apps gets 404 for its file.
+func NewAppAssociationHandler(appleAppIDs []string, androidApps []AndroidApp) (*AppAssociationHandler, error) {
+	h := &AppAssociationHandler{}
+
+	if len(appleAppIDs) > 0 {
+		type component map[string]any
+		data, err := json.Marshal(map[string]any{
+			"applinks": map[string]any{
+				"apps": []string{},
+				"details": []map[string]any{{
+					"appIDs": appleAppIDs,
+					// Previews and QR codes stay in the browser
+					"components": []component{
+						{"/": "/*+", "exclude": true},
+						{"/": "/*/qr", "exclude": true},
+						{"/": "/*", "?": map[string]string{"preview": "*"}, "exclude": true},
+						{"/": "/*"},
+					},
+				}},
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("encoding apple-app-site-association: %w", err)
+		}
+		h.appleAppSiteAssociation = data
+	}
+
+	if len(androidApps) > 0 {
+		type target struct {
+			Namespace    string   `json:"namespace"`
+			PackageName  string   `json:"package_name"`
+			Fingerprints []string `json:"sha256_cert_fingerprints"`
+		}
+		type statement struct {
+			Relation []string `json:"relation"`
+			Target   target   `json:"target"`
+		}
+		statements := make([]statement, len(androidApps))
+		for i, app := range androidApps {
+			statements[i] = statement{
+				Relation: []string{"delegate_permission/common.handle_all_urls"},
+				Target: target{
+					Namespace:   "android_app",
+					PackageName: app.PackageName,
+					// Android compares the upper case form
+					Fingerprints: upper(app.Fingerprints),
+				},
+			}
+		}
+		data, err := json.Marshal(statements)
+		if err != nil {
+			return nil, fmt.Errorf("encoding assetlinks.json: %w", err)
+		}
+		h.assetLinks = data
+	}
+
+	return h, nil
+}
+
+// AppleAppSiteAssociation serves GET /.well-known/apple-app-site-association
+func (h *AppAssociationHandler) AppleAppSiteAssociation(w http.ResponseWriter, r *http.Request) {
+	serveAssociation(w, r, h.appleAppSiteAssociation)
+}
+
+// AssetLinks serves GET /.well-known/assetlinks.json
+func (h *AppAssociationHandler) AssetLinks(w http.ResponseWriter, r *http.Request) {
+	serveAssociation(w, r, h.assetLinks)
+}
+
+func upper(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToUpper(v)
+	}
+	return out
+}
+
+func serveAssociation(w http.ResponseWriter, r *http.Request, data []byte) {
+	if data == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(data)
+}
+
+// appFallback is where a visitor without the app goes
+func appFallback(links *models.AppLinks, destination string, utm *models.UTMParams, forwardQuery bool, incoming url.Values) string {
+	if links != nil && links.Fallback != "" {
+		destination = links.Fallback
+	}
+	return destinationURL(destination, utm, forwardQuery, incoming)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dev4dreams/dev4url/internal/core"
+	"github.com/dev4dreams/dev4url/internal/models"
+)
+
+const (
+	iPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) Mobile/15E148"
+	androidUA = "Mozilla/5.0 (Linux; Android 14; Pixel 8) Mobile Safari/537.36"
+	desktopUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64)"
+)
+
+func TestVisitAppLink(t *testing.T) {
+	link := &staticLink{
+		OriginalURL: "https://example.com/item/42",
+		AppLinks: &models.AppLinks{
+			IOS:      "myapp://item/42",
+			Android:  "https://app.example.com/item/42",
+			Fallback: "https://example.com/get-the-app",
+		},
+	}
+	h := NewRedirectHandler(nil, link, discardClicks{}, nil, core.CodeFormat{Length: 7}, nil, slog.Default())
+
+	visit := func(ua string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/abc1234", nil)
+		r.SetPathValue("code", "abc1234")
+		r.Header.Set("User-Agent", ua)
+		rec := httptest.NewRecorder()
+		h.Visit(rec, r)
+		return rec
+	}
+
+	rec := visit(iPhoneUA)
+	body := rec.Body.String()
+	csp := rec.Header().Get("Content-Security-Policy")
+	if rec.Code != http.StatusOK || !strings.Contains(body, `href="myapp://item/42"`) {
+		t.Fatalf("Expected the app link page, got %d: %s", rec.Code, body)
+	}
+	if !strings.Contains(body, "https://example.com/get-the-app") {
+		t.Error("Expected the page to fall back to the fallback URL")
+	}
+	if !strings.Contains(csp, "script-src 'nonce-") || !strings.Contains(body, `<script nonce="`) {
+		t.Errorf("Expected a nonce for the page script, got CSP %q", csp)
+	}
+
+	// App Links are opened by the system from a plain redirect
+	if rec := visit(androidUA); rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://app.example.com/item/42" {
+		t.Errorf("Expected a redirect to the App Link, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := visit(desktopUA); rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://example.com/item/42" {
+		t.Errorf("Expected desktop visitors at the destination, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestHandleRedirectAppLink(t *testing.T) {
+	link := &staticLink{
+		OriginalURL: "https://example.com/item/42",
+		UTM:         &models.UTMParams{Source: "app"},
+		AppLinks:    &models.AppLinks{IOS: "myapp://item/42"},
+	}
+	h := NewRedirectHandler(nil, link, discardClicks{}, nil, core.CodeFormat{Length: 7}, nil, slog.Default())
+
+	r := httptest.NewRequest(http.MethodPost, "/shortUrl/get", strings.NewReader(`{"shortenUrl":"abc1234"}`))
+	r.Header.Set("User-Agent", iPhoneUA)
+	rec := httptest.NewRecorder()
+	h.HandleRedirect(rec, r)
+
+	var response models.GetOriginalUrlResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.AppURL != "myapp://item/42" || response.OriginalURL != "https://example.com/item/42?utm_source=app" {
+		t.Errorf("Expected the app URL and the web fallback, got %+v", response)
+	}
+}
+
+func TestAppAssociationFiles(t *testing.T) {
+	h, err := NewAppAssociationHandler([]string{"ABCDE12345.com.example.app"}, []AndroidApp{
+		{PackageName: "com.example.app", Fingerprints: []string{"ab:cd"}},
+	})
+	if err != nil {
+		t.Fatalf("NewAppAssociationHandler() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.AppleAppSiteAssociation(rec, httptest.NewRequest(http.MethodGet, "/.well-known/apple-app-site-association", nil))
+	var aasa struct {
+		AppLinks struct {
+			Details []struct {
+				AppIDs []string `json:"appIDs"`
+			} `json:"details"`
+		} `json:"applinks"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&aasa); err != nil {
+		t.Fatalf("Failed to decode apple-app-site-association: %v", err)
+	}
+	if rec.Header().Get("Content-Type") != "application/json" ||
+		len(aasa.AppLinks.Details) != 1 || aasa.AppLinks.Details[0].AppIDs[0] != "ABCDE12345.com.example.app" {
+		t.Errorf("Unexpected apple-app-site-association %+v", aasa)
+	}
+
+	rec = httptest.NewRecorder()
+	h.AssetLinks(rec, httptest.NewRequest(http.MethodGet, "/.well-known/assetlinks.json", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `"package_name":"com.example.app"`) ||
+		!strings.Contains(body, `"sha256_cert_fingerprints":["AB:CD"]`) {
+		t.Errorf("Unexpected assetlinks.json %s", body)
+	}
+
+	none, _ := NewAppAssociationHandler(nil, nil)
+	rec = httptest.NewRecorder()
+	none.AssetLinks(rec, httptest.NewRequest(http.MethodGet, "/.well-known/assetlinks.json", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without apps, got %d", rec.Code)
+	}
+}
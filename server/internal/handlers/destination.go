@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dev4dreams/dev4url/internal/logging"
+	"github.com/dev4dreams/dev4url/internal/metrics"
+	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/internal/services/safebrowsing"
+	"github.com/dev4dreams/dev4url/internal/services/targeting"
+	"github.com/dev4dreams/dev4url/internal/utils"
+)
+
+// maxUTMLength bounds each UTM parameter value
+const maxUTMLength = 200
+
+// utmPairs lists a link's UTM parameters as query keys and values
+func utmPairs(utm *models.UTMParams) [][2]string {
+	if utm == nil {
+		return nil
+	}
+	var pairs [][2]string
+	for _, p := range [][2]string{
+		{"utm_source", utm.Source},
+		{"utm_medium", utm.Medium},
+		{"utm_campaign", utm.Campaign},
+		{"utm_term", utm.Term},
+		{"utm_content", utm.Content},
+	} {
+		if p[1] != "" {
+			pairs = append(pairs, p)
+		}
+	}
+	return pairs
+}
+
+// validateUTM checks UTM parameters given at creation or update
+func validateUTM(utm *models.UTMParams) error {
+	for _, p := range utmPairs(utm) {
+		if len(p[1]) > maxUTMLength {
+			return fmt.Errorf("%s is longer than %d characters", p[0], maxUTMLength)
+		}
+		if strings.ContainsFunc(p[1], func(r rune) bool { return r < 0x20 || r == 0x7F }) {
+			return fmt.Errorf("%s contains control characters", p[0])
+		}
+	}
+	return nil
+}
+
+// checkTargets validates targeting rules given at creation or update and
+// puts every alternate destination through the same checks as the
+// original URL. When a rule is refused it writes the response and returns
+// the URL creation outcome for metrics; it returns "" when all rules pass.
+func checkTargets(
+	w http.ResponseWriter,
+	r *http.Request,
+	validator utils.URLValidatorInterface,
+	safeBrowsing safebrowsing.SafeBrowsingChecker,
+	rules []models.TargetRule,
+	logger *slog.Logger,
+) string {
+	if err := targeting.Validate(rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return metrics.OutcomeInvalidRequest
+	}
+	urls := make([]string, len(rules))
+	for i, rule := range rules {
+		urls[i] = rule.URL
+	}
+	return checkDestinations(w, r, validator, safeBrowsing, "targets", urls, logger)
+}
+
+// checkVariants is checkTargets for split destinations
+func checkVariants(
+	w http.ResponseWriter,
+	r *http.Request,
+	validator utils.URLValidatorInterface,
+	safeBrowsing safebrowsing.SafeBrowsingChecker,
+	variants []models.Variant,
+	logger *slog.Logger,
+) string {
+	if err := validateVariants(variants); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return metrics.OutcomeInvalidRequest
+	}
+	urls := make([]string, len(variants))
+	for i, variant := range variants {
+		urls[i] = variant.URL
+	}
+	return checkDestinations(w, r, validator, safeBrowsing, "variants", urls, logger)
+}
+
+// checkDestinations puts the URLs listed under field through the URL
+// validator and Safe Browsing
+func checkDestinations(
+	w http.ResponseWriter,
+	r *http.Request,
+	validator utils.URLValidatorInterface,
+	safeBrowsing safebrowsing.SafeBrowsingChecker,
+	field string,
+	urls []string,
+	logger *slog.Logger,
+) string {
+	logger = logging.FromContext(r.Context(), logger)
+	for i, destination := range urls {
+		result := validator.ValidateURL(r.Context(), destination)
+		if !result.IsValid {
+			logger.Info("Alternate URL validation failed",
+				slog.String("field", field),
+				slog.String("url", destination),
+				slog.Any("errors", result.Errors))
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error":  fmt.Sprintf("%s[%d]: URL validation failed", field, i),
+				"errors": result.Errors,
+			})
+			return metrics.OutcomeValidation
+		}
+
+		isSafe, err := safeBrowsing.IsURLSafe(r.Context(), destination)
+		if err != nil {
+			logger.Error("SafeBrowsing check failed", slog.Any("error", err))
+			if errors.Is(err, safebrowsing.ErrCircuitOpen) {
+				http.Error(w, "URL safety checks are temporarily unavailable, please try again", http.StatusServiceUnavailable)
+			} else {
+				http.Error(w, "Error checking URL safety", http.StatusInternalServerError)
+			}
+			return metrics.OutcomeSafeBrowsingErr
+		}
+		if !isSafe {
+			logger.Warn("Unsafe alternate URL detected", slog.String("field", field), slog.String("url", destination))
+			http.Error(w, fmt.Sprintf("%s[%d]: URL detected as potentially harmful", field, i), http.StatusBadRequest)
+			return metrics.OutcomeUnsafe
+		}
+	}
+	return ""
+}
+
+// destinationURL is where a visit goes: the stored destination with the
+// link's UTM parameters added where it does not have them, and, for links
+// forwarding queries, the visitor's query merged in. A key the visitor
+// sends replaces the destination's own. The destination's query is kept
+// byte for byte otherwise, since some sites are picky about encoding.
+func destinationURL(original string, utm *models.UTMParams, forwardQuery bool, incoming url.Values) string {
+	defaults := utmPairs(utm)
+	if !forwardQuery {
+		incoming = nil
+	}
+	if len(defaults) == 0 && len(incoming) == 0 {
+		return original
+	}
+
+	u, err := url.Parse(original)
+	if err != nil {
+		// Validated at creation, so this does not happen
+		return original
+	}
+
+	var parts []string
+	present := make(map[string]bool)
+	if u.RawQuery != "" {
+		for _, part := range strings.Split(u.RawQuery, "&") {
+			rawKey, _, _ := strings.Cut(part, "=")
+			key, err := url.QueryUnescape(rawKey)
+			if err != nil {
+				key = rawKey
+			}
+			if _, replaced := incoming[key]; replaced {
+				continue
+			}
+			present[key] = true
+			parts = append(parts, part)
+		}
+	}
+
+	for _, p := range defaults {
+		if _, replaced := incoming[p[0]]; !present[p[0]] && !replaced {
+			parts = append(parts, url.QueryEscape(p[0])+"="+url.QueryEscape(p[1]))
+		}
+	}
+	if encoded := incoming.Encode(); encoded != "" {
+		parts = append(parts, encoded)
+	}
+
+	u.RawQuery = strings.Join(parts, "&")
+	u.ForceQuery = false
+	return u.String()
+}
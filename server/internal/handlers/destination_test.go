@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dev4dreams/dev4url/internal/models"
+)
+
+func TestDestinationURL(t *testing.T) {
+	utm := &models.UTMParams{Source: "poster", Campaign: "spring sale"}
+
+	tests := []struct {
+		name     string
+		original string
+		utm      *models.UTMParams
+		forward  bool
+		incoming string
+		want     string
+	}{
+		{
+			name:     "untouched without options",
+			original: "https://example.org/a?b=%7e&c",
+			incoming: "utm_source=ignored",
+			want:     "https://example.org/a?b=%7e&c",
+		},
+		{
+			name:     "defaults added",
+			original: "https://example.org/a#top",
+			utm:      utm,
+			want:     "https://example.org/a?utm_source=poster&utm_campaign=spring+sale#top",
+		},
+		{
+			name:     "destination keeps its own UTM",
+			original: "https://example.org/?utm_source=site",
+			utm:      utm,
+			want:     "https://example.org/?utm_source=site&utm_campaign=spring+sale",
+		},
+		{
+			name:     "visitor query merged",
+			original: "https://example.org/?id=1&ref=old",
+			forward:  true,
+			incoming: "ref=new&x=2",
+			want:     "https://example.org/?id=1&ref=new&x=2",
+		},
+		{
+			name:     "visitor overrides defaults",
+			original: "https://example.org/",
+			utm:      utm,
+			forward:  true,
+			incoming: "utm_source=twitter",
+			want:     "https://example.org/?utm_campaign=spring+sale&utm_source=twitter",
+		},
+	}
+
+	for _, tt := range tests {
+		incoming, _ := url.ParseQuery(tt.incoming)
+		if got := destinationURL(tt.original, tt.utm, tt.forward, incoming); got != tt.want {
+			t.Errorf("%s: destinationURL() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateUTM(t *testing.T) {
+	if err := validateUTM(&models.UTMParams{Source: "newsletter", Medium: "email"}); err != nil {
+		t.Errorf("validateUTM() unexpected error: %v", err)
+	}
+	if err := validateUTM(&models.UTMParams{Term: strings.Repeat("x", maxUTMLength+1)}); err == nil {
+		t.Error("Expected an error for an overlong value")
+	}
+	if err := validateUTM(&models.UTMParams{Content: "a\nb"}); err == nil {
+		t.Error("Expected an error for a control character")
+	}
+}
@@ -0,0 +1,140 @@
+// internal/handlers/health.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/db"
+	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/internal/services/safebrowsing"
+)
+
+const (
+	healthStatusOK          = "ok"
+	healthStatusFailing     = "failing"
+	healthStatusDegraded    = "degraded"
+	healthStatusDraining    = "draining"
+	healthStatusUnavailable = "unavailable"
+)
+
+// HealthHandler serves the liveness and readiness probes
+type HealthHandler struct {
+	db              *db.Database
+	safeBrowsing    *safebrowsing.SafeBrowsingService
+	latestMigration int
+	timeout         time.Duration
+	draining        atomic.Bool
+	logger          *slog.Logger
+}
+
+// NewHealthHandler creates a health handler. timeout bounds the database
+// checks of a single readiness probe.
+func NewHealthHandler(
+	database *db.Database,
+	safeBrowsing *safebrowsing.SafeBrowsingService,
+	timeout time.Duration,
+	logger *slog.Logger,
+) (*HealthHandler, error) {
+	latest, err := db.LatestMigration()
+	if err != nil {
+		return nil, err
+	}
+
+	return &HealthHandler{
+		db:              database,
+		safeBrowsing:    safeBrowsing,
+		latestMigration: latest,
+		timeout:         timeout,
+		logger:          logger,
+	}, nil
+}
+
+// StartDraining makes readiness fail so orchestrators stop routing traffic
+// here while in-flight requests finish
+func (h *HealthHandler) StartDraining() {
+	h.draining.Store(true)
+}
+
+// Liveness reports that the process is up and serving requests
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, models.HealthResponse{Status: healthStatusOK})
+}
+
+// Readiness reports whether this instance should receive traffic
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		writeHealth(w, http.StatusServiceUnavailable, models.HealthResponse{Status: healthStatusDraining})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	checks := map[string]models.HealthCheck{
+		"database":   h.checkDatabase(ctx),
+		"migrations": h.checkMigrations(ctx),
+	}
+
+	ready := true
+	for name, check := range checks {
+		if check.Status != healthStatusOK {
+			ready = false
+			h.logger.Warn("Readiness check failed",
+				slog.String("check", name), slog.String("detail", check.Detail))
+		}
+	}
+
+	// An open breaker only degrades URL creation, redirects keep working,
+	// so it is reported without failing readiness
+	checks["safebrowsing"] = h.checkSafeBrowsing()
+
+	response := models.HealthResponse{Status: healthStatusOK, Checks: checks}
+	status := http.StatusOK
+	if !ready {
+		response.Status = healthStatusUnavailable
+		status = http.StatusServiceUnavailable
+	}
+	writeHealth(w, status, response)
+}
+
+func (h *HealthHandler) checkDatabase(ctx context.Context) models.HealthCheck {
+	if err := h.db.PingContext(ctx); err != nil {
+		return models.HealthCheck{Status: healthStatusFailing, Detail: err.Error()}
+	}
+	return models.HealthCheck{Status: healthStatusOK}
+}
+
+func (h *HealthHandler) checkMigrations(ctx context.Context) models.HealthCheck {
+	version, err := h.db.MigrationVersion(ctx)
+	if err != nil {
+		return models.HealthCheck{Status: healthStatusFailing, Detail: err.Error()}
+	}
+	if version < h.latestMigration {
+		return models.HealthCheck{
+			Status: healthStatusFailing,
+			Detail: fmt.Sprintf("schema at version %d, expected %d", version, h.latestMigration),
+		}
+	}
+	return models.HealthCheck{Status: healthStatusOK}
+}
+
+func (h *HealthHandler) checkSafeBrowsing() models.HealthCheck {
+	state := h.safeBrowsing.BreakerState()
+	if state != safebrowsing.BreakerClosed {
+		return models.HealthCheck{Status: healthStatusDegraded, Detail: "circuit breaker " + state.String()}
+	}
+	return models.HealthCheck{Status: healthStatusOK}
+}
+
+func writeHealth(w http.ResponseWriter, status int, response models.HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
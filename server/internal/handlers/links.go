@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/dev4dreams/dev4url/internal/db"
+	"github.com/dev4dreams/dev4url/internal/logging"
+	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/internal/services/safebrowsing"
+	"github.com/dev4dreams/dev4url/internal/utils"
+)
+
+// CacheInvalidator drops cached resolutions of a changed code, see
+// linkcache.Cache
+type CacheInvalidator interface {
+	Invalidate(ctx context.Context, code string) error
+}
+
+// LinksHandler is the management API for existing short URLs. It has no
+// authentication of its own and belongs on the admin listener.
+type LinksHandler struct {
+	db           *db.Database
+	validator    utils.URLValidatorInterface
+	appValidator *utils.AppURLValidator
+	safeBrowsing safebrowsing.SafeBrowsingChecker
+	cache        CacheInvalidator  // nil without a resolve cache
+	metadata     MetadataRefresher // nil when metadata fetching is disabled
+	logger       *slog.Logger
+}
+
+// NewLinksHandler creates a management handler; cache and metadata may be
+// nil
+func NewLinksHandler(
+	database *db.Database,
+	validator utils.URLValidatorInterface,
+	appValidator *utils.AppURLValidator,
+	safeBrowsing safebrowsing.SafeBrowsingChecker,
+	cache CacheInvalidator,
+	metadata MetadataRefresher,
+	logger *slog.Logger,
+) *LinksHandler {
+	return &LinksHandler{
+		db:           database,
+		validator:    validator,
+		appValidator: appValidator,
+		safeBrowsing: safeBrowsing,
+		cache:        cache,
+		metadata:     metadata,
+		logger:       logger,
+	}
+}
+
+// GetLink serves GET /links/{code}
+func (h *LinksHandler) GetLink(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+	link, err := h.db.GetURL(r.Context(), code)
+	if err != nil {
+		h.writeDBError(w, r, code, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, link)
+}
+
+// GetStats serves GET /links/{code}/stats, the link's clicks split by
+// variant. Counts lag behind redirects by up to the click flush interval.
+func (h *LinksHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+	link, err := h.db.GetURL(r.Context(), code)
+	if err != nil {
+		h.writeDBError(w, r, code, err)
+		return
+	}
+	variantClicks, err := h.db.VariantClicks(r.Context(), code)
+	if err != nil {
+		h.writeDBError(w, r, code, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, linkStats(link, variantClicks))
+}
+
+// linkStats lists the link's variants with their clicks, followed by
+// removed variants that still have clicks
+func linkStats(link *models.URLResponse, variantClicks map[string]int64) models.LinkStats {
+	stats := models.LinkStats{ShortURL: link.ShortURL, Clicks: link.Clicks}
+	var total int64
+	for _, n := range variantClicks {
+		total += n
+	}
+
+	for _, variant := range link.Variants {
+		stats.Variants = append(stats.Variants, models.VariantStats{
+			Name:   variant.Name,
+			URL:    variant.URL,
+			Weight: variant.Weight,
+			Clicks: variantClicks[variant.Name],
+		})
+		delete(variantClicks, variant.Name)
+	}
+	removed := make([]string, 0, len(variantClicks))
+	for name := range variantClicks {
+		removed = append(removed, name)
+	}
+	slices.Sort(removed)
+	for _, name := range removed {
+		stats.Variants = append(stats.Variants, models.VariantStats{Name: name, Clicks: variantClicks[name]})
+	}
+
+	if total > 0 {
+		for i := range stats.Variants {
+			stats.Variants[i].Share = float64(stats.Variants[i].Clicks) / float64(total)
+		}
+	}
+	return stats
+}
+
+// UpdateLink serves PATCH /links/{code}, changing the destination or
+// disabling the link. New destinations, targeted ones included, go through
+// the same checks as at creation.
+func (h *LinksHandler) UpdateLink(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	code := r.PathValue("code")
+
+	var req models.UpdateUrlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.OriginalURL == nil && req.Active == nil && req.Interstitial == nil &&
+		req.UTM == nil && req.ForwardQuery == nil && req.Targets == nil &&
+		req.Variants == nil && req.StickyVariants == nil && req.AppLinks == nil &&
+		req.Schedule == nil {
+		http.Error(w, "Nothing to update", http.StatusBadRequest)
+		return
+	}
+	if err := validateUTM(req.UTM); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.OriginalURL != nil {
+		result := h.validator.ValidateURL(r.Context(), *req.OriginalURL)
+		if !result.IsValid {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error":  "URL validation failed",
+				"errors": result.Errors,
+			})
+			return
+		}
+		isSafe, err := h.safeBrowsing.IsURLSafe(r.Context(), *req.OriginalURL)
+		if err != nil {
+			logger.Error("SafeBrowsing check failed", slog.Any("error", err))
+			http.Error(w, "Error checking URL safety", http.StatusServiceUnavailable)
+			return
+		}
+		if !isSafe {
+			http.Error(w, "URL detected as potentially harmful", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Targets != nil {
+		if outcome := checkTargets(w, r, h.validator, h.safeBrowsing, *req.Targets, h.logger); outcome != "" {
+			return
+		}
+	}
+	if req.Variants != nil {
+		if outcome := checkVariants(w, r, h.validator, h.safeBrowsing, *req.Variants, h.logger); outcome != "" {
+			return
+		}
+	}
+	if outcome := checkAppLinks(w, r, h.validator, h.safeBrowsing, h.appValidator, req.AppLinks, h.logger); outcome != "" {
+		return
+	}
+	if outcome := checkSchedule(w, r, h.validator, h.safeBrowsing, req.Schedule, h.logger); outcome != "" {
+		return
+	}
+
+	link, err := h.db.UpdateURL(r.Context(), code, &req)
+	if err != nil {
+		h.writeDBError(w, r, code, err)
+		return
+	}
+
+	// Until the cache forgets the code, redirects keep the old destination
+	if h.cache != nil {
+		if err := h.cache.Invalidate(r.Context(), code); err != nil {
+			logger.Error("Failed to invalidate cached link, it expires with its TTL",
+				slog.String("short_code", code), slog.Any("error", err))
+		}
+	}
+
+	// The old destination's metadata was cleared with the update
+	if req.OriginalURL != nil && link.Metadata == nil && h.metadata != nil {
+		h.metadata.Refresh(code, link.OriginalURL)
+	}
+
+	logger.Info("Short URL updated",
+		slog.String("short_code", code),
+		slog.String("original_url", link.OriginalURL),
+		slog.Bool("active", link.Active))
+	writeJSON(w, http.StatusOK, link)
+}
+
+func (h *LinksHandler) writeDBError(w http.ResponseWriter, r *http.Request, code string, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "URL not found", http.StatusNotFound)
+		return
+	}
+	logging.FromContext(r.Context(), h.logger).Error("Failed to access short URL",
+		slog.String("short_code", code), slog.Any("error", err))
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
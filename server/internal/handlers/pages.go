@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/logging"
+	"github.com/dev4dreams/dev4url/internal/metrics"
+	"github.com/dev4dreams/dev4url/internal/models"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+var pageTemplates = template.Must(template.ParseFS(templateFiles, "templates/*.html"))
+
+// Safety values shown on the preview page
+const (
+	safetySafe    = "safe"
+	safetyUnsafe  = "unsafe"
+	safetyUnknown = "unknown"
+)
+
+type previewPage struct {
+	Code         string
+	Destination  string
+	Domain       string
+	Safety       string
+	CreatedAt    time.Time
+	Clicks       int
+	Interstitial bool
+}
+
+type unfurlPage struct {
+	Destination string
+	Title       string
+	Description string
+	Image       string
+	Favicon     string
+}
+
+// previewBots are user agent fragments of crawlers that unfurl shared
+// links in chats and feeds, lower case
+var previewBots = []string{
+	"slackbot",
+	"twitterbot",
+	"facebookexternalhit",
+	"linkedinbot",
+	"discordbot",
+	"whatsapp",
+	"telegrambot",
+}
+
+// isPreviewBot reports whether r comes from a link preview crawler
+func isPreviewBot(r *http.Request) bool {
+	ua := strings.ToLower(r.UserAgent())
+	for _, bot := range previewBots {
+		if strings.Contains(ua, bot) {
+			return true
+		}
+	}
+	return false
+}
+
+type notFoundPage struct {
+	Code       string
+	Suggestion string
+}
+
+// Visit serves GET /{code}. It redirects, or shows the preview page when
+// the code ends in "+", the preview query parameter is set, or the link's
+// creator asked for an interstitial. Link preview bots get the
+// destination's metadata instead and are not counted as clicks. Links with
+// targeting rules send each visitor to the first rule they match, split
+// links to one of their variants. Mobile visitors of links with an app link
+// for their platform are sent to the app first. Scheduled links outside
+// their window send visitors to the before or after URL.
+func (h *RedirectHandler) Visit(w http.ResponseWriter, r *http.Request) {
+	code, preview := strings.CutSuffix(r.PathValue("code"), "+")
+	incoming := r.URL.Query()
+	preview = preview || incoming.Has("preview")
+	incoming.Del("preview")
+
+	if err := h.format.Verify(code); err != nil {
+		metrics.Redirects.WithLabelValues(metrics.ResultMalformed).Inc()
+		h.renderNotFound(w, r, code, h.suggest(r, code))
+		return
+	}
+	if preview {
+		h.renderPreview(w, r, code, incoming, "")
+		return
+	}
+	if isPreviewBot(r) {
+		h.renderUnfurl(w, r, code, incoming)
+		return
+	}
+
+	link, err := h.resolver.ResolveLink(r.Context(), code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			metrics.Redirects.WithLabelValues(metrics.ResultMiss).Inc()
+			h.renderNotFound(w, r, code, "")
+			return
+		}
+		logging.FromContext(r.Context(), h.logger).Error("Failed to resolve short URL",
+			slog.String("short_code", code), slog.Any("error", err))
+		metrics.Redirects.WithLabelValues(metrics.ResultError).Inc()
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Outside its window a link is not visited, so no click is counted
+	if fallback, live := scheduled(link.Schedule, time.Now()); !live {
+		metrics.Redirects.WithLabelValues(metrics.ResultScheduled).Inc()
+		if fallback == "" {
+			h.renderNotFound(w, r, code, "")
+			return
+		}
+		http.Redirect(w, r, fallback, http.StatusFound)
+		return
+	}
+
+	var assigned string
+	if link.StickyVariants {
+		assigned = assignedVariant(r, code)
+	}
+	destination, variant := h.choose(r, link, assigned)
+
+	metrics.Redirects.WithLabelValues(metrics.ResultHit).Inc()
+	h.clicks.Add(code, variant)
+	if variant != "" && link.StickyVariants {
+		rememberVariant(w, code, variant)
+	}
+
+	if link.Interstitial {
+		h.renderPreview(w, r, code, incoming, variant)
+		return
+	}
+	if appURL := appLinkFor(r, link.AppLinks); appURL != "" {
+		h.renderAppLink(w, r, appURL, appFallback(link.AppLinks, destination, link.UTM, link.ForwardQuery, incoming))
+		return
+	}
+	http.Redirect(w, r, destinationURL(destination, link.UTM, link.ForwardQuery, incoming), http.StatusFound)
+}
+
+// renderPreview shows where a link goes without sending the visitor there.
+// assigned is the variant just served, if any; otherwise sticky links
+// show the visitor's remembered one.
+func (h *RedirectHandler) renderPreview(w http.ResponseWriter, r *http.Request, code string, incoming url.Values, assigned string) {
+	logger := logging.FromContext(r.Context(), h.logger)
+
+	link, err := h.db.GetURL(r.Context(), code)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !link.Active) {
+		h.renderNotFound(w, r, code, "")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to load short URL for preview",
+			slog.String("short_code", code), slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The preview shows where this visitor would be sent
+	var destination string
+	if fallback, live := scheduled(link.Schedule, time.Now()); !live {
+		if fallback == "" {
+			h.renderNotFound(w, r, code, "")
+			return
+		}
+		destination = fallback
+	} else {
+		if assigned == "" && link.StickyVariants {
+			assigned = assignedVariant(r, code)
+		}
+		target, _ := h.choose(r, &models.ResolvedLink{
+			OriginalURL: link.OriginalURL,
+			Targets:     link.Targets,
+			Variants:    link.Variants,
+		}, assigned)
+		destination = destinationURL(target, link.UTM, link.ForwardQuery, incoming)
+	}
+	page := previewPage{
+		Code:         code,
+		Destination:  destination,
+		Safety:       safetyUnknown,
+		CreatedAt:    link.CreatedAt,
+		Clicks:       link.Clicks,
+		Interstitial: link.Interstitial,
+	}
+	if parsed, err := url.Parse(destination); err == nil {
+		page.Domain = parsed.Hostname()
+	}
+
+	// The destination may have turned malicious since it was checked at
+	// creation
+	isSafe, err := h.safeBrowsing.IsURLSafe(r.Context(), destination)
+	switch {
+	case err != nil:
+		logger.Warn("SafeBrowsing check failed for preview",
+			slog.String("short_code", code), slog.Any("error", err))
+	case isSafe:
+		page.Safety = safetySafe
+	default:
+		page.Safety = safetyUnsafe
+	}
+
+	h.renderPage(w, r, http.StatusOK, "preview", page)
+}
+
+// renderUnfurl answers a preview bot with the stored metadata of the
+// destination. Without metadata the bot is redirected to fetch the
+// destination itself.
+func (h *RedirectHandler) renderUnfurl(w http.ResponseWriter, r *http.Request, code string, incoming url.Values) {
+	link, err := h.db.GetURL(r.Context(), code)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !link.Active) {
+		h.renderNotFound(w, r, code, "")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context(), h.logger).Error("Failed to load short URL for unfurl",
+			slog.String("short_code", code), slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// A link shared before its launch must not give the destination away
+	if fallback, live := scheduled(link.Schedule, time.Now()); !live {
+		if fallback == "" {
+			h.renderNotFound(w, r, code, "")
+			return
+		}
+		http.Redirect(w, r, fallback, http.StatusFound)
+		return
+	}
+
+	destination := destinationURL(link.OriginalURL, link.UTM, link.ForwardQuery, incoming)
+	meta := link.Metadata
+	if meta == nil {
+		http.Redirect(w, r, destination, http.StatusFound)
+		return
+	}
+	page := unfurlPage{
+		Destination: destination,
+		Title:       meta.Title,
+		Description: meta.Description,
+		Image:       meta.Image,
+		Favicon:     meta.Favicon,
+	}
+	if page.Title == "" {
+		if parsed, err := url.Parse(destination); err == nil {
+			page.Title = parsed.Hostname()
+		}
+	}
+	h.renderPage(w, r, http.StatusOK, "unfurl", page)
+}
+
+func (h *RedirectHandler) renderNotFound(w http.ResponseWriter, r *http.Request, code, suggestion string) {
+	h.renderPage(w, r, http.StatusNotFound, "not_found", notFoundPage{Code: code, Suggestion: suggestion})
+}
+
+// pageCSP allows the pages no scripts at all
+const pageCSP = "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'"
+
+func (h *RedirectHandler) renderPage(w http.ResponseWriter, r *http.Request, status int, name string, data any) {
+	h.renderPageWithCSP(w, r, status, name, data, pageCSP)
+}
+
+// renderPageWithCSP renders into a buffer first so a template error still
+// yields a clean 500
+func (h *RedirectHandler) renderPageWithCSP(w http.ResponseWriter, r *http.Request, status int, name string, data any, csp string) {
+	var buf bytes.Buffer
+	if err := pageTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		logging.FromContext(r.Context(), h.logger).Error("Failed to render page",
+			slog.String("template", name), slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", csp)
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
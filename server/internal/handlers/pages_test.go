@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderPreview(t *testing.T) {
+	h := &RedirectHandler{logger: slog.Default()}
+	page := previewPage{
+		Code:         "abc1234",
+		Destination:  `https://example.org/?q="><script>alert(1)</script>`,
+		Domain:       "example.org",
+		Safety:       safetySafe,
+		CreatedAt:    time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		Clicks:       42,
+		Interstitial: true,
+	}
+
+	rec := httptest.NewRecorder()
+	h.renderPage(rec, httptest.NewRequest(http.MethodGet, "/abc1234+", nil), http.StatusOK, "preview", page)
+
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("Unexpected response %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if strings.Contains(body, "<script>") {
+		t.Error("Expected the destination to be escaped")
+	}
+	for _, want := range []string{"You are leaving dev4url", "1 March 2025", "42", "No known threats", "Continue to example.org"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the page to contain %q", want)
+		}
+	}
+
+	page.Safety = safetyUnsafe
+	rec = httptest.NewRecorder()
+	h.renderPage(rec, httptest.NewRequest(http.MethodGet, "/abc1234+", nil), http.StatusOK, "preview", page)
+	if strings.Contains(rec.Body.String(), "Continue to") {
+		t.Error("Expected no link to a flagged destination")
+	}
+}
+
+func TestRenderNotFound(t *testing.T) {
+	h := &RedirectHandler{logger: slog.Default()}
+	rec := httptest.NewRecorder()
+	h.renderNotFound(rec, httptest.NewRequest(http.MethodGet, "/abc1235", nil), "abc1235", "abc1234")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `href="/abc1234"`) {
+		t.Errorf("Expected a link to the suggestion in:\n%s", rec.Body.String())
+	}
+}
+
+func TestRenderUnfurl(t *testing.T) {
+	h := &RedirectHandler{logger: slog.Default()}
+	page := unfurlPage{
+		Destination: "https://example.org/article",
+		Title:       `Breaking "news"`,
+		Image:       "https://example.org/card.png",
+	}
+
+	rec := httptest.NewRecorder()
+	h.renderPage(rec, httptest.NewRequest(http.MethodGet, "/abc1234", nil), http.StatusOK, "unfurl", page)
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<meta property="og:title" content="Breaking &#34;news&#34;">`,
+		`<meta property="og:image" content="https://example.org/card.png">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+		`content="0;url=https://example.org/article"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the page to contain %s in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "og:description") {
+		t.Error("Expected no description tags without a description")
+	}
+}
+
+func TestIsPreviewBot(t *testing.T) {
+	for ua, want := range map[string]bool{
+		"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)":                true,
+		"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)": true,
+		"Twitterbot/1.0": true,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0": false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/abc1234", nil)
+		r.Header.Set("User-Agent", ua)
+		if got := isPreviewBot(r); got != want {
+			t.Errorf("isPreviewBot(%q) = %v, want %v", ua, got, want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dev4dreams/dev4url/internal/core"
+	"github.com/dev4dreams/dev4url/internal/logging"
+	"github.com/dev4dreams/dev4url/internal/metrics"
+	"github.com/dev4dreams/dev4url/pkg/qrcode"
+)
+
+// QR code request limits
+const (
+	qrDefaultSize = 512
+	qrMinSize     = 64
+	qrMaxSize     = 2048
+	qrMaxMargin   = 16
+)
+
+// QR code colors, as NRGBA so parsed colors compare equal
+var (
+	qrBlack       = color.NRGBA{A: 0xFF}
+	qrWhite       = color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+	qrTransparent = color.NRGBA{}
+)
+
+// QRHandler serves QR codes of short URLs
+type QRHandler struct {
+	resolver LinkResolver
+	format   core.CodeFormat
+	baseURL  string
+	logos    map[string]image.Image
+	logger   *slog.Logger
+}
+
+// NewQRHandler creates a handler encoding baseURL + "/" + code. Logos are
+// selected by name with the logo query parameter; logos may be nil.
+func NewQRHandler(
+	resolver LinkResolver,
+	format core.CodeFormat,
+	baseURL string,
+	logos map[string]image.Image,
+	logger *slog.Logger,
+) *QRHandler {
+	return &QRHandler{
+		resolver: resolver,
+		format:   format,
+		baseURL:  baseURL,
+		logos:    logos,
+		logger:   logger,
+	}
+}
+
+// qrRequest is a parsed QR code query
+type qrRequest struct {
+	svg   bool
+	level qrcode.Level
+	opts  qrcode.Options
+}
+
+// parseQRRequest reads format, size, ec, margin, fg, bg and logo from the
+// query
+func (h *QRHandler) parseQRRequest(query url.Values) (*qrRequest, error) {
+	req := &qrRequest{
+		level: qrcode.Medium,
+		opts: qrcode.Options{
+			Size:       qrDefaultSize,
+			Margin:     qrcode.DefaultMargin,
+			Foreground: qrBlack,
+			Background: qrWhite,
+		},
+	}
+
+	switch format := query.Get("format"); format {
+	case "", "png":
+	case "svg":
+		req.svg = true
+	default:
+		return nil, fmt.Errorf("format must be png or svg, got %q", format)
+	}
+
+	if v := query.Get("size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < qrMinSize || size > qrMaxSize {
+			return nil, fmt.Errorf("size must be between %d and %d pixels", qrMinSize, qrMaxSize)
+		}
+		req.opts.Size = size
+	}
+	if v := query.Get("ec"); v != "" {
+		level, err := qrcode.ParseLevel(v)
+		if err != nil {
+			return nil, errors.New("ec must be L, M, Q or H")
+		}
+		req.level = level
+	}
+	if v := query.Get("margin"); v != "" {
+		margin, err := strconv.Atoi(v)
+		if err != nil || margin < 0 || margin > qrMaxMargin {
+			return nil, fmt.Errorf("margin must be between 0 and %d modules", qrMaxMargin)
+		}
+		req.opts.Margin = margin
+	}
+	if v := query.Get("fg"); v != "" {
+		fg, err := parseHexColor(v)
+		if err != nil {
+			return nil, fmt.Errorf("fg: %w", err)
+		}
+		req.opts.Foreground = fg
+	}
+	if v := query.Get("bg"); v == "transparent" {
+		req.opts.Background = qrTransparent
+	} else if v != "" {
+		bg, err := parseHexColor(v)
+		if err != nil {
+			return nil, fmt.Errorf("bg: %w", err)
+		}
+		req.opts.Background = bg
+	}
+	if req.opts.Foreground == req.opts.Background {
+		return nil, errors.New("fg and bg must differ")
+	}
+
+	if name := query.Get("logo"); name != "" {
+		logo, ok := h.logos[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown logo %q", name)
+		}
+		req.opts.Logo = logo
+		// The logo hides part of the code, which needs to be recoverable
+		req.level = max(req.level, qrcode.Quartile)
+	}
+	return req, nil
+}
+
+// parseHexColor reads RGB or RRGGBB, with or without a leading #
+func parseHexColor(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if len(s) != 6 || err != nil {
+		return color.NRGBA{}, fmt.Errorf("%q is not a hex color like 1a2b3c", s)
+	}
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xFF}, nil
+}
+
+// ServeQR serves GET /{code}/qr, a PNG or SVG QR code of the short URL
+func (h *QRHandler) ServeQR(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.logger)
+	code := r.PathValue("code")
+
+	req, err := h.parseQRRequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Only active links get a code, so posters are not printed for typos
+	if err := h.format.Verify(code); err != nil {
+		http.Error(w, "URL not found", http.StatusNotFound)
+		return
+	}
+	if _, err := h.resolver.ResolveLink(r.Context(), code); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "URL not found", http.StatusNotFound)
+			return
+		}
+		logger.Error("Failed to resolve short URL for QR code",
+			slog.String("short_code", code), slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	qr, err := qrcode.Encode([]byte(h.baseURL+"/"+code), req.level)
+	if err != nil {
+		logger.Error("Failed to encode QR code", slog.String("short_code", code), slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	contentType, format := "image/png", "png"
+	if req.svg {
+		contentType, format = "image/svg+xml", "svg"
+		err = qrcode.SVG(&buf, qr, req.opts)
+	} else {
+		err = qrcode.PNG(&buf, qr, req.opts)
+	}
+	if errors.Is(err, qrcode.ErrTooSmall) {
+		http.Error(w, fmt.Sprintf("size is too small for a %d module code", qr.Size()+2*req.opts.Margin),
+			http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to render QR code", slog.String("short_code", code), slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	metrics.QRCodes.WithLabelValues(format).Inc()
+	// The short URL of a code never changes
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src data:")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"image"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dev4dreams/dev4url/internal/core"
+	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/pkg/qrcode"
+)
+
+// activeCodes is a LinkResolver knowing a fixed set of codes
+type activeCodes map[string]bool
+
+func (a activeCodes) ResolveLink(ctx context.Context, code string) (*models.ResolvedLink, error) {
+	if !a[code] {
+		return nil, sql.ErrNoRows
+	}
+	return &models.ResolvedLink{OriginalURL: "https://example.org"}, nil
+}
+
+func newTestQRHandler() *QRHandler {
+	logos := map[string]image.Image{"events": image.NewNRGBA(image.Rect(0, 0, 10, 10))}
+	return NewQRHandler(activeCodes{"abc1234": true}, core.CodeFormat{Length: 7}, "https://dev4url.cc", logos, slog.Default())
+}
+
+func serveQR(h *QRHandler, target string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{code}/qr", h.ServeQR)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestServeQR(t *testing.T) {
+	h := newTestQRHandler()
+
+	rec := serveQR(h, "/abc1234/qr?size=200")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("Unexpected response %d %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	img, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+	if img.Bounds().Dx() != 200 {
+		t.Errorf("Expected a 200 pixel image, got %v", img.Bounds())
+	}
+
+	rec = serveQR(h, "/abc1234/qr?format=svg&fg=%23c00&bg=transparent&margin=1")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("Unexpected response %d %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `fill="#cc0000"`) || strings.Contains(body, "<rect") {
+		t.Errorf("Expected a red code on a transparent background:\n%s", body)
+	}
+
+	if rec := serveQR(h, "/xyz9876/qr"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown code, got %d", rec.Code)
+	}
+}
+
+func TestParseQRRequest(t *testing.T) {
+	h := newTestQRHandler()
+
+	for _, query := range []string{
+		"format=gif",
+		"size=10",
+		"size=99999",
+		"ec=X",
+		"margin=-1",
+		"fg=red",
+		"fg=fff&bg=ffffff",
+		"logo=missing",
+	} {
+		if rec := serveQR(h, "/abc1234/qr?"+query); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, rec.Code)
+		}
+	}
+
+	req, err := h.parseQRRequest(map[string][]string{"logo": {"events"}, "ec": {"L"}})
+	if err != nil {
+		t.Fatalf("parseQRRequest() unexpected error: %v", err)
+	}
+	if req.opts.Logo == nil || req.level != qrcode.Quartile {
+		t.Errorf("Expected a logo to raise the level to Q, got %s", req.level)
+	}
+}
@@ -1,22 +1,69 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"time"
 
+	"github.com/dev4dreams/dev4url/internal/core"
 	"github.com/dev4dreams/dev4url/internal/db"
+	"github.com/dev4dreams/dev4url/internal/logging"
+	"github.com/dev4dreams/dev4url/internal/metrics"
 	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/internal/services/safebrowsing"
+	"github.com/dev4dreams/dev4url/internal/services/targeting"
 )
 
+// LinkResolver resolves an active code, or returns sql.ErrNoRows; see
+// db.Database and linkcache.Cache
+type LinkResolver interface {
+	ResolveLink(ctx context.Context, code string) (*models.ResolvedLink, error)
+}
+
+// ClickCounter records a redirect and the variant it served, if any; see
+// clicks.Buffer
+type ClickCounter interface {
+	Add(code, variant string)
+}
+
 type RedirectHandler struct {
-	db *db.Database
+	db           *db.Database
+	resolver     LinkResolver
+	clicks       ClickCounter
+	safeBrowsing safebrowsing.SafeBrowsingChecker
+	format       core.CodeFormat
+	locator      *targeting.Locator
+	logger       *slog.Logger
 }
 
-// NewRedirectHandler creates a new handler instance with database connection
-func NewRedirectHandler(database *db.Database) *RedirectHandler {
+// NewRedirectHandler creates a new handler instance resolving codes through
+// resolver, the database itself or a cache in front of it, and counting
+// clicks without waiting on a write. Codes that fail the format's checksum
+// are answered without touching the database. Safe Browsing is consulted
+// for preview pages only. The locator tells where visitors are for links
+// with targeting rules.
+func NewRedirectHandler(
+	database *db.Database,
+	resolver LinkResolver,
+	clicks ClickCounter,
+	safeBrowsing safebrowsing.SafeBrowsingChecker,
+	format core.CodeFormat,
+	locator *targeting.Locator,
+	logger *slog.Logger,
+) *RedirectHandler {
 	return &RedirectHandler{
-		db: database,
+		db:           database,
+		resolver:     resolver,
+		clicks:       clicks,
+		safeBrowsing: safeBrowsing,
+		format:       format,
+		locator:      locator,
+		logger:       logger,
 	}
 }
 
@@ -40,31 +87,64 @@ func (h *RedirectHandler) HandleRedirect(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Query the database using the existing connection
-	var originalURL string
-	err := h.db.QueryRow(`
-		UPDATE urls 
-		SET 
-			clicks = clicks + 1,
-			last_accessed_at = NOW()
-		WHERE short_url = $1 AND active = true 
-		RETURNING original_url`,
-		req.ShortenUrl,
-	).Scan(&originalURL)
+	// A typo in a checksummed code cannot exist, skip the lookup
+	if err := h.format.Verify(req.ShortenUrl); err != nil {
+		metrics.Redirects.WithLabelValues(metrics.ResultMalformed).Inc()
+		h.writeMalformed(w, r, req.ShortenUrl)
+		return
+	}
+
+	link, err := h.resolver.ResolveLink(r.Context(), req.ShortenUrl)
 
 	// Handle potential errors
 	if err != nil {
-		if errors.Is(err, errors.New("sql: no rows in result set")) {
+		if errors.Is(err, sql.ErrNoRows) {
+			metrics.Redirects.WithLabelValues(metrics.ResultMiss).Inc()
 			http.Error(w, "URL not found or inactive", http.StatusNotFound)
 			return
 		}
+		logging.FromContext(r.Context(), h.logger).Error("Failed to resolve short URL",
+			slog.String("short_code", req.ShortenUrl), slog.Any("error", err))
+		metrics.Redirects.WithLabelValues(metrics.ResultError).Inc()
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Prepare and send response
+	// Outside its window a link is not visited, so no click is counted
+	if fallback, live := scheduled(link.Schedule, time.Now()); !live {
+		metrics.Redirects.WithLabelValues(metrics.ResultScheduled).Inc()
+		if fallback == "" {
+			http.Error(w, "URL not found or inactive", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, models.GetOriginalUrlResponse{OriginalURL: fallback})
+		return
+	}
+
+	// The client remembers the variant served for sticky links
+	var assigned string
+	if link.StickyVariants {
+		assigned = req.Variant
+	}
+	destination, variant := h.choose(r, link, assigned)
+
+	metrics.Redirects.WithLabelValues(metrics.ResultHit).Inc()
+	h.clicks.Add(req.ShortenUrl, variant)
+
+	// The client passes on the visitor's query; a malformed one forwards
+	// whatever parsed
+	incoming, _ := url.ParseQuery(req.Query)
+
+	// Prepare and send response; the client shows the preview page for
+	// interstitial links and tries the app first when there is an app link
 	response := models.GetOriginalUrlResponse{
-		OriginalURL: originalURL,
+		OriginalURL:  destinationURL(destination, link.UTM, link.ForwardQuery, incoming),
+		Interstitial: link.Interstitial,
+		Variant:      variant,
+		AppURL:       appLinkFor(r, link.AppLinks),
+	}
+	if response.AppURL != "" {
+		response.OriginalURL = appFallback(link.AppLinks, destination, link.UTM, link.ForwardQuery, incoming)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -73,3 +153,34 @@ func (h *RedirectHandler) HandleRedirect(w http.ResponseWriter, r *http.Request)
 		return
 	}
 }
+
+// writeMalformed answers a code with a bad check character, suggesting the
+// active code it was most likely meant to be
+func (h *RedirectHandler) writeMalformed(w http.ResponseWriter, r *http.Request, code string) {
+	response := models.ShortUrlNotFoundResponse{
+		Error:      "URL not found or inactive",
+		DidYouMean: h.suggest(r, code),
+	}
+
+	writeJSON(w, http.StatusNotFound, response)
+}
+
+// suggest returns the single active code one typo away from code, or ""
+// when there is none or the typo is ambiguous
+func (h *RedirectHandler) suggest(r *http.Request, code string) string {
+	candidates := h.format.Candidates(code)
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	found, err := h.db.ActiveShortURLs(r.Context(), candidates)
+	if err != nil {
+		logging.FromContext(r.Context(), h.logger).Warn("Failed to look up code suggestions",
+			slog.String("short_code", code), slog.Any("error", err))
+		return ""
+	}
+	if len(found) != 1 {
+		return ""
+	}
+	return found[0]
+}
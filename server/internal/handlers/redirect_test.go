@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dev4dreams/dev4url/internal/core"
+	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/internal/services/targeting"
+)
+
+// staticLink is a LinkResolver knowing one link under every code
+type staticLink models.ResolvedLink
+
+func (s *staticLink) ResolveLink(ctx context.Context, code string) (*models.ResolvedLink, error) {
+	if s == nil {
+		return nil, sql.ErrNoRows
+	}
+	link := models.ResolvedLink(*s)
+	return &link, nil
+}
+
+type discardClicks struct{}
+
+func (discardClicks) Add(code, variant string) {}
+
+func TestHandleRedirectTargets(t *testing.T) {
+	link := &staticLink{
+		OriginalURL: "https://example.com",
+		UTM:         &models.UTMParams{Source: "qr"},
+		Targets: []models.TargetRule{
+			{OS: []string{targeting.OSIOS}, URL: "https://apps.apple.com/app/id1"},
+			{OS: []string{targeting.OSAndroid}, URL: "https://play.google.com/store/apps/details?id=app"},
+			{Countries: []string{"FR"}, URL: "https://example.com/fr"},
+		},
+	}
+	h := NewRedirectHandler(nil, link, discardClicks{}, nil, core.CodeFormat{Length: 7},
+		targeting.NewLocator("CF-IPCountry", "", nil), slog.Default())
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"iphone", map[string]string{"User-Agent": "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) Mobile/15E148"},
+			"https://apps.apple.com/app/id1?utm_source=qr"},
+		{"android", map[string]string{"User-Agent": "Mozilla/5.0 (Linux; Android 14; Pixel 8) Mobile Safari/537.36", "CF-IPCountry": "FR"},
+			"https://play.google.com/store/apps/details?id=app&utm_source=qr"},
+		{"country", map[string]string{"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64)", "CF-IPCountry": "FR"},
+			"https://example.com/fr?utm_source=qr"},
+		{"fallback", map[string]string{"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64)", "CF-IPCountry": "DE"},
+			"https://example.com?utm_source=qr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/shortUrl/get", strings.NewReader(`{"shortenUrl":"abc1234"}`))
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			h.HandleRedirect(rec, r)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+			}
+			var response models.GetOriginalUrlResponse
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.OriginalURL != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, response.OriginalURL)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/metrics"
+	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/internal/services/safebrowsing"
+	"github.com/dev4dreams/dev4url/internal/utils"
+)
+
+// validateSchedule checks a schedule given at creation or update. The
+// before and after URLs are left to the URL validator.
+func validateSchedule(schedule *models.Schedule) error {
+	if schedule == nil {
+		return nil
+	}
+	if schedule.BeforeURL != "" && schedule.ActiveFrom == nil {
+		return fmt.Errorf("schedule: before_url needs active_from")
+	}
+	if schedule.AfterURL != "" && schedule.ActiveUntil == nil {
+		return fmt.Errorf("schedule: after_url needs active_until")
+	}
+	if schedule.ActiveFrom != nil && schedule.ActiveUntil != nil && !schedule.ActiveUntil.After(*schedule.ActiveFrom) {
+		return fmt.Errorf("schedule: active_until must be after active_from")
+	}
+	return nil
+}
+
+// checkSchedule is checkTargets for a link's schedule
+func checkSchedule(
+	w http.ResponseWriter,
+	r *http.Request,
+	validator utils.URLValidatorInterface,
+	safeBrowsing safebrowsing.SafeBrowsingChecker,
+	schedule *models.Schedule,
+	logger *slog.Logger,
+) string {
+	if err := validateSchedule(schedule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return metrics.OutcomeInvalidRequest
+	}
+	if schedule == nil {
+		return ""
+	}
+	var urls []string
+	for _, u := range []string{schedule.BeforeURL, schedule.AfterURL} {
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return checkDestinations(w, r, validator, safeBrowsing, "schedule", urls, logger)
+}
+
+// scheduled reports whether a link with schedule is live at now. When it
+// is not, fallback is its before or after URL, "" when the link is not to
+// be found outside its window.
+func scheduled(schedule *models.Schedule, now time.Time) (fallback string, live bool) {
+	switch {
+	case schedule == nil:
+		return "", true
+	case schedule.ActiveFrom != nil && now.Before(*schedule.ActiveFrom):
+		return schedule.BeforeURL, false
+	case schedule.ActiveUntil != nil && !now.Before(*schedule.ActiveUntil):
+		return schedule.AfterURL, false
+	}
+	return "", true
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/core"
+	"github.com/dev4dreams/dev4url/internal/models"
+)
+
+func TestValidateSchedule(t *testing.T) {
+	from := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	until := from.Add(24 * time.Hour)
+
+	tests := []struct {
+		name     string
+		schedule *models.Schedule
+		wantErr  bool
+	}{
+		{"none", nil, false},
+		{"cleared", &models.Schedule{}, false},
+		{"window", &models.Schedule{ActiveFrom: &from, ActiveUntil: &until, BeforeURL: "https://example.com/soon"}, false},
+		{"open ended", &models.Schedule{ActiveFrom: &from}, false},
+		{"before without start", &models.Schedule{ActiveUntil: &until, BeforeURL: "https://example.com/soon"}, true},
+		{"after without end", &models.Schedule{ActiveFrom: &from, AfterURL: "https://example.com/over"}, true},
+		{"empty window", &models.Schedule{ActiveFrom: &from, ActiveUntil: &from}, true},
+		{"reversed", &models.Schedule{ActiveFrom: &until, ActiveUntil: &from}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateSchedule(tt.schedule); (err != nil) != tt.wantErr {
+				t.Errorf("validateSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduled(t *testing.T) {
+	from := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	until := from.Add(24 * time.Hour)
+	schedule := &models.Schedule{
+		ActiveFrom:  &from,
+		ActiveUntil: &until,
+		BeforeURL:   "https://example.com/soon",
+	}
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		wantFallback string
+		wantLive     bool
+	}{
+		{"before", from.Add(-time.Second), "https://example.com/soon", false},
+		{"at start", from, "", true},
+		{"inside", from.Add(time.Hour), "", true},
+		{"at end", until, "", false},
+		{"after", until.Add(time.Hour), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fallback, live := scheduled(schedule, tt.now)
+			if fallback != tt.wantFallback || live != tt.wantLive {
+				t.Errorf("scheduled() = %q, %v, want %q, %v", fallback, live, tt.wantFallback, tt.wantLive)
+			}
+		})
+	}
+
+	if _, live := scheduled(nil, from); !live {
+		t.Error("Expected a link without a schedule to be live")
+	}
+}
+
+func TestVisitSchedule(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	visit := func(link *staticLink) *httptest.ResponseRecorder {
+		h := NewRedirectHandler(nil, link, discardClicks{}, nil, core.CodeFormat{Length: 7}, nil, slog.Default())
+		r := httptest.NewRequest(http.MethodGet, "/abc1234", nil)
+		r.SetPathValue("code", "abc1234")
+		rec := httptest.NewRecorder()
+		h.Visit(rec, r)
+		return rec
+	}
+
+	rec := visit(&staticLink{
+		OriginalURL: "https://example.com/launch",
+		Schedule:    &models.Schedule{ActiveFrom: &future, BeforeURL: "https://example.com/soon"},
+	})
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://example.com/soon" {
+		t.Errorf("Expected the coming soon page before launch, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = visit(&staticLink{
+		OriginalURL: "https://example.com/launch",
+		Schedule:    &models.Schedule{ActiveFrom: &past, ActiveUntil: &future},
+	})
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://example.com/launch" {
+		t.Errorf("Expected the destination inside the window, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = visit(&staticLink{
+		OriginalURL: "https://example.com/launch",
+		Schedule:    &models.Schedule{ActiveUntil: &past},
+	})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected an expired link without an after URL to be not found, got %d", rec.Code)
+	}
+}
+
+func TestHandleRedirectSchedule(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	link := &staticLink{
+		OriginalURL: "https://example.com/sale",
+		Schedule:    &models.Schedule{ActiveUntil: &past, AfterURL: "https://example.com/sale-over"},
+	}
+	h := NewRedirectHandler(nil, link, discardClicks{}, nil, core.CodeFormat{Length: 7}, nil, slog.Default())
+
+	r := httptest.NewRequest(http.MethodPost, "/shortUrl/get", strings.NewReader(`{"shortenUrl":"abc1234"}`))
+	rec := httptest.NewRecorder()
+	h.HandleRedirect(rec, r)
+
+	var response models.GetOriginalUrlResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.OriginalURL != "https://example.com/sale-over" {
+		t.Errorf("Expected the after URL, got %+v", response)
+	}
+}
@@ -5,43 +5,66 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/dev4dreams/dev4url/internal/core"
 	"github.com/dev4dreams/dev4url/internal/db"
+	"github.com/dev4dreams/dev4url/internal/logging"
+	"github.com/dev4dreams/dev4url/internal/metrics"
 	"github.com/dev4dreams/dev4url/internal/middleware"
 	"github.com/dev4dreams/dev4url/internal/models"
 	"github.com/dev4dreams/dev4url/internal/services/safebrowsing"
+	"github.com/dev4dreams/dev4url/internal/tracing"
 	"github.com/dev4dreams/dev4url/internal/utils"
 	"github.com/getsentry/sentry-go"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// MetadataRefresher fetches the metadata of a link's destination in the
+// background, see metadata.Service
+type MetadataRefresher interface {
+	Refresh(code, destination string)
+}
+
 type URLHandler struct {
 	UrlValidator utils.URLValidatorInterface
+	AppValidator *utils.AppURLValidator
 	SafeBrowsing safebrowsing.SafeBrowsingChecker
-	Shortener    *core.Generator
+	Shortener    core.CodeGenerator
 	BaseURL      string
 	Db           db.DatabaseInterface
+	Cache        CacheInvalidator  // nil without a resolve cache
+	Metadata     MetadataRefresher // nil when metadata fetching is disabled
+	Logger       *slog.Logger
 }
 
 func NewURLHandler(
 	validator utils.URLValidatorInterface,
+	appValidator *utils.AppURLValidator,
 	safeBrowsing safebrowsing.SafeBrowsingChecker,
-	shortener *core.Generator,
+	shortener core.CodeGenerator,
 	baseURL string,
 	db db.DatabaseInterface,
+	cache CacheInvalidator,
+	metadata MetadataRefresher,
+	logger *slog.Logger,
 ) *URLHandler {
 	return &URLHandler{
 		UrlValidator: validator,
+		AppValidator: appValidator,
 		SafeBrowsing: safeBrowsing,
 		Shortener:    shortener,
 		BaseURL:      baseURL,
 		Db:           db,
+		Cache:        cache,
+		Metadata:     metadata,
+		Logger:       logger,
 	}
 }
 
 func (h *URLHandler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), h.Logger)
 	hub := sentry.GetHubFromContext((r.Context()))
 
 	if hub == nil {
@@ -54,7 +77,7 @@ func (h *URLHandler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 
 	// Only allow POST method
 	if r.Method != http.MethodPost {
-		middleware.CaptureError(fmt.Errorf("method not allowed: %s", r.Method), map[string]string{
+		middleware.CaptureError(r.Context(), fmt.Errorf("method not allowed: %s", r.Method), map[string]string{
 			"error_type": "method_not_allowed",
 			"method":     r.Method,
 		})
@@ -65,24 +88,39 @@ func (h *URLHandler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
 	var req models.CreateUrlRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		middleware.CaptureError(err, map[string]string{
+		middleware.CaptureError(r.Context(), err, map[string]string{
 			"error_type": "invalid_request",
 			"error_step": "body_decode",
 		})
+		metrics.URLCreations.WithLabelValues(metrics.OutcomeInvalidRequest).Inc()
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	if err := validateUTM(req.UTM); err != nil {
+		metrics.URLCreations.WithLabelValues(metrics.OutcomeInvalidRequest).Inc()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Validate original URL
-	validationResult := h.UrlValidator.ValidateURL(r.Context(), req.OriginalURL)
+	validateCtx, validateSpan := tracing.Start(r.Context(), "url.validate")
+	validationResult := h.UrlValidator.ValidateURL(validateCtx, req.OriginalURL)
+	validateSpan.SetAttributes(attribute.Bool("url.valid", validationResult.IsValid))
+	validateSpan.End()
 	if !validationResult.IsValid {
+		logger.Info("URL validation failed",
+			slog.String("original_url", req.OriginalURL),
+			slog.Any("errors", validationResult.Errors))
 		middleware.CaptureError(
+			r.Context(),
 			fmt.Errorf("URL validation failed: %v", validationResult.Errors),
 			map[string]string{
 				"error_type":   "validation_error",
 				"original_url": req.OriginalURL,
 			},
 		)
+		metrics.URLCreations.WithLabelValues(metrics.OutcomeValidation).Inc()
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"error":  "URL validation failed",
@@ -91,30 +129,60 @@ func (h *URLHandler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 
 		return
 	}
+	if len(validationResult.Warnings) > 0 {
+		logger.Warn("URL matched warning rules",
+			slog.String("original_url", req.OriginalURL),
+			slog.Any("warnings", validationResult.Warnings))
+	}
 
 	// Check if URL is safe
-	isSafe, err := h.SafeBrowsing.IsURLSafe(req.OriginalURL)
+	isSafe, err := h.SafeBrowsing.IsURLSafe(r.Context(), req.OriginalURL)
 	if err != nil {
-		middleware.CaptureError(err, map[string]string{
+		middleware.CaptureError(r.Context(), err, map[string]string{
 			"error_type":   "safebrowsing_error",
 			"original_url": req.OriginalURL,
 		})
-		log.Printf("SafeBrowsing check failed: %v", err)
+		logger.Error("SafeBrowsing check failed", slog.Any("error", err))
+		metrics.URLCreations.WithLabelValues(metrics.OutcomeSafeBrowsingErr).Inc()
+		if errors.Is(err, safebrowsing.ErrCircuitOpen) {
+			http.Error(w, "URL safety checks are temporarily unavailable, please try again", http.StatusServiceUnavailable)
+			return
+		}
 		http.Error(w, "Error checking URL safety", http.StatusInternalServerError)
 		return
 	}
 	if !isSafe {
 		middleware.CaptureError(
+			r.Context(),
 			fmt.Errorf("unsafe URL detected: %s", req.OriginalURL),
 			map[string]string{
 				"error_type":   "unsafe_url",
 				"original_url": req.OriginalURL,
 			},
 		)
+		logger.Warn("Unsafe URL detected", slog.String("original_url", req.OriginalURL))
+		metrics.URLCreations.WithLabelValues(metrics.OutcomeUnsafe).Inc()
 		http.Error(w, "URL detected as potentially harmful", http.StatusBadRequest)
 		return
 	}
 
+	if outcome := checkTargets(w, r, h.UrlValidator, h.SafeBrowsing, req.Targets, h.Logger); outcome != "" {
+		metrics.URLCreations.WithLabelValues(outcome).Inc()
+		return
+	}
+	if outcome := checkVariants(w, r, h.UrlValidator, h.SafeBrowsing, req.Variants, h.Logger); outcome != "" {
+		metrics.URLCreations.WithLabelValues(outcome).Inc()
+		return
+	}
+	if outcome := checkAppLinks(w, r, h.UrlValidator, h.SafeBrowsing, h.AppValidator, req.AppLinks, h.Logger); outcome != "" {
+		metrics.URLCreations.WithLabelValues(outcome).Inc()
+		return
+	}
+	if outcome := checkSchedule(w, r, h.UrlValidator, h.SafeBrowsing, req.Schedule, h.Logger); outcome != "" {
+		metrics.URLCreations.WithLabelValues(outcome).Inc()
+		return
+	}
+
 	// Handle custom URL if provided
 	var shortCode string
 	if req.CustomURL != "" {
@@ -125,6 +193,7 @@ func (h *URLHandler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 		// 3. Use it if valid and available
 		// For now, we'll return an error as it's not implemented
 		middleware.CaptureError(
+			r.Context(),
 			fmt.Errorf("custom URL requested but not implemented"),
 			map[string]string{
 				"error_type": "not_implemented",
@@ -134,7 +203,10 @@ func (h *URLHandler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Custom URLs not implemented yet", http.StatusNotImplemented)
 		return
 	} else {
-		shortCode, err = h.Shortener.GenerateShortURL()
+		generateCtx, generateSpan := tracing.Start(r.Context(), "shortcode.generate")
+		shortCode, err = h.Shortener.Generate(generateCtx)
+		tracing.RecordError(generateSpan, err)
+		generateSpan.End()
 		if err != nil {
 			var statusCode int
 			var message string
@@ -143,18 +215,20 @@ func (h *URLHandler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 			case errors.Is(err, core.ErrInvalidWorkerID):
 				statusCode = http.StatusInternalServerError
 				message = "Server configuration error"
-			case errors.Is(err, core.ErrClockMovedBackwards):
+			case errors.Is(err, core.ErrClockMovedBackwards), errors.Is(err, core.ErrTooManyCollisions):
 				statusCode = http.StatusServiceUnavailable
 				message = "Temporary server error, please try again"
 			default:
 				statusCode = http.StatusInternalServerError
 				message = "Internal server error"
 			}
-			middleware.CaptureError(err, map[string]string{
+			middleware.CaptureError(r.Context(), err, map[string]string{
 				"error_type":   "shortcode_generation",
 				"error_detail": err.Error(),
 				"status_code":  fmt.Sprintf("%d", statusCode),
 			})
+			logger.Error("Short code generation failed", slog.Any("error", err))
+			metrics.URLCreations.WithLabelValues(metrics.OutcomeGenerationErr).Inc()
 			http.Error(w, message, statusCode)
 			return
 		}
@@ -162,33 +236,59 @@ func (h *URLHandler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 	}
 
 	urlPayload := &models.CreateUrlPayload{
-		ShortenUrl:  shortCode,
-		OriginalUrl: req.OriginalURL,
-		CustomUrl:   req.CustomURL,
+		ShortenUrl:     shortCode,
+		OriginalUrl:    req.OriginalURL,
+		CustomUrl:      req.CustomURL,
+		Interstitial:   req.Interstitial,
+		UTM:            req.UTM,
+		ForwardQuery:   req.ForwardQuery,
+		Targets:        req.Targets,
+		Variants:       req.Variants,
+		StickyVariants: req.StickyVariants,
+		AppLinks:       req.AppLinks,
+		Schedule:       req.Schedule,
 	}
 
-	dbResponse, err := h.Db.CreateURL(urlPayload)
+	dbResponse, err := h.Db.CreateURL(r.Context(), urlPayload)
 	if err != nil {
-		middleware.CaptureError(err, map[string]string{
+		middleware.CaptureError(r.Context(), err, map[string]string{
 			"error_type":   "database_error",
 			"error_step":   "create_url",
 			"original_url": req.OriginalURL,
 			"short_code":   shortCode,
 		})
+		logger.Error("Failed to save URL",
+			slog.String("short_code", shortCode), slog.Any("error", err))
+		metrics.URLCreations.WithLabelValues(metrics.OutcomeDBError).Inc()
 		http.Error(w, "Error saving URL to database", http.StatusInternalServerError)
 		return
 	}
 
+	metrics.URLCreations.WithLabelValues(metrics.OutcomeCreated).Inc()
+	// Codes are predictable, so the new one may have been looked up and
+	// cached as a miss already
+	if h.Cache != nil {
+		if err := h.Cache.Invalidate(r.Context(), dbResponse.ShortURL); err != nil {
+			logger.Error("Failed to invalidate cached link, it expires with its TTL",
+				slog.String("short_code", dbResponse.ShortURL), slog.Any("error", err))
+		}
+	}
+	if h.Metadata != nil {
+		h.Metadata.Refresh(dbResponse.ShortURL, dbResponse.OriginalURL)
+	}
+
 	// Construct full short URL
 	fullShortURL := h.BaseURL + "/" + dbResponse.ShortURL
-	fmt.Printf("ShortURL created: %v", fullShortURL)
+	logger.Info("Short URL created",
+		slog.String("short_url", fullShortURL),
+		slog.String("original_url", req.OriginalURL))
 	w.Header().Set("Content-Type", "application/json")
 	response := models.CreateUrlResponse{
 		ShortenUrl: fullShortURL,
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		middleware.CaptureError(err, map[string]string{
+		middleware.CaptureError(r.Context(), err, map[string]string{
 			"error_type": "response_encoding",
 			"short_url":  fullShortURL,
 		})
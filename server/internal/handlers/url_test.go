@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/internal/services/safebrowsing"
+	"github.com/dev4dreams/dev4url/internal/utils"
+)
+
+type acceptAll struct{}
+
+func (acceptAll) ValidateURL(ctx context.Context, urlStr string) *utils.ValidationResult {
+	return &utils.ValidationResult{IsValid: true}
+}
+
+type safeURLs struct{}
+
+func (safeURLs) IsURLSafe(ctx context.Context, url string) (bool, error) { return true, nil }
+
+func (safeURLs) CheckURL(ctx context.Context, url string) (*safebrowsing.ThreatResponse, error) {
+	return &safebrowsing.ThreatResponse{}, nil
+}
+
+type fixedCode string
+
+func (c fixedCode) Generate(ctx context.Context) (string, error) { return string(c), nil }
+
+// memoryDB stores created links in a map
+type memoryDB map[string]*models.CreateUrlPayload
+
+func (m memoryDB) CreateURL(ctx context.Context, payload *models.CreateUrlPayload) (*models.URLResponse, error) {
+	m[payload.ShortenUrl] = payload
+	return &models.URLResponse{ShortURL: payload.ShortenUrl, OriginalURL: payload.OriginalUrl}, nil
+}
+
+func (m memoryDB) ResolveLink(ctx context.Context, shortURL string) (*models.ResolvedLink, error) {
+	return nil, nil
+}
+
+func (memoryDB) Close() error            { return nil }
+func (memoryDB) VerifyConnection() error { return nil }
+
+type recordingInvalidator []string
+
+func (r *recordingInvalidator) Invalidate(ctx context.Context, code string) error {
+	*r = append(*r, code)
+	return nil
+}
+
+// A code looked up before it was issued is cached as a miss, which
+// creating it must clear
+func TestCreateShortURLInvalidatesCache(t *testing.T) {
+	var cache recordingInvalidator
+	h := NewURLHandler(acceptAll{}, nil, safeURLs{}, fixedCode("abc1234"), "https://d4u.example",
+		memoryDB{}, &cache, nil, slog.Default())
+
+	r := httptest.NewRequest(http.MethodPost, "/shortUrl/post", strings.NewReader(`{"original_url":"https://example.com"}`))
+	rec := httptest.NewRecorder()
+	h.CreateShortURL(rec, r)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if len(cache) != 1 || cache[0] != "abc1234" {
+		t.Errorf("Expected the new code to be invalidated, got %v", cache)
+	}
+}
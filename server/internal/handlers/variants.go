@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/internal/services/targeting"
+)
+
+// Split link limits
+const (
+	maxVariants          = 20
+	maxVariantWeight     = 10000
+	maxVariantNameLength = 64
+)
+
+// Sticky variants are remembered per code in a cookie
+const (
+	variantCookiePrefix = "d4u_v_"
+	variantCookieMaxAge = 30 * 24 * time.Hour
+)
+
+// validateVariants checks split destinations given at creation or update.
+// The URLs are left to the URL validator.
+func validateVariants(variants []models.Variant) error {
+	if len(variants) > maxVariants {
+		return fmt.Errorf("at most %d variants are allowed", maxVariants)
+	}
+	if len(variants) == 1 {
+		return fmt.Errorf("a split needs at least two variants")
+	}
+	seen := make(map[string]bool, len(variants))
+	for i, variant := range variants {
+		if !isVariantName(variant.Name) {
+			return fmt.Errorf("variants[%d]: name must be 1 to %d letters, digits, - or _", i, maxVariantNameLength)
+		}
+		if seen[variant.Name] {
+			return fmt.Errorf("variants[%d]: name %q is used twice", i, variant.Name)
+		}
+		seen[variant.Name] = true
+		if variant.URL == "" {
+			return fmt.Errorf("variants[%d]: url is required", i)
+		}
+		if variant.Weight < 1 || variant.Weight > maxVariantWeight {
+			return fmt.Errorf("variants[%d]: weight must be between 1 and %d", i, maxVariantWeight)
+		}
+	}
+	return nil
+}
+
+func isVariantName(name string) bool {
+	if name == "" || len(name) > maxVariantNameLength {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// pickVariant returns the variant named assigned when the link still has
+// it, and otherwise draws one by weight. roll returns a number in [0, n).
+func pickVariant(variants []models.Variant, assigned string, roll func(n int) int) (models.Variant, bool) {
+	if len(variants) == 0 {
+		return models.Variant{}, false
+	}
+	total := 0
+	for _, variant := range variants {
+		if assigned != "" && variant.Name == assigned {
+			return variant, true
+		}
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return variants[0], true
+	}
+
+	n := roll(total)
+	for _, variant := range variants {
+		if n < variant.Weight {
+			return variant, true
+		}
+		n -= variant.Weight
+	}
+	return variants[len(variants)-1], true
+}
+
+// choose picks where link sends the visitor making r: the first targeting
+// rule they match, or else one of the variants, or else the original URL.
+// assigned is the variant the visitor was served before, kept when the
+// link still has it; callers pass it for sticky links only. The variant
+// served is "" when none was.
+func (h *RedirectHandler) choose(r *http.Request, link *models.ResolvedLink, assigned string) (destination, variant string) {
+	if len(link.Targets) > 0 {
+		if target, ok := targeting.Destination(link.Targets, h.locator.Visitor(r)); ok {
+			return target, ""
+		}
+	}
+	if v, ok := pickVariant(link.Variants, assigned, rand.IntN); ok {
+		return v.URL, v.Name
+	}
+	return link.OriginalURL, ""
+}
+
+// assignedVariant returns the variant remembered for code in the visitor's
+// cookie
+func assignedVariant(r *http.Request, code string) string {
+	cookie, err := r.Cookie(variantCookiePrefix + code)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// rememberVariant keeps the variant served for code in a cookie, so sticky
+// links send the visitor there again
+func rememberVariant(w http.ResponseWriter, code, variant string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     variantCookiePrefix + code,
+		Value:    variant,
+		Path:     "/",
+		MaxAge:   int(variantCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
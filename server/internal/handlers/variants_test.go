@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dev4dreams/dev4url/internal/core"
+	"github.com/dev4dreams/dev4url/internal/models"
+)
+
+func TestValidateVariants(t *testing.T) {
+	valid := []models.Variant{
+		{Name: "control", URL: "https://example.com/a", Weight: 50},
+		{Name: "new-hero_2", URL: "https://example.com/b", Weight: 50},
+	}
+	tests := []struct {
+		name     string
+		variants []models.Variant
+		wantErr  bool
+	}{
+		{"none", nil, false},
+		{"valid", valid, false},
+		{"single", valid[:1], true},
+		{"duplicate name", []models.Variant{valid[0], valid[0]}, true},
+		{"bad name", []models.Variant{valid[0], {Name: "b c", URL: "https://example.com", Weight: 1}}, true},
+		{"no url", []models.Variant{valid[0], {Name: "b", Weight: 1}}, true},
+		{"zero weight", []models.Variant{valid[0], {Name: "b", URL: "https://example.com"}}, true},
+		{"weight too high", []models.Variant{valid[0], {Name: "b", URL: "https://example.com", Weight: maxVariantWeight + 1}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateVariants(tt.variants); (err != nil) != tt.wantErr {
+				t.Errorf("validateVariants() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPickVariant(t *testing.T) {
+	variants := []models.Variant{
+		{Name: "a", URL: "https://example.com/a", Weight: 1},
+		{Name: "b", URL: "https://example.com/b", Weight: 3},
+	}
+
+	// Rolls 0 to 3 cover the total weight of 4 once
+	counts := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		v, ok := pickVariant(variants, "", func(n int) int { return i % n })
+		if !ok {
+			t.Fatal("Expected a variant")
+		}
+		counts[v.Name]++
+	}
+	if counts["a"] != 1 || counts["b"] != 3 {
+		t.Errorf("Expected picks in proportion to weight, got %v", counts)
+	}
+
+	never := func(int) int { t.Fatal("Expected no roll for an assigned variant"); return 0 }
+	if v, _ := pickVariant(variants, "a", never); v.Name != "a" {
+		t.Errorf("Expected the assigned variant, got %s", v.Name)
+	}
+	if v, _ := pickVariant(variants, "removed", func(int) int { return 3 }); v.Name != "b" {
+		t.Errorf("Expected a new draw for a removed variant, got %s", v.Name)
+	}
+	if _, ok := pickVariant(nil, "a", never); ok {
+		t.Error("Expected no variant without variants")
+	}
+}
+
+func TestVisitStickyVariant(t *testing.T) {
+	link := &staticLink{
+		OriginalURL:    "https://example.com",
+		StickyVariants: true,
+		Variants: []models.Variant{
+			{Name: "a", URL: "https://example.com/a", Weight: 1},
+			{Name: "b", URL: "https://example.com/b", Weight: 1},
+		},
+	}
+	h := NewRedirectHandler(nil, link, discardClicks{}, nil, core.CodeFormat{Length: 7}, nil, slog.Default())
+
+	r := httptest.NewRequest(http.MethodGet, "/abc1234", nil)
+	r.SetPathValue("code", "abc1234")
+	r.AddCookie(&http.Cookie{Name: variantCookiePrefix + "abc1234", Value: "b"})
+	rec := httptest.NewRecorder()
+	h.Visit(rec, r)
+
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://example.com/b" {
+		t.Fatalf("Expected a redirect to variant b, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != variantCookiePrefix+"abc1234" || cookies[0].Value != "b" {
+		t.Errorf("Expected the variant cookie to be renewed, got %v", cookies)
+	}
+}
+
+func TestHandleRedirectVariant(t *testing.T) {
+	link := &staticLink{
+		OriginalURL: "https://example.com",
+		Variants: []models.Variant{
+			{Name: "a", URL: "https://example.com/a", Weight: 1},
+			{Name: "b", URL: "https://example.com/b", Weight: 1},
+		},
+	}
+	h := NewRedirectHandler(nil, link, discardClicks{}, nil, core.CodeFormat{Length: 7}, nil, slog.Default())
+
+	r := httptest.NewRequest(http.MethodPost, "/shortUrl/get", strings.NewReader(`{"shortenUrl":"abc1234","variant":"b"}`))
+	rec := httptest.NewRecorder()
+	h.HandleRedirect(rec, r)
+
+	var response models.GetOriginalUrlResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	want := map[string]string{"a": "https://example.com/a", "b": "https://example.com/b"}
+	if response.Variant == "" || want[response.Variant] != response.OriginalURL {
+		t.Errorf("Expected a variant and its URL, got %+v", response)
+	}
+}
+
+func TestLinkStats(t *testing.T) {
+	link := &models.URLResponse{
+		ShortURL: "abc1234",
+		Clicks:   10,
+		Variants: []models.Variant{
+			{Name: "a", URL: "https://example.com/a", Weight: 1},
+			{Name: "b", URL: "https://example.com/b", Weight: 3},
+		},
+	}
+	stats := linkStats(link, map[string]int64{"a": 2, "old": 2, "b": 4})
+
+	want := []models.VariantStats{
+		{Name: "a", URL: "https://example.com/a", Weight: 1, Clicks: 2, Share: 0.25},
+		{Name: "b", URL: "https://example.com/b", Weight: 3, Clicks: 4, Share: 0.5},
+		{Name: "old", Clicks: 2, Share: 0.25},
+	}
+	if stats.Clicks != 10 || len(stats.Variants) != len(want) {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+	for i := range want {
+		if stats.Variants[i] != want[i] {
+			t.Errorf("Variant %d: expected %+v, got %+v", i, want[i], stats.Variants[i])
+		}
+	}
+}
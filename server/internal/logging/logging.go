@@ -0,0 +1,41 @@
+// internal/logging/logging.go
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+type contextKey struct{}
+
+// New creates a JSON logger. Development environments log at debug level.
+func New(w io.Writer, environment string, level string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil || level == "" {
+		lvl = slog.LevelInfo
+		if environment == "development" {
+			lvl = slog.LevelDebug
+		}
+	}
+
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl})
+	return slog.New(handler).With(slog.String("service", "urlshortener-service"))
+}
+
+// NewContext returns a copy of ctx carrying the request scoped logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the request scoped logger, or fallback when the
+// context carries none. A nil fallback resolves to slog.Default().
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	if fallback == nil {
+		return slog.Default()
+	}
+	return fallback
+}
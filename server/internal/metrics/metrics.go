@@ -0,0 +1,230 @@
+// internal/metrics/metrics.go
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "dev4url"
+
+// Registry holds every collector exposed on /metrics. A dedicated registry
+// keeps tests and multiple servers from colliding on the global one.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests counts served requests per route, method and status
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPRequestDuration observes request latency per route, method and status
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// Redirects counts short code lookups by result (hit, miss, error,
+	// malformed)
+	Redirects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirects_total",
+		Help:      "Short code resolutions by result.",
+	}, []string{"result"})
+
+	// URLCreations counts short URL creation attempts by outcome
+	URLCreations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "url_creations_total",
+		Help:      "Short URL creation attempts by outcome.",
+	}, []string{"outcome"})
+
+	// SafeBrowsingDuration observes the latency of Safe Browsing API calls
+	SafeBrowsingDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "safebrowsing_request_duration_seconds",
+		Help:      "Latency of Google Safe Browsing API calls.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2, 4, 6, 10},
+	})
+
+	// SafeBrowsingErrors counts failed Safe Browsing API calls
+	SafeBrowsingErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "safebrowsing_errors_total",
+		Help:      "Failed Google Safe Browsing API calls.",
+	})
+
+	// RateLimitRejections counts requests rejected by the IP rate limiter
+	RateLimitRejections = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by the per-IP rate limiter.",
+	})
+
+	// ValidatorReloads counts validator rule reloads by result
+	ValidatorReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "validator_rule_reloads_total",
+		Help:      "Validator rule reloads by result.",
+	}, []string{"result"})
+
+	// GeneratorSequenceWaits counts how often the ID generator exhausted its
+	// sequence and had to wait for the next millisecond
+	GeneratorSequenceWaits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "generator_sequence_exhausted_total",
+		Help:      "Times the short code generator waited for the next millisecond.",
+	})
+
+	// GeneratorClockBackwards counts small backward clock steps the ID
+	// generator absorbed instead of failing
+	GeneratorClockBackwards = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "generator_clock_backwards_total",
+		Help:      "Backward clock steps absorbed by the short code generator.",
+	})
+
+	// GeneratorCollisions counts random short codes that were already taken
+	GeneratorCollisions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "generator_collisions_total",
+		Help:      "Random short codes discarded because they were already taken.",
+	})
+
+	// ResolveCacheLookups counts resolve cache lookups by result (hit,
+	// negative_hit, miss)
+	ResolveCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "resolve_cache_lookups_total",
+		Help:      "Short code resolve cache lookups by result.",
+	}, []string{"result"})
+
+	// ResolveCacheErrors counts failed shared cache operations
+	ResolveCacheErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "resolve_cache_errors_total",
+		Help:      "Failed shared resolve cache operations.",
+	})
+
+	// ClickFlushErrors counts failed flushes of buffered clicks
+	ClickFlushErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "click_flush_errors_total",
+		Help:      "Failed flushes of buffered click counts.",
+	})
+
+	// ClicksDropped counts clicks lost because the buffer was full
+	ClicksDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "clicks_dropped_total",
+		Help:      "Clicks dropped because the click buffer was full.",
+	})
+
+	// ClicksPending is the number of buffered clicks not yet written
+	ClicksPending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "clicks_pending",
+		Help:      "Buffered clicks not yet written to the database.",
+	})
+
+	// ClickFlushLag is how old the oldest click was at the last flush
+	ClickFlushLag = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "click_flush_lag_seconds",
+		Help:      "Age of the oldest buffered click at the last flush.",
+	})
+
+	// MetadataFetches counts destination metadata fetches by result
+	MetadataFetches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "metadata_fetches_total",
+		Help:      "Destination metadata fetches by result.",
+	}, []string{"result"})
+
+	// QRCodes counts rendered QR codes by image format
+	QRCodes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "qr_codes_total",
+		Help:      "Rendered QR codes by image format.",
+	}, []string{"format"})
+)
+
+// Outcome labels for URLCreations
+const (
+	OutcomeCreated         = "created"
+	OutcomeInvalidRequest  = "invalid_request"
+	OutcomeValidation      = "validation_failure"
+	OutcomeUnsafe          = "unsafe"
+	OutcomeSafeBrowsingErr = "safebrowsing_error"
+	OutcomeGenerationErr   = "generation_error"
+	OutcomeDBError         = "db_error"
+)
+
+// Result labels for Redirects
+const (
+	ResultHit   = "hit"
+	ResultMiss  = "miss"
+	ResultError = "error"
+	// ResultMalformed is a code rejected by its check character
+	ResultMalformed = "malformed"
+	// ResultScheduled is a link visited outside its schedule window
+	ResultScheduled = "scheduled"
+)
+
+// Result labels for MetadataFetches
+const (
+	MetadataFetched = "fetched"
+	MetadataError   = "error"
+	MetadataSkipped = "skipped"
+)
+
+// Result labels for ResolveCacheLookups
+const (
+	CacheHit         = "hit"
+	CacheNegativeHit = "negative_hit"
+	CacheMiss        = "miss"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		Redirects,
+		URLCreations,
+		SafeBrowsingDuration,
+		SafeBrowsingErrors,
+		RateLimitRejections,
+		GeneratorSequenceWaits,
+		GeneratorClockBackwards,
+		GeneratorCollisions,
+		ValidatorReloads,
+		ResolveCacheLookups,
+		ResolveCacheErrors,
+		ClickFlushErrors,
+		ClicksDropped,
+		ClicksPending,
+		ClickFlushLag,
+		MetadataFetches,
+		QRCodes,
+	)
+}
+
+// RegisterDBStats exposes sql.DB.Stats() as connection pool gauges
+func RegisterDBStats(db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
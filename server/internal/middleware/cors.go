@@ -3,32 +3,191 @@ package middleware
 
 import (
 	"net/http"
-	"os"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func CORS(next http.Handler) http.Handler {
+// CORSConfig holds the cross-origin settings shared by every route
+type CORSConfig struct {
+	// AllowedOrigins lists exact origins ("https://dev4url.cc") and wildcard
+	// subdomain patterns ("https://*.dev4url.cc"). A single "*" allows any
+	// origin, in which case credentials are never allowed.
+	AllowedOrigins   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// originPattern is a parsed entry of CORSConfig.AllowedOrigins
+type originPattern struct {
+	scheme string
+	host   string // host[:port], without the "*." prefix for wildcards
+	// wildcard matches any subdomain of host, but not host itself
+	wildcard bool
+}
+
+// CORS answers preflight requests and echoes back allowed origins
+type CORS struct {
+	origins          []originPattern
+	allowAny         bool
+	allowedHeaders   string
+	allowCredentials bool
+	maxAge           string
+}
+
+// NewCORS creates a CORS middleware from the given config.
+// Malformed origin entries are ignored.
+func NewCORS(cfg CORSConfig) *CORS {
+	c := &CORS{
+		allowCredentials: cfg.AllowCredentials,
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		if origin == "*" {
+			c.allowAny = true
+			continue
+		}
+		if pattern, ok := parseOriginPattern(origin); ok {
+			c.origins = append(c.origins, pattern)
+		}
+	}
+
+	// Browsers reject a wildcard origin combined with credentials
+	if c.allowAny {
+		c.allowCredentials = false
+	}
+
+	headers := cfg.AllowedHeaders
+	if len(headers) == 0 {
+		headers = []string{"Content-Type", "Authorization"}
+	}
+	c.allowedHeaders = strings.Join(headers, ", ")
+
+	if cfg.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+
+	return c
+}
+
+// parseOriginPattern parses "scheme://host[:port]" with an optional "*." host prefix
+func parseOriginPattern(origin string) (originPattern, bool) {
+	scheme, host, found := strings.Cut(strings.ToLower(origin), "://")
+	if !found || scheme == "" || host == "" || strings.Contains(host, "/") {
+		return originPattern{}, false
+	}
+
+	pattern := originPattern{scheme: scheme, host: host}
+	if strings.HasPrefix(host, "*.") {
+		pattern.wildcard = true
+		pattern.host = host[2:]
+	}
+	if pattern.host == "" || strings.Contains(pattern.host, "*") {
+		return originPattern{}, false
+	}
+
+	return pattern, true
+}
+
+// matches reports whether the parsed request origin is covered by the pattern
+func (p originPattern) matches(scheme, host string) bool {
+	if scheme != p.scheme {
+		return false
+	}
+	if !p.wildcard {
+		return host == p.host
+	}
+	return strings.HasSuffix(host, "."+p.host)
+}
+
+// isAllowedOrigin checks the Origin header against the configured patterns
+func (c *CORS) isAllowedOrigin(origin string) bool {
+	if c.allowAny {
+		return true
+	}
+
+	parsed, err := url.Parse(strings.ToLower(origin))
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return false
+	}
+
+	for _, pattern := range c.origins {
+		if pattern.matches(parsed.Scheme, parsed.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow wraps a route so that only the given methods reach it. OPTIONS
+// preflight requests are answered here and never reach the handler.
+func (c *CORS) Allow(next http.Handler, methods ...string) http.Handler {
+	allowed := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		allowed[strings.ToUpper(method)] = true
+	}
+	allowMethods := strings.Join(append(slices.Clone(methods), http.MethodOptions), ", ")
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		allowedOrigins := os.Getenv("ALLOWED_ORIGINS")
+		// Responses differ per origin, so shared caches must key on it
+		w.Header().Add("Vary", "Origin")
 
-		w.Header().Set("Access-Control-Allow-Origin", allowedOrigins)
+		origin := r.Header.Get("Origin")
+		originAllowed := origin != "" && c.isAllowedOrigin(origin)
 
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		if originAllowed {
+			if c.allowAny {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if c.allowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
 
 		// Handle preflight requests
 		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+			requested := r.Header.Get("Access-Control-Request-Method")
+			if requested == "" {
+				// Plain OPTIONS request, not a preflight
+				w.Header().Set("Allow", allowMethods)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+			if !originAllowed || !allowed[strings.ToUpper(requested)] {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", c.allowedHeaders)
+			if c.maxAge != "" {
+				w.Header().Set("Access-Control-Max-Age", c.maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
-		// Handle the actual request
-		if r.Method == http.MethodGet || r.Method == http.MethodPost {
-			next.ServeHTTP(w, r)
+		// Reject methods this route does not serve
+		if !allowed[r.Method] {
+			w.Header().Set("Allow", allowMethods)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		// If method is neither OPTIONS, GET, nor POST
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		next.ServeHTTP(w, r)
 	})
 }
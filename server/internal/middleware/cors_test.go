@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSOrigins(t *testing.T) {
+	cors := NewCORS(CORSConfig{
+		AllowedOrigins:   []string{"https://dev4url.cc", "https://*.dev4url.cc", "http://localhost:3000"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	handler := cors.Allow(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), http.MethodPost)
+
+	tests := []struct {
+		name        string
+		origin      string
+		wantAllowed bool
+	}{
+		{name: "exact origin", origin: "https://dev4url.cc", wantAllowed: true},
+		{name: "wildcard subdomain", origin: "https://app.dev4url.cc", wantAllowed: true},
+		{name: "nested subdomain", origin: "https://a.b.dev4url.cc", wantAllowed: true},
+		{name: "origin with port", origin: "http://localhost:3000", wantAllowed: true},
+		{name: "wrong scheme", origin: "http://dev4url.cc", wantAllowed: false},
+		{name: "wrong port", origin: "http://localhost:4000", wantAllowed: false},
+		{name: "suffix without dot", origin: "https://evildev4url.cc", wantAllowed: false},
+		{name: "unknown origin", origin: "https://example.org", wantAllowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/shortUrl/post", nil)
+			req.Header.Set("Origin", tt.origin)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get("Access-Control-Allow-Origin")
+			if tt.wantAllowed && got != tt.origin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.origin, got)
+			}
+			if !tt.wantAllowed && got != "" {
+				t.Errorf("Expected no Access-Control-Allow-Origin, got %q", got)
+			}
+			if rec.Header().Get("Vary") != "Origin" {
+				t.Errorf("Expected Vary: Origin, got %q", rec.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	cors := NewCORS(CORSConfig{
+		AllowedOrigins: []string{"https://dev4url.cc"},
+		MaxAge:         10 * time.Minute,
+	})
+	called := false
+	handler := cors.Allow(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), http.MethodPost)
+
+	req := httptest.NewRequest(http.MethodOptions, "/shortUrl/post", nil)
+	req.Header.Set("Origin", "https://dev4url.cc")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Expected Access-Control-Max-Age 600, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "POST, OPTIONS" {
+		t.Errorf("Expected Access-Control-Allow-Methods %q, got %q", "POST, OPTIONS", got)
+	}
+	if called {
+		t.Error("Preflight request should not reach the handler")
+	}
+
+	// Methods outside the route's set are refused
+	req = httptest.NewRequest(http.MethodOptions, "/shortUrl/post", nil)
+	req.Header.Set("Origin", "https://dev4url.cc")
+	req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for DELETE preflight, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/shortUrl/post", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for GET, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestCORSWildcardDisablesCredentials(t *testing.T) {
+	cors := NewCORS(CORSConfig{
+		AllowedOrigins:   []string{"*"},
+		AllowCredentials: true,
+	})
+	handler := cors.Allow(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), http.MethodGet)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Origin", "https://anything.example")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected Access-Control-Allow-Origin *, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Expected no Access-Control-Allow-Credentials, got %q", got)
+	}
+}
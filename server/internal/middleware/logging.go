@@ -0,0 +1,48 @@
+// internal/middleware/logging.go
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/logging"
+)
+
+// AccessLog writes one structured log line per request with its status and
+// latency, using the request scoped logger set by RequestID. The route is
+// the pattern of routes the request matches.
+func AccessLog(logger *slog.Logger, routes *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(rec, r)
+
+			level := slog.LevelInfo
+			if rec.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			logging.FromContext(r.Context(), logger).LogAttrs(r.Context(), level, "request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", matchedRoute(routes, r)),
+				slog.Int("status", rec.status),
+				slog.Int("bytes", rec.bytes),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("user_agent", r.UserAgent()),
+			)
+		})
+	}
+}
+
+// matchedRoute is the pattern of routes that r matches, "" when none does.
+// Middleware outside the mux never sees the pattern the mux sets, because
+// it is set on a request copied further down the chain.
+func matchedRoute(routes *http.ServeMux, r *http.Request) string {
+	_, pattern := routes.Handler(r)
+	return pattern
+}
@@ -0,0 +1,64 @@
+// internal/middleware/metrics.go
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/metrics"
+)
+
+// statusRecorder captures the status code and size written by the wrapped handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Metrics records request counts and latency, labelled by the route of
+// routes the request matches. The route is looked up rather than read from
+// the request, so requests answered before reaching the mux, such as rate
+// limited ones, are labelled too and Metrics can wrap the middleware that
+// rejects them.
+func Metrics(routes *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(rec, r)
+
+			route := matchedRoute(routes, r)
+			if route == "" {
+				// Unmatched paths are collapsed so scanners can't explode cardinality
+				route = "unmatched"
+			}
+			status := strconv.Itoa(rec.status)
+
+			metrics.HTTPRequests.WithLabelValues(route, r.Method, status).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(route, r.Method, status).
+				Observe(time.Since(start).Seconds())
+		})
+	}
+}
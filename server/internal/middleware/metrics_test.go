@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dev4dreams/dev4url/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Requests rejected before reaching the mux are counted under their route
+func TestMetricsCountsRejectedRequests(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{code}", func(w http.ResponseWriter, r *http.Request) {})
+	reject := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+	})
+	handler := Metrics(mux)(reject)
+
+	counter := metrics.HTTPRequests.WithLabelValues("GET /{code}", http.MethodGet, "429")
+	before := testutil.ToFloat64(counter)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abc1234", nil))
+
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("Expected one rate limited request counted, got %v", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/dev4dreams/dev4url/internal/metrics"
 	"golang.org/x/time/rate"
 )
 
@@ -53,6 +54,7 @@ func (i *IPRateLimiter) RateLimit(next http.Handler) http.Handler {
 
 		// Check if request is allowed
 		if !limiter.Allow() {
+			metrics.RateLimitRejections.Inc()
 			http.Error(w, "Too many requests. Please try again later.", http.StatusTooManyRequests)
 			return
 		}
@@ -0,0 +1,80 @@
+// internal/middleware/request_id.go
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/dev4dreams/dev4url/internal/logging"
+	"github.com/getsentry/sentry-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader is accepted from clients and echoed on every response
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client supplied IDs so they can't bloat logs
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID assigned by the RequestID middleware
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID accepts a well-formed incoming X-Request-ID or generates one,
+// echoes it on the response, tags the Sentry scope with it and stores a
+// logger carrying it on the request context. It must run inside
+// SentryHandler so the tag lands on the request's hub.
+func RequestID(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !isValidRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			if hub := sentry.GetHubFromContext(r.Context()); hub != nil {
+				hub.ConfigureScope(func(scope *sentry.Scope) {
+					scope.SetTag("request_id", id)
+				})
+			}
+
+			requestLogger := logger.With(slog.String("request_id", id))
+			// Correlate log lines with the trace started by the Tracing middleware
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+				requestLogger = requestLogger.With(slog.String("trace_id", sc.TraceID().String()))
+			}
+
+			ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+			ctx = logging.NewContext(ctx, requestLogger)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// isValidRequestID only accepts short, printable ASCII IDs
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns 16 random bytes, hex encoded
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantSame bool
+	}{
+		{name: "propagates incoming ID", incoming: "abc-123", wantSame: true},
+		{name: "generates missing ID", incoming: "", wantSame: false},
+		{name: "replaces ID with spaces", incoming: "abc 123", wantSame: false},
+		{name: "replaces oversized ID", incoming: strings.Repeat("a", maxRequestIDLength+1), wantSame: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := RequestID(slog.Default())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(RequestIDHeader)
+			if got == "" || got != seen {
+				t.Fatalf("Expected response header to match context ID, got %q and %q", got, seen)
+			}
+			if tt.wantSame && got != tt.incoming {
+				t.Errorf("Expected request ID %q, got %q", tt.incoming, got)
+			}
+			if !tt.wantSame && got == tt.incoming {
+				t.Errorf("Expected a generated request ID, got %q", got)
+			}
+		})
+	}
+}
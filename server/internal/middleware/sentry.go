@@ -2,22 +2,23 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 )
 
 // InitSentry initializes the Sentry client with the provided DSN
-func InitSentry(dsn string) error {
+func InitSentry(dsn, environment string, traceRate float64) error {
 	err := sentry.Init(sentry.ClientOptions{
 		Dsn:              dsn,
-		TracesSampleRate: 1.0,
+		TracesSampleRate: traceRate,
 		EnableTracing:    true,
-		Environment:      os.Getenv("APP_ENV"), // Use environment variable
-		Debug:            os.Getenv("APP_ENV") == "development",
+		Environment:      environment,
+		Debug:            environment == "development",
 		ServerName:       "urlshortener-service",
 		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
 			// You can modify or filter events before they're sent
@@ -64,7 +65,11 @@ func SentryHandler(next http.Handler) http.Handler {
 					err,
 				)
 				// Log the error ID for tracking
-				fmt.Printf("Captured error with ID: %s\n", *eventID)
+				attrs := []any{slog.Any("panic", err), slog.String("path", r.URL.Path)}
+				if eventID != nil {
+					attrs = append(attrs, slog.String("sentry_event_id", string(*eventID)))
+				}
+				slog.ErrorContext(ctx, "Recovered from panic", attrs...)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			}
 		}()
@@ -73,13 +78,19 @@ func SentryHandler(next http.Handler) http.Handler {
 	})
 }
 
-// CaptureError helper function to capture errors with additional context
-func CaptureError(err error, tags map[string]string) *sentry.EventID {
+// CaptureError helper function to capture errors with additional context.
+// The request's hub is used when ctx carries one, so events keep the
+// request scoped tags such as request_id.
+func CaptureError(ctx context.Context, err error, tags map[string]string) *sentry.EventID {
 	if err == nil {
 		return nil
 	}
 
-	hub := sentry.CurrentHub().Clone()
+	hub := sentry.GetHubFromContext(ctx)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	hub = hub.Clone()
 	hub.ConfigureScope(func(scope *sentry.Scope) {
 		scope.SetTags(tags)
 	})
@@ -0,0 +1,41 @@
+// internal/middleware/tracing.go
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span per request, continuing any W3C trace
+// context sent by the caller. It should be the outermost middleware.
+func Tracing(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http.server",
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			return r.Method
+		}),
+	)
+}
+
+// TraceRoute renames the server span after the matched route. It must wrap
+// the mux directly to see the matched pattern.
+func TraceRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		if r.Pattern == "" {
+			return
+		}
+		name := r.Pattern
+		if !strings.Contains(name, " ") {
+			// Patterns registered without a method
+			name = r.Method + " " + name
+		}
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(name)
+		span.SetAttributes(semconv.HTTPRoute(r.Pattern))
+	})
+}
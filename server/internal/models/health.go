@@ -0,0 +1,14 @@
+// internal/models/health.go
+package models
+
+// HealthCheck is the result of a single readiness check
+type HealthCheck struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// HealthResponse is returned by the liveness and readiness endpoints
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
@@ -7,12 +7,91 @@ import "time"
 type CreateUrlRequest struct {
 	OriginalURL string `json:"original_url"`
 	CustomURL   string `json:"custom_url,omitempty"` // still optional
+	// Interstitial shows every visitor the preview page instead of
+	// redirecting straight away
+	Interstitial bool `json:"interstitial,omitempty"`
+	// UTM parameters are added to the destination on every redirect,
+	// unless it already has them
+	UTM *UTMParams `json:"utm,omitempty"`
+	// ForwardQuery passes the visitor's query string on to the destination
+	ForwardQuery bool `json:"forward_query,omitempty"`
+	// Targets send matching visitors elsewhere; the first match wins and
+	// the original URL is the fallback
+	Targets []TargetRule `json:"targets,omitempty"`
+	// Variants split the visitors no target matched between destinations
+	// by weight, in place of the original URL
+	Variants []Variant `json:"variants,omitempty"`
+	// StickyVariants sends returning visitors to the variant they got
+	// before
+	StickyVariants bool `json:"sticky_variants,omitempty"`
+	// AppLinks open the link in a mobile app when it is installed
+	AppLinks *AppLinks `json:"app_links,omitempty"`
+	// Schedule limits when the link sends visitors to its destination
+	Schedule *Schedule `json:"schedule,omitempty"`
 }
 
 type CreateUrlPayload struct {
-	OriginalUrl string `json:"original_url"`
-	ShortenUrl  string `json:"short_url"`
-	CustomUrl   string `json:"custom_url"`
+	OriginalUrl    string       `json:"original_url"`
+	ShortenUrl     string       `json:"short_url"`
+	CustomUrl      string       `json:"custom_url"`
+	Interstitial   bool         `json:"interstitial"`
+	UTM            *UTMParams   `json:"utm,omitempty"`
+	ForwardQuery   bool         `json:"forward_query"`
+	Targets        []TargetRule `json:"targets,omitempty"`
+	Variants       []Variant    `json:"variants,omitempty"`
+	StickyVariants bool         `json:"sticky_variants"`
+	AppLinks       *AppLinks    `json:"app_links,omitempty"`
+	Schedule       *Schedule    `json:"schedule,omitempty"`
+}
+
+// Schedule limits when a link sends visitors to its destination, so links
+// printed ahead of a launch go live at a set moment. Either bound may be
+// left out. Before ActiveFrom visitors go to BeforeURL, such as a "coming
+// soon" page, and from ActiveUntil on to AfterURL; without one the link
+// is not found. Turning the link off still stops it at any time.
+type Schedule struct {
+	ActiveFrom  *time.Time `json:"active_from,omitempty"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+	BeforeURL   string     `json:"before_url,omitempty"`
+	AfterURL    string     `json:"after_url,omitempty"`
+}
+
+// AppLinks open a link in a mobile app. Each is a custom scheme URL such
+// as myapp://item/42, from the configured allowlist, or a universal link
+// or Android App Link (https). Visitors without the app go on to
+// Fallback, or to the link's destination when it is empty.
+type AppLinks struct {
+	IOS      string `json:"ios,omitempty"`
+	Android  string `json:"android,omitempty"`
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// Variant is one destination of a split link, served to a share of
+// visitors proportional to its weight
+type Variant struct {
+	Name   string `json:"name"` // reported in click stats, e.g. control
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// TargetRule sends visitors matching all of its conditions to URL. Each
+// condition matches any of its values and an empty one matches everyone.
+type TargetRule struct {
+	Countries []string `json:"countries,omitempty"` // ISO 3166-1 alpha-2, e.g. DE
+	OS        []string `json:"os,omitempty"`        // ios, android, windows, macos, linux or chromeos
+	Devices   []string `json:"devices,omitempty"`   // mobile, tablet or desktop
+	// Languages match the visitor's preferred language; en matches en-GB
+	Languages []string `json:"languages,omitempty"`
+	URL       string   `json:"url"`
+}
+
+// UTMParams are campaign parameters, without their utm_ prefix
+type UTMParams struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
 }
 
 // for single url response
@@ -23,19 +102,121 @@ type CreateUrlResponse struct {
 // when url been called
 type GetOriginalUrlRequest struct {
 	ShortenUrl string `json:"shortenUrl"`
+	// Query is the visitor's query string, forwarded to the destination
+	// by links that ask for it
+	Query string `json:"query,omitempty"`
+	// Variant is the one the visitor was served before, kept by links
+	// with sticky variants
+	Variant string `json:"variant,omitempty"`
 }
 type GetOriginalUrlResponse struct {
-	OriginalURL string `json:"original_url"`
+	OriginalURL  string `json:"original_url"`
+	Interstitial bool   `json:"interstitial,omitempty"`
+	Variant      string `json:"variant,omitempty"` // served to the visitor
+	// AppURL is opened first for visitors on a platform the link has an
+	// app link for; OriginalURL is the fallback
+	AppURL string `json:"app_url,omitempty"`
+}
+
+// ResolvedLink is what a redirect needs to know about an active link; it
+// is what the resolve cache stores
+type ResolvedLink struct {
+	OriginalURL    string       `json:"original_url"`
+	Interstitial   bool         `json:"interstitial,omitempty"`
+	UTM            *UTMParams   `json:"utm,omitempty"`
+	ForwardQuery   bool         `json:"forward_query,omitempty"`
+	Targets        []TargetRule `json:"targets,omitempty"`
+	Variants       []Variant    `json:"variants,omitempty"`
+	StickyVariants bool         `json:"sticky_variants,omitempty"`
+	AppLinks       *AppLinks    `json:"app_links,omitempty"`
+	Schedule       *Schedule    `json:"schedule,omitempty"`
+}
+
+// when the code fails its checksum, with the one active code a typo away
+type ShortUrlNotFoundResponse struct {
+	Error      string `json:"error"`
+	DidYouMean string `json:"did_you_mean,omitempty"`
+}
+
+// for the management API; nil fields are left unchanged
+type UpdateUrlRequest struct {
+	OriginalURL  *string `json:"original_url,omitempty"`
+	Active       *bool   `json:"active,omitempty"`
+	Interstitial *bool   `json:"interstitial,omitempty"`
+	// UTM replaces the default UTM parameters, an empty object clears them
+	UTM          *UTMParams `json:"utm,omitempty"`
+	ForwardQuery *bool      `json:"forward_query,omitempty"`
+	// Targets replaces the targeting rules, an empty list clears them
+	Targets *[]TargetRule `json:"targets,omitempty"`
+	// Variants replaces the split destinations, an empty list clears them.
+	// Clicks of removed variants stay in the stats.
+	Variants       *[]Variant `json:"variants,omitempty"`
+	StickyVariants *bool      `json:"sticky_variants,omitempty"`
+	// AppLinks replaces the app links, an empty object clears them
+	AppLinks *AppLinks `json:"app_links,omitempty"`
+	// Schedule replaces the schedule, an empty object clears it
+	Schedule *Schedule `json:"schedule,omitempty"`
 }
 
 // This struct is for reading full URL data from DB
 type URLResponse struct {
-	ID          string    `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	ShortURL    string    `json:"short_url"`
-	OriginalURL string    `json:"original_url"`
-	CustomURL   *string   `json:"custom_url,omitempty"`
-	Clicks      int       `json:"clicks"`
-	Active      bool      `json:"active"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID             string        `json:"id"`
+	CreatedAt      time.Time     `json:"created_at"`
+	ShortURL       string        `json:"short_url"`
+	OriginalURL    string        `json:"original_url"`
+	CustomURL      *string       `json:"custom_url,omitempty"`
+	Clicks         int           `json:"clicks"`
+	Active         bool          `json:"active"`
+	Interstitial   bool          `json:"interstitial"`
+	UTM            *UTMParams    `json:"utm,omitempty"`
+	ForwardQuery   bool          `json:"forward_query"`
+	Targets        []TargetRule  `json:"targets,omitempty"`
+	Variants       []Variant     `json:"variants,omitempty"`
+	StickyVariants bool          `json:"sticky_variants"`
+	AppLinks       *AppLinks     `json:"app_links,omitempty"`
+	Schedule       *Schedule     `json:"schedule,omitempty"`
+	Metadata       *LinkMetadata `json:"metadata,omitempty"` // nil until fetched
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// LinkMetadata describes the destination page, fetched after creation so
+// shared short links unfurl in chat apps
+type LinkMetadata struct {
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	Favicon     string    `json:"favicon,omitempty"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+// LinkStats are the click counts of a short URL, split by variant for
+// split links
+type LinkStats struct {
+	ShortURL string         `json:"short_url"`
+	Clicks   int            `json:"clicks"`
+	Variants []VariantStats `json:"variants,omitempty"`
+}
+
+// VariantStats are the clicks served by one variant. Removed variants keep
+// their clicks with no URL or weight.
+type VariantStats struct {
+	Name   string `json:"name"`
+	URL    string `json:"url,omitempty"`
+	Weight int    `json:"weight"`
+	Clicks int64  `json:"clicks"`
+	// Share is the variant's fraction of the link's variant clicks
+	Share float64 `json:"share"`
+}
+
+// ClickKey identifies what a click is counted against: a short code and,
+// for split links, the variant served
+type ClickKey struct {
+	Code    string
+	Variant string // empty when the link has no variants
+}
+
+// BlocklistEntry is a blocked domain or pattern stored in the database
+type BlocklistEntry struct {
+	Kind  string `json:"kind"` // "domain" or "pattern"
+	Value string `json:"value"`
 }
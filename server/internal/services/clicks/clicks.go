@@ -0,0 +1,128 @@
+// Package clicks counts redirects in memory and writes the counts to the
+// database in batches, so a popular link does not turn its row into a
+// write hot spot and redirects never wait on a write.
+package clicks
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/metrics"
+	"github.com/dev4dreams/dev4url/internal/models"
+)
+
+// Key identifies what a click is counted against
+type Key = models.ClickKey
+
+// Store adds click counts per short code and variant, see db.Database. On
+// error it returns the counts it did not write.
+type Store interface {
+	AddClicks(ctx context.Context, counts map[Key]int64) (map[Key]int64, error)
+}
+
+// Buffer aggregates clicks per code and variant between flushes. It holds
+// at most maxCodes distinct keys; clicks on further keys are dropped until
+// the next flush makes room.
+type Buffer struct {
+	store    Store
+	interval time.Duration
+	maxCodes int
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	pending map[Key]int64
+	clicks  int64     // sum of pending
+	oldest  time.Time // first click not yet written, zero when none
+}
+
+// NewBuffer creates a buffer flushing to store every interval once Run
+func NewBuffer(store Store, interval time.Duration, maxCodes int, logger *slog.Logger) *Buffer {
+	return &Buffer{
+		store:    store,
+		interval: interval,
+		maxCodes: maxCodes,
+		logger:   logger,
+		pending:  make(map[Key]int64),
+	}
+}
+
+// Add counts one click on code, served by variant or "" for none
+func (b *Buffer) Add(code, variant string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := Key{Code: code, Variant: variant}
+	if _, ok := b.pending[key]; !ok && len(b.pending) >= b.maxCodes {
+		metrics.ClicksDropped.Inc()
+		return
+	}
+	b.pending[key]++
+	b.clicks++
+	if b.oldest.IsZero() {
+		b.oldest = time.Now()
+	}
+	metrics.ClicksPending.Set(float64(b.clicks))
+}
+
+// Run flushes every interval until ctx is done. Call Flush afterwards to
+// write what is left.
+func (b *Buffer) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.Flush(ctx)
+		}
+	}
+}
+
+// Flush writes the pending counts. Counts that could not be written are
+// merged back for the next flush, within the buffer's bound.
+func (b *Buffer) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	counts, oldest := b.pending, b.oldest
+	b.pending, b.clicks, b.oldest = make(map[Key]int64), 0, time.Time{}
+	b.mu.Unlock()
+
+	if len(counts) == 0 {
+		metrics.ClickFlushLag.Set(0)
+		return nil
+	}
+
+	unwritten, err := b.store.AddClicks(ctx, counts)
+	if err == nil {
+		metrics.ClickFlushLag.Set(time.Since(oldest).Seconds())
+		b.mu.Lock()
+		metrics.ClicksPending.Set(float64(b.clicks))
+		b.mu.Unlock()
+		return nil
+	}
+
+	metrics.ClickFlushErrors.Inc()
+	b.logger.Error("Failed to flush click counts, keeping them for the next flush",
+		slog.Int("codes", len(unwritten)), slog.Any("error", err))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key, n := range unwritten {
+		if _, ok := b.pending[key]; !ok && len(b.pending) >= b.maxCodes {
+			metrics.ClicksDropped.Add(float64(n))
+			continue
+		}
+		b.pending[key] += n
+		b.clicks += n
+	}
+	if len(b.pending) > 0 {
+		b.oldest = oldest
+	}
+	// The lag keeps growing while flushes fail
+	metrics.ClickFlushLag.Set(time.Since(oldest).Seconds())
+	metrics.ClicksPending.Set(float64(b.clicks))
+	return err
+}
@@ -0,0 +1,103 @@
+package clicks
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	err    error
+	counts map[Key]int64
+}
+
+func (s *fakeStore) AddClicks(ctx context.Context, counts map[Key]int64) (map[Key]int64, error) {
+	if s.err != nil {
+		return counts, s.err
+	}
+	for key, n := range counts {
+		s.counts[key] += n
+	}
+	return nil, nil
+}
+
+func TestBufferFlush(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{counts: make(map[Key]int64)}
+	buffer := NewBuffer(store, time.Minute, 10, slog.Default())
+
+	buffer.Add("abc", "")
+	buffer.Add("abc", "")
+	buffer.Add("xyz", "")
+
+	store.err = errors.New("database unavailable")
+	if err := buffer.Flush(ctx); err == nil {
+		t.Fatal("Expected the flush to fail")
+	}
+
+	store.err = nil
+	buffer.Add("abc", "")
+	if err := buffer.Flush(ctx); err != nil {
+		t.Fatalf("Flush() unexpected error: %v", err)
+	}
+	if store.counts[Key{Code: "abc"}] != 3 || store.counts[Key{Code: "xyz"}] != 1 {
+		t.Errorf("Expected clicks kept across the failed flush, got %v", store.counts)
+	}
+
+	if err := buffer.Flush(ctx); err != nil || len(store.counts) != 2 || store.counts[Key{Code: "abc"}] != 3 {
+		t.Errorf("Expected an empty flush to write nothing, got %v, %v", store.counts, err)
+	}
+}
+
+func TestBufferBound(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeStore{counts: make(map[Key]int64)}
+	buffer := NewBuffer(store, time.Minute, 2, slog.Default())
+
+	buffer.Add("a", "")
+	buffer.Add("b", "")
+	buffer.Add("c", "") // dropped, the buffer holds two codes
+	buffer.Add("a", "") // known codes are still counted
+
+	if err := buffer.Flush(ctx); err != nil {
+		t.Fatalf("Flush() unexpected error: %v", err)
+	}
+	if store.counts[Key{Code: "a"}] != 2 || store.counts[Key{Code: "b"}] != 1 || store.counts[Key{Code: "c"}] != 0 {
+		t.Errorf("Unexpected counts %v", store.counts)
+	}
+
+	buffer.Add("c", "")
+	buffer.Flush(ctx)
+	if store.counts[Key{Code: "c"}] != 1 {
+		t.Errorf("Expected the flush to make room for new codes, got %v", store.counts)
+	}
+}
+
+func TestBufferVariants(t *testing.T) {
+	store := &fakeStore{counts: make(map[Key]int64)}
+	buffer := NewBuffer(store, time.Minute, 10, slog.Default())
+
+	buffer.Add("abc", "a")
+	buffer.Add("abc", "b")
+	buffer.Add("abc", "b")
+	buffer.Add("abc", "")
+
+	if err := buffer.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() unexpected error: %v", err)
+	}
+	want := map[Key]int64{
+		{Code: "abc", Variant: "a"}: 1,
+		{Code: "abc", Variant: "b"}: 2,
+		{Code: "abc"}:               1,
+	}
+	if len(store.counts) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, store.counts)
+	}
+	for key, n := range want {
+		if store.counts[key] != n {
+			t.Errorf("Expected %d clicks on %v, got %d", n, key, store.counts[key])
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package geoip
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// encodeValue writes a string, unsigned integer or map in the MaxMind DB
+// data format; values are small enough for one byte sizes
+func encodeValue(buf *bytes.Buffer, value any) {
+	switch v := value.(type) {
+	case string:
+		buf.WriteByte(typeString<<5 | byte(len(v)))
+		buf.WriteString(v)
+	case uint32:
+		buf.WriteByte(typeUint32<<5 | 4)
+		buf.Write([]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+	case uint16:
+		buf.WriteByte(typeUint16<<5 | 2)
+		buf.Write([]byte{byte(v >> 8), byte(v)})
+	case map[string]any:
+		buf.WriteByte(typeMap<<5 | byte(len(v)))
+		for key, value := range v {
+			encodeValue(buf, key)
+			encodeValue(buf, value)
+		}
+	default:
+		panic("unsupported type")
+	}
+}
+
+// buildDatabase returns a database mapping prefix to a record with
+// country code, with the search tree written at recordSize
+func buildDatabase(t *testing.T, prefix netip.Prefix, country string, recordSize, ipVersion int) []byte {
+	t.Helper()
+
+	var data bytes.Buffer
+	encodeValue(&data, map[string]any{"country": map[string]any{"iso_code": country}})
+
+	// One node per prefix bit, after 96 zero bits for IPv4 in an IPv6 tree
+	var bits []uint
+	if ipVersion == 6 && prefix.Addr().Is4() {
+		bits = make([]uint, 96)
+	}
+	ip := prefix.Addr().AsSlice()
+	for i := 0; i < prefix.Bits(); i++ {
+		bits = append(bits, uint(ip[i/8]>>(7-i%8)&1))
+	}
+	nodeCount := uint(len(bits))
+	dataRecord := nodeCount + dataSectionSeparator
+
+	var tree bytes.Buffer
+	for i, bit := range bits {
+		next := uint(i + 1)
+		if i == len(bits)-1 {
+			next = dataRecord
+		}
+		records := [2]uint{nodeCount, nodeCount}
+		records[bit] = next
+		left, right := records[0], records[1]
+		switch recordSize {
+		case 24:
+			tree.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left),
+				byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			tree.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left),
+				byte(left>>24)<<4 | byte(right>>24),
+				byte(right >> 16), byte(right >> 8), byte(right)})
+		case 32:
+			tree.Write([]byte{byte(left >> 24), byte(left >> 16), byte(left >> 8), byte(left),
+				byte(right >> 24), byte(right >> 16), byte(right >> 8), byte(right)})
+		}
+	}
+
+	var db bytes.Buffer
+	db.Write(tree.Bytes())
+	db.Write(make([]byte, dataSectionSeparator))
+	db.Write(data.Bytes())
+	db.Write(metadataMarker)
+	encodeValue(&db, map[string]any{
+		"node_count":  uint32(nodeCount),
+		"record_size": uint16(recordSize),
+		"ip_version":  uint16(ipVersion),
+	})
+	return db.Bytes()
+}
+
+func TestCountry(t *testing.T) {
+	tests := []struct {
+		name       string
+		prefix     string
+		recordSize int
+		ipVersion  int
+		addr       string
+		want       string
+		wantErr    error
+	}{
+		{"ipv4 tree", "81.2.69.0/24", 24, 4, "81.2.69.160", "GB", nil},
+		{"outside prefix", "81.2.69.0/24", 24, 4, "81.2.70.1", "", ErrNotFound},
+		{"ipv6 address in ipv4 tree", "81.2.69.0/24", 24, 4, "2001:db8::1", "", ErrNotFound},
+		{"28 bit records", "81.2.69.0/24", 28, 4, "81.2.69.1", "GB", nil},
+		{"32 bit records", "81.2.69.0/24", 32, 4, "81.2.69.1", "GB", nil},
+		{"ipv4 in ipv6 tree", "81.2.69.0/24", 24, 6, "81.2.69.160", "GB", nil},
+		{"mapped ipv4 in ipv6 tree", "81.2.69.0/24", 28, 6, "::ffff:81.2.69.160", "GB", nil},
+		{"ipv6 tree", "2001:db8::/32", 24, 6, "2001:db8:1::1", "DE", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			country := tt.want
+			if country == "" {
+				country = "GB"
+			}
+			buf := buildDatabase(t, netip.MustParsePrefix(tt.prefix), country, tt.recordSize, tt.ipVersion)
+			r, err := newReader(buf)
+			if err != nil {
+				t.Fatalf("newReader() error = %v", err)
+			}
+
+			got, err := r.Country(netip.MustParseAddr(tt.addr))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Country(%s) error = %v, want %v", tt.addr, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Country(%s) = %q, want %q", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	buf := buildDatabase(t, netip.MustParsePrefix("2001:db8::/32"), "FR", 24, 6)
+	if err := os.WriteFile(path, buf, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if got, err := r.Country(netip.MustParseAddr("2001:db8::1")); err != nil || got != "FR" {
+		t.Errorf("Country() = %q, %v, want FR", got, err)
+	}
+}
+
+func TestInvalidDatabase(t *testing.T) {
+	valid := buildDatabase(t, netip.MustParsePrefix("81.2.69.0/24"), "GB", 24, 4)
+	tests := map[string][]byte{
+		"empty":            nil,
+		"no metadata":      valid[:bytes.Index(valid, metadataMarker)],
+		"truncated tree":   valid[bytes.Index(valid, metadataMarker)-4:],
+		"truncated values": valid[:len(valid)-3],
+	}
+
+	for name, buf := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newReader(buf); !errors.Is(err, ErrInvalidDatabase) {
+				t.Errorf("newReader() error = %v, want ErrInvalidDatabase", err)
+			}
+		})
+	}
+}
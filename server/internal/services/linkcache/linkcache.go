@@ -0,0 +1,145 @@
+// Package linkcache keeps recently resolved short codes in memory, and
+// optionally in a cache shared by all replicas, so hot redirects skip the
+// database. Unknown codes are cached too, which blunts enumeration.
+package linkcache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/logging"
+	"github.com/dev4dreams/dev4url/internal/metrics"
+	"github.com/dev4dreams/dev4url/internal/models"
+)
+
+// Entry is a cached resolution; Found is false for unknown or inactive codes
+type Entry struct {
+	Link  models.ResolvedLink
+	Found bool
+}
+
+// Backend stores entries by short code. Get reports false on a miss.
+type Backend interface {
+	Get(ctx context.Context, code string) (Entry, bool, error)
+	Set(ctx context.Context, code string, entry Entry, ttl time.Duration) error
+	Delete(ctx context.Context, code string) error
+}
+
+// Store resolves codes that are not cached, see db.Database
+type Store interface {
+	ResolveLink(ctx context.Context, code string) (*models.ResolvedLink, error)
+}
+
+// Config tunes the cache
+type Config struct {
+	// TTL bounds how long an entry is served. Invalidate clears this
+	// replica and the shared backend; other replicas' local entries only
+	// go stale for up to TTL.
+	TTL         time.Duration
+	NegativeTTL time.Duration
+}
+
+// Cache is a read-through cache in front of a Store
+type Cache struct {
+	local  Backend
+	shared Backend // may be nil
+	store  Store
+	config Config
+	logger *slog.Logger
+}
+
+// New creates a cache resolving misses through store. shared may be nil.
+func New(local, shared Backend, store Store, config Config, logger *slog.Logger) *Cache {
+	return &Cache{
+		local:  local,
+		shared: shared,
+		store:  store,
+		config: config,
+		logger: logger,
+	}
+}
+
+// ResolveLink resolves an active code like the Store, returning
+// sql.ErrNoRows for unknown or inactive codes
+func (c *Cache) ResolveLink(ctx context.Context, code string) (*models.ResolvedLink, error) {
+	if entry, ok := c.get(ctx, code); ok {
+		if !entry.Found {
+			metrics.ResolveCacheLookups.WithLabelValues(metrics.CacheNegativeHit).Inc()
+			return nil, sql.ErrNoRows
+		}
+		metrics.ResolveCacheLookups.WithLabelValues(metrics.CacheHit).Inc()
+		link := entry.Link
+		return &link, nil
+	}
+	metrics.ResolveCacheLookups.WithLabelValues(metrics.CacheMiss).Inc()
+
+	link, err := c.store.ResolveLink(ctx, code)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.set(ctx, code, Entry{}, c.config.NegativeTTL)
+		return nil, err
+	case err != nil:
+		return nil, err
+	}
+
+	c.set(ctx, code, Entry{Link: *link, Found: true}, c.config.TTL)
+	return link, nil
+}
+
+// Invalidate drops a code after it was created, changed or disabled
+func (c *Cache) Invalidate(ctx context.Context, code string) error {
+	c.local.Delete(ctx, code)
+	if c.shared == nil {
+		return nil
+	}
+	if err := c.shared.Delete(ctx, code); err != nil {
+		metrics.ResolveCacheErrors.Inc()
+		return err
+	}
+	return nil
+}
+
+// get reads the local cache, then the shared one, copying shared hits
+// locally. Shared cache errors are logged and treated as misses.
+func (c *Cache) get(ctx context.Context, code string) (Entry, bool) {
+	if entry, ok, _ := c.local.Get(ctx, code); ok {
+		return entry, true
+	}
+	if c.shared == nil {
+		return Entry{}, false
+	}
+
+	entry, ok, err := c.shared.Get(ctx, code)
+	if err != nil {
+		metrics.ResolveCacheErrors.Inc()
+		logging.FromContext(ctx, c.logger).Warn("Shared cache read failed",
+			slog.String("short_code", code), slog.Any("error", err))
+		return Entry{}, false
+	}
+	if ok {
+		ttl := c.config.TTL
+		if !entry.Found {
+			ttl = c.config.NegativeTTL
+		}
+		c.local.Set(ctx, code, entry, ttl)
+	}
+	return entry, ok
+}
+
+func (c *Cache) set(ctx context.Context, code string, entry Entry, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	c.local.Set(ctx, code, entry, ttl)
+	if c.shared == nil {
+		return
+	}
+	if err := c.shared.Set(ctx, code, entry, ttl); err != nil {
+		metrics.ResolveCacheErrors.Inc()
+		logging.FromContext(ctx, c.logger).Warn("Shared cache write failed",
+			slog.String("short_code", code), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,260 @@
+package linkcache
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/models"
+)
+
+// fakeStore resolves from a map and counts its calls
+type fakeStore struct {
+	mu    sync.Mutex
+	urls  map[string]string
+	calls int
+}
+
+func (s *fakeStore) ResolveLink(ctx context.Context, code string) (*models.ResolvedLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	url, ok := s.urls[code]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &models.ResolvedLink{OriginalURL: url}, nil
+}
+
+func newTestCache(shared Backend) (*Cache, *fakeStore) {
+	store := &fakeStore{urls: map[string]string{"abc1234": "https://example.org"}}
+	cache := New(NewLRU(10), shared, store,
+		Config{TTL: time.Minute, NegativeTTL: time.Minute}, slog.Default())
+	return cache, store
+}
+
+func found(url string) Entry {
+	return Entry{Link: models.ResolvedLink{OriginalURL: url}, Found: true}
+}
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	lru := NewLRU(2)
+	now := time.Now()
+	lru.now = func() time.Time { return now }
+
+	lru.Set(ctx, "a", found("1"), time.Minute)
+	lru.Set(ctx, "b", found("2"), time.Minute)
+	lru.Get(ctx, "a") // b is now least recently used
+	lru.Set(ctx, "c", found("3"), time.Minute)
+
+	if _, ok, _ := lru.Get(ctx, "b"); ok {
+		t.Error("Expected the least recently used entry to be evicted")
+	}
+	if entry, ok, _ := lru.Get(ctx, "a"); !ok || entry.Link.OriginalURL != "1" {
+		t.Errorf("Get(a) = %+v, %v, want the cached entry", entry, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok, _ := lru.Get(ctx, "a"); ok {
+		t.Error("Expected an expired entry to be dropped")
+	}
+	if lru.Len() != 1 {
+		t.Errorf("Len() = %d, want 1 after dropping the expired entry", lru.Len())
+	}
+}
+
+func TestCacheReadThrough(t *testing.T) {
+	ctx := context.Background()
+	cache, store := newTestCache(nil)
+
+	for i := 0; i < 3; i++ {
+		link, err := cache.ResolveLink(ctx, "abc1234")
+		if err != nil || link.OriginalURL != "https://example.org" {
+			t.Fatalf("ResolveLink() = %+v, %v", link, err)
+		}
+	}
+	if store.calls != 1 {
+		t.Errorf("Expected 1 store call, got %d", store.calls)
+	}
+}
+
+func TestCacheNegative(t *testing.T) {
+	ctx := context.Background()
+	cache, store := newTestCache(nil)
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.ResolveLink(ctx, "missing"); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("ResolveLink() error = %v, want sql.ErrNoRows", err)
+		}
+	}
+	if store.calls != 1 {
+		t.Errorf("Expected unknown codes to be cached, got %d store calls", store.calls)
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	ctx := context.Background()
+	cache, store := newTestCache(nil)
+
+	cache.ResolveLink(ctx, "abc1234")
+	store.urls["abc1234"] = "https://example.net"
+	if err := cache.Invalidate(ctx, "abc1234"); err != nil {
+		t.Fatalf("Invalidate() unexpected error: %v", err)
+	}
+	if link, _ := cache.ResolveLink(ctx, "abc1234"); link == nil || link.OriginalURL != "https://example.net" {
+		t.Errorf("Expected the new destination after invalidation, got %+v", link)
+	}
+}
+
+func TestCacheSharedRedis(t *testing.T) {
+	ctx := context.Background()
+	addr := startFakeRedis(t)
+	redis, err := NewRedis("redis://:pass@"+addr+"/2", 2)
+	if err != nil {
+		t.Fatalf("NewRedis() unexpected error: %v", err)
+	}
+	defer redis.Close()
+
+	first, store := newTestCache(redis)
+	second, _ := newTestCache(redis)
+	second.store = store
+
+	first.ResolveLink(ctx, "abc1234")
+	first.ResolveLink(ctx, "missing")
+	if link, err := second.ResolveLink(ctx, "abc1234"); err != nil || link.OriginalURL != "https://example.org" {
+		t.Fatalf("ResolveLink() on another replica = %+v, %v", link, err)
+	}
+	if _, err := second.ResolveLink(ctx, "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("ResolveLink() error = %v, want sql.ErrNoRows", err)
+	}
+	if store.calls != 2 {
+		t.Errorf("Expected the second replica to be served by redis, got %d store calls", store.calls)
+	}
+
+	if err := first.Invalidate(ctx, "abc1234"); err != nil {
+		t.Fatalf("Invalidate() unexpected error: %v", err)
+	}
+	if _, ok, err := redis.Get(ctx, "abc1234"); ok || err != nil {
+		t.Errorf("Expected the shared entry to be deleted, got %v, %v", ok, err)
+	}
+}
+
+func TestNewRedisRejectsBadURLs(t *testing.T) {
+	for _, raw := range []string{"http://cache:6379", "redis://cache:6379/zero"} {
+		if _, err := NewRedis(raw, 1); err == nil {
+			t.Errorf("NewRedis(%q) expected an error", raw)
+		}
+	}
+}
+
+// Without Redis the cache falls back to the store
+func TestCacheRedisUnavailable(t *testing.T) {
+	ctx := context.Background()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	redis, err := NewRedis("redis://"+addr, 1)
+	if err != nil {
+		t.Fatalf("NewRedis() unexpected error: %v", err)
+	}
+	defer redis.Close()
+	if _, _, err := redis.Get(ctx, "abc1234"); err == nil {
+		t.Error("Expected an error without a server")
+	}
+
+	cache, store := newTestCache(redis)
+	if link, err := cache.ResolveLink(ctx, "abc1234"); err != nil || link.OriginalURL != "https://example.org" {
+		t.Fatalf("ResolveLink() = %+v, %v", link, err)
+	}
+	if store.calls != 1 {
+		t.Errorf("Expected the store to be asked, got %d calls", store.calls)
+	}
+}
+
+// startFakeRedis serves AUTH, SELECT, GET, SET and DEL from memory and
+// refuses every other command, as an old server refuses HELLO
+func startFakeRedis(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	var mu sync.Mutex
+	data := make(map[string]string)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(r)
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					switch strings.ToUpper(args[0]) {
+					case "AUTH", "SELECT":
+						io.WriteString(conn, "+OK\r\n")
+					case "GET":
+						if value, ok := data[args[1]]; ok {
+							fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+						} else {
+							io.WriteString(conn, "$-1\r\n")
+						}
+					case "SET":
+						data[args[1]] = args[2]
+						io.WriteString(conn, "+OK\r\n")
+					case "DEL":
+						delete(data, args[1])
+						io.WriteString(conn, ":1\r\n")
+					default:
+						io.WriteString(conn, "-ERR unknown command\r\n")
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
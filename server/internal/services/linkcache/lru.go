@@ -0,0 +1,95 @@
+package linkcache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRU is an in-process Backend holding at most size entries, evicting the
+// least recently used first. Expired entries are dropped when read.
+type LRU struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // front is most recently used
+	items map[string]*list.Element
+	now   func() time.Time
+}
+
+type lruItem struct {
+	code      string
+	entry     Entry
+	expiresAt time.Time
+}
+
+// NewLRU creates an LRU holding up to size entries
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element, size),
+		now:   time.Now,
+	}
+}
+
+// Get implements Backend
+func (c *LRU) Get(ctx context.Context, code string) (Entry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[code]
+	if !ok {
+		return Entry{}, false, nil
+	}
+	item := elem.Value.(*lruItem)
+	if !c.now().Before(item.expiresAt) {
+		c.remove(elem)
+		return Entry{}, false, nil
+	}
+	c.order.MoveToFront(elem)
+	return item.entry, true, nil
+}
+
+// Set implements Backend
+func (c *LRU) Set(ctx context.Context, code string, entry Entry, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(ttl)
+	if elem, ok := c.items[code]; ok {
+		item := elem.Value.(*lruItem)
+		item.entry, item.expiresAt = entry, expiresAt
+		c.order.MoveToFront(elem)
+		return nil
+	}
+
+	c.items[code] = c.order.PushFront(&lruItem{code: code, entry: entry, expiresAt: expiresAt})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+// Delete implements Backend
+func (c *LRU) Delete(ctx context.Context, code string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[code]; ok {
+		c.remove(elem)
+	}
+	return nil
+}
+
+// Len returns the number of cached entries, expired ones included
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*lruItem).code)
+}
@@ -0,0 +1,84 @@
+package linkcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces cache keys in a shared Redis
+const redisKeyPrefix = "dev4url:link:"
+
+// defaultRedisTimeout bounds dialing and each command; a slow cache is
+// worse than none
+const defaultRedisTimeout = 250 * time.Millisecond
+
+// Redis is a Backend shared between replicas through Redis
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis parses a redis:// or rediss:// URL such as
+// redis://:password@host:6379/0 and keeps at most poolSize connections
+func NewRedis(rawURL string, poolSize int) (*Redis, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	opts.PoolSize = poolSize
+	opts.DialTimeout = defaultRedisTimeout
+	opts.ReadTimeout = defaultRedisTimeout
+	opts.WriteTimeout = defaultRedisTimeout
+	// Requests give up on the cache when their own deadline passes
+	opts.ContextTimeoutEnabled = true
+	return &Redis{client: redis.NewClient(opts)}, nil
+}
+
+// Get implements Backend. Values are the link as JSON, or "0" for a cached
+// miss.
+func (r *Redis) Get(ctx context.Context, code string) (Entry, bool, error) {
+	value, err := r.client.Get(ctx, redisKeyPrefix+code).Result()
+	if errors.Is(err, redis.Nil) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, err
+	}
+	if value == "0" {
+		return Entry{}, true, nil
+	}
+
+	var entry Entry
+	if err := json.Unmarshal([]byte(value), &entry.Link); err != nil {
+		return Entry{}, false, fmt.Errorf("unexpected redis value for %q: %w", code, err)
+	}
+	entry.Found = true
+	return entry, true, nil
+}
+
+// Set implements Backend
+func (r *Redis) Set(ctx context.Context, code string, entry Entry, ttl time.Duration) error {
+	value := "0"
+	if entry.Found {
+		encoded, err := json.Marshal(entry.Link)
+		if err != nil {
+			return err
+		}
+		value = string(encoded)
+	}
+	return r.client.Set(ctx, redisKeyPrefix+code, value, ttl).Err()
+}
+
+// Delete implements Backend
+func (r *Redis) Delete(ctx context.Context, code string) error {
+	return r.client.Del(ctx, redisKeyPrefix+code).Err()
+}
+
+// Close closes the connections
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
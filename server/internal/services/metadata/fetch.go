@@ -0,0 +1,154 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	ErrBlockedAddress = errors.New("destination resolves to a non-public address")
+	ErrNotHTML        = errors.New("destination is not an HTML page")
+)
+
+// maxRedirects bounds the redirects followed while fetching a page
+const maxRedirects = 5
+
+// blockedPrefixes are special-purpose ranges that are not reachable on the
+// public internet, on top of what netip reports as private or local
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 can reach private IPv4
+	netip.MustParsePrefix("2002::/16"),    // 6to4, same
+}
+
+// publicAddress reports whether addr is safe to connect to from inside our
+// network
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// FetcherConfig limits what a fetch may cost
+type FetcherConfig struct {
+	Timeout   time.Duration
+	MaxBytes  int64
+	UserAgent string
+}
+
+// Fetcher downloads HTML pages from user supplied URLs. Addresses are
+// checked when connecting, after DNS resolution, so a hostname cannot
+// point the fetcher at internal services, including through redirects or
+// DNS rebinding.
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+	agent    string
+}
+
+// NewFetcher creates a fetcher with the given limits
+func NewFetcher(config FetcherConfig) *Fetcher {
+	return newFetcher(config, publicAddress)
+}
+
+func newFetcher(config FetcherConfig, allowed func(netip.Addr) bool) *Fetcher {
+	dialer := &net.Dialer{
+		Timeout: config.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !allowed(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+			}
+			return nil
+		},
+	}
+
+	transport := &http.Transport{
+		// No proxy: it would resolve and connect on our behalf, unchecked
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   config.Timeout,
+		ResponseHeaderTimeout: config.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	return &Fetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   config.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+				}
+				return nil
+			},
+		},
+		maxBytes: config.MaxBytes,
+		agent:    config.UserAgent,
+	}
+}
+
+// Fetch downloads at most MaxBytes of an HTML page and returns the body,
+// converted to UTF-8, and the final URL after redirects
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) ([]byte, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, nil, fmt.Errorf("unsupported scheme %q", req.URL.Scheme)
+	}
+	req.Header.Set("User-Agent", f.agent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("destination returned status %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, nil, fmt.Errorf("%w: %q", ErrNotHTML, mediaType)
+	}
+
+	// Metadata lives in the head, a truncated body is fine
+	utf8, err := charset.NewReader(io.LimitReader(resp.Body, f.maxBytes), resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := io.ReadAll(utf8)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, resp.Request.URL, nil
+}
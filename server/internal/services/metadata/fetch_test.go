@@ -0,0 +1,71 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testConfig = FetcherConfig{Timeout: 2 * time.Second, MaxBytes: 1 << 16, UserAgent: "test"}
+
+func TestFetcherBlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request to reach a loopback server")
+	}))
+	defer server.Close()
+
+	_, _, err := NewFetcher(testConfig).Fetch(context.Background(), server.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Fetch() error = %v, want ErrBlockedAddress", err)
+	}
+
+	for _, addr := range []string{"10.1.2.3", "169.254.169.254", "::1", "fd00::1", "100.64.0.1", "::ffff:127.0.0.1"} {
+		if publicAddress(netip.MustParseAddr(addr)) {
+			t.Errorf("Expected %s to be blocked", addr)
+		}
+	}
+	if !publicAddress(netip.MustParseAddr("93.184.216.34")) {
+		t.Error("Expected a public address to be allowed")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/start", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		w.Write([]byte("<title>Caf\xe9</title>" + strings.Repeat("x", 1<<17)))
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetcher := newFetcher(testConfig, func(netip.Addr) bool { return true })
+
+	body, final, err := fetcher.Fetch(context.Background(), server.URL+"/start")
+	if err != nil {
+		t.Fatalf("Fetch() unexpected error: %v", err)
+	}
+	if final.Path != "/page" {
+		t.Errorf("Expected the final URL after redirects, got %s", final)
+	}
+	if !strings.HasPrefix(string(body), "<title>Café</title>") {
+		t.Errorf("Expected the body converted to UTF-8, got %q", body[:20])
+	}
+	if len(body) > int(testConfig.MaxBytes)+1 {
+		t.Errorf("Expected the body capped near %d bytes, got %d", testConfig.MaxBytes, len(body))
+	}
+
+	if _, _, err := fetcher.Fetch(context.Background(), server.URL+"/image"); !errors.Is(err, ErrNotHTML) {
+		t.Errorf("Fetch() of an image error = %v, want ErrNotHTML", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Package metadata fetches the title, description, preview image and
+// favicon of link destinations, so shared short links unfurl nicely in
+// chat apps and social networks.
+package metadata
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/metrics"
+	"github.com/dev4dreams/dev4url/internal/models"
+)
+
+// Store saves metadata fetched from destination on a link, unless the
+// link's destination has changed since; see db.Database
+type Store interface {
+	SetLinkMetadata(ctx context.Context, code, destination string, meta *models.LinkMetadata) error
+}
+
+// Service fetches metadata in the background with bounded concurrency.
+// Fetches that find no free slot are skipped; the link simply does not
+// unfurl.
+type Service struct {
+	fetcher *Fetcher
+	store   Store
+	timeout time.Duration
+	slots   chan struct{}
+	wg      sync.WaitGroup
+	logger  *slog.Logger
+}
+
+// NewService creates a service running at most concurrency fetches at once
+func NewService(fetcher *Fetcher, store Store, timeout time.Duration, concurrency int, logger *slog.Logger) *Service {
+	return &Service{
+		fetcher: fetcher,
+		store:   store,
+		timeout: timeout,
+		slots:   make(chan struct{}, concurrency),
+		logger:  logger,
+	}
+}
+
+// Refresh fetches and stores the metadata of a link's destination without
+// blocking the caller
+func (s *Service) Refresh(code, destination string) {
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		metrics.MetadataFetches.WithLabelValues(metrics.MetadataSkipped).Inc()
+		s.logger.Warn("Skipped metadata fetch, too many in flight", slog.String("short_code", code))
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			<-s.slots
+			s.wg.Done()
+		}()
+		s.refresh(code, destination)
+	}()
+}
+
+func (s *Service) refresh(code, destination string) {
+	// Detached from the creating request, which has already been answered
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	body, finalURL, err := s.fetcher.Fetch(ctx, destination)
+	if err != nil {
+		metrics.MetadataFetches.WithLabelValues(metrics.MetadataError).Inc()
+		s.logger.Info("Failed to fetch destination metadata",
+			slog.String("short_code", code), slog.Any("error", err))
+		return
+	}
+
+	meta := Parse(body, finalURL)
+	meta.FetchedAt = time.Now()
+	if err := s.store.SetLinkMetadata(ctx, code, destination, meta); err != nil {
+		metrics.MetadataFetches.WithLabelValues(metrics.MetadataError).Inc()
+		s.logger.Error("Failed to store destination metadata",
+			slog.String("short_code", code), slog.Any("error", err))
+		return
+	}
+	metrics.MetadataFetches.WithLabelValues(metrics.MetadataFetched).Inc()
+}
+
+// Wait blocks until in-flight fetches finish or ctx is done
+func (s *Service) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,50 @@
+package metadata
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/models"
+)
+
+type recordingStore struct {
+	code, destination string
+	meta              *models.LinkMetadata
+}
+
+func (s *recordingStore) SetLinkMetadata(ctx context.Context, code, destination string, meta *models.LinkMetadata) error {
+	s.code, s.destination, s.meta = code, destination, meta
+	return nil
+}
+
+// The store is told which destination was fetched, not where it
+// redirected, so it can drop metadata of a destination since replaced
+func TestRefreshStoresFetchedDestination(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/start", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<title>Launch</title>"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	store := &recordingStore{}
+	fetcher := newFetcher(testConfig, func(netip.Addr) bool { return true })
+	service := NewService(fetcher, store, 2*time.Second, 1, slog.Default())
+	service.refresh("abc1234", server.URL+"/start")
+
+	if store.code != "abc1234" || store.destination != server.URL+"/start" {
+		t.Errorf("Expected metadata stored for abc1234 at %s/start, got %s at %s", server.URL, store.code, store.destination)
+	}
+	if store.meta == nil || store.meta.Title != "Launch" {
+		t.Errorf("Unexpected metadata %+v", store.meta)
+	}
+}
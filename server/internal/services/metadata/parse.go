@@ -0,0 +1,138 @@
+package metadata
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"github.com/dev4dreams/dev4url/internal/models"
+	"golang.org/x/net/html"
+)
+
+// Field length caps; anything longer is cut
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+	maxURLLength         = 2048
+)
+
+// Parse extracts the title, description, preview image and favicon from
+// the head of an HTML page. Open Graph tags win over Twitter card tags,
+// which win over the plain title and description. Relative URLs are
+// resolved against base; only http(s) URLs are kept.
+func Parse(body []byte, base *url.URL) *models.LinkMetadata {
+	var title, description, ogTitle, ogDescription, ogImage, twitterTitle, twitterDescription, twitterImage, icon string
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	inTitle := false
+loop:
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			// io.EOF, or the end of a truncated body
+			break loop
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = string(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				break loop
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = tokenizer.TagAttr()
+				attrs[string(key)] = string(value)
+			}
+
+			switch string(name) {
+			case "title":
+				inTitle = true
+			case "body":
+				break loop
+			case "meta":
+				key := strings.ToLower(attrs["property"])
+				if key == "" {
+					key = strings.ToLower(attrs["name"])
+				}
+				content := attrs["content"]
+				switch key {
+				case "description":
+					description = content
+				case "og:title":
+					ogTitle = content
+				case "og:description":
+					ogDescription = content
+				case "og:image", "og:image:url", "og:image:secure_url":
+					if ogImage == "" {
+						ogImage = content
+					}
+				case "twitter:title":
+					twitterTitle = content
+				case "twitter:description":
+					twitterDescription = content
+				case "twitter:image", "twitter:image:src":
+					if twitterImage == "" {
+						twitterImage = content
+					}
+				}
+			case "link":
+				for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+					if (rel == "icon" || rel == "apple-touch-icon") && icon == "" {
+						icon = attrs["href"]
+					}
+				}
+			}
+		}
+	}
+
+	return &models.LinkMetadata{
+		Title:       clean(firstNonEmpty(ogTitle, twitterTitle, title), maxTitleLength),
+		Description: clean(firstNonEmpty(ogDescription, twitterDescription, description), maxDescriptionLength),
+		Image:       resolve(base, firstNonEmpty(ogImage, twitterImage)),
+		Favicon:     resolve(base, firstNonEmpty(icon, "/favicon.ico")),
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// clean collapses whitespace and cuts s to at most max runes
+func clean(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > max {
+		s = string(runes[:max])
+	}
+	return s
+}
+
+// resolve makes ref absolute against base, dropping anything that is not
+// an http(s) URL
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	parsed, err := base.Parse(ref)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ""
+	}
+	resolved := parsed.String()
+	if len(resolved) > maxURLLength {
+		return ""
+	}
+	return resolved
+}
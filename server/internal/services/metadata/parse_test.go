@@ -0,0 +1,55 @@
+package metadata
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://example.org/blog/post")
+	page := `<!DOCTYPE html><html><head>
+<title>  Plain
+  title </title>
+<meta name="description" content="Plain description">
+<meta name="twitter:title" content="Twitter title">
+<meta property="og:title" content="OG title">
+<meta name="twitter:image" content="https://cdn.example.org/card.png">
+<meta property="og:image" content="/images/og.png">
+<link rel="shortcut icon" href="icons/fav.png">
+</head><body><meta property="og:description" content="ignored, in the body"></body></html>`
+
+	meta := Parse([]byte(page), base)
+
+	if meta.Title != "OG title" {
+		t.Errorf("Title = %q, want the Open Graph title", meta.Title)
+	}
+	if meta.Description != "Plain description" {
+		t.Errorf("Description = %q, want the meta description", meta.Description)
+	}
+	if meta.Image != "https://example.org/images/og.png" {
+		t.Errorf("Image = %q, want the resolved Open Graph image", meta.Image)
+	}
+	if meta.Favicon != "https://example.org/blog/icons/fav.png" {
+		t.Errorf("Favicon = %q, want the resolved icon", meta.Favicon)
+	}
+}
+
+func TestParseFallbacks(t *testing.T) {
+	base, _ := url.Parse("https://example.org/")
+	long := strings.Repeat("word ", 400)
+	page := `<html><head><title>` + long + `</title>
+<meta property="og:image" content="javascript:alert(1)">`
+
+	meta := Parse([]byte(page), base)
+
+	if n := len([]rune(meta.Title)); n != maxTitleLength {
+		t.Errorf("Expected the title cut to %d runes, got %d", maxTitleLength, n)
+	}
+	if meta.Image != "" {
+		t.Errorf("Expected a javascript: image to be dropped, got %q", meta.Image)
+	}
+	if meta.Favicon != "https://example.org/favicon.ico" {
+		t.Errorf("Favicon = %q, want the default /favicon.ico", meta.Favicon)
+	}
+}
@@ -0,0 +1,163 @@
+package rules
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/metrics"
+	"github.com/dev4dreams/dev4url/internal/utils"
+)
+
+// Reloader keeps a URLValidator's config in sync with its sources
+type Reloader struct {
+	loader    *Loader
+	validator *utils.URLValidator
+	interval  time.Duration
+	logger    *slog.Logger
+
+	// fileModTime detects rules file changes between polls
+	fileModTime time.Time
+	fileSize    int64
+}
+
+// NewReloader creates a reloader polling its sources every interval
+func NewReloader(loader *Loader, validator *utils.URLValidator, interval time.Duration, logger *slog.Logger) *Reloader {
+	return &Reloader{
+		loader:    loader,
+		validator: validator,
+		interval:  interval,
+		logger:    logger,
+	}
+}
+
+// Run reloads on every signal received on reload (e.g. SIGHUP) and on each
+// poll interval, until ctx is done. The database is read on every poll; the
+// rules file only when its modification time or size changed.
+func (r *Reloader) Run(ctx context.Context, reload <-chan os.Signal) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload:
+			r.logger.Info("Reloading validator rules on signal")
+			r.Reload(ctx)
+		case <-ticker.C:
+			if r.loader.store != nil || r.fileChanged() {
+				r.Reload(ctx)
+			}
+		}
+	}
+}
+
+// Reload loads the rules and swaps them in when they changed. On error the
+// current rules stay in place.
+func (r *Reloader) Reload(ctx context.Context) error {
+	r.fileChanged() // record the file state this load is based on
+
+	next, err := r.loader.Load(ctx)
+	if err != nil {
+		metrics.ValidatorReloads.WithLabelValues("error").Inc()
+		r.logger.Error("Failed to reload validator rules, keeping current rules", slog.Any("error", err))
+		return err
+	}
+
+	current := r.validator.Config()
+	changes := diff(current, next)
+	if len(changes) == 0 {
+		metrics.ValidatorReloads.WithLabelValues("unchanged").Inc()
+		return nil
+	}
+
+	if err := r.validator.SetConfig(next); err != nil {
+		metrics.ValidatorReloads.WithLabelValues("error").Inc()
+		r.logger.Error("Rejected invalid validator rules, keeping current rules", slog.Any("error", err))
+		return err
+	}
+	metrics.ValidatorReloads.WithLabelValues("applied").Inc()
+	r.logger.Info("Validator rules reloaded", changes...)
+	return nil
+}
+
+// fileChanged reports whether the rules file differs from the last check
+func (r *Reloader) fileChanged() bool {
+	if r.loader.path == "" {
+		return false
+	}
+	info, err := os.Stat(r.loader.path)
+	if err != nil {
+		// Let Load report the error
+		return true
+	}
+	changed := !info.ModTime().Equal(r.fileModTime) || info.Size() != r.fileSize
+	r.fileModTime = info.ModTime()
+	r.fileSize = info.Size()
+	return changed
+}
+
+// diff describes what changed between two configs as log attributes
+func diff(old, new *utils.Config) []any {
+	var attrs []any
+	if old.MaxURLLength != new.MaxURLLength {
+		attrs = append(attrs, slog.Group("max_url_length",
+			slog.Int("old", old.MaxURLLength), slog.Int("new", new.MaxURLLength)))
+	}
+	attrs = appendListDiff(attrs, "allowed_domains", old.AllowedDomains, new.AllowedDomains)
+	attrs = appendListDiff(attrs, "blocked_domains", old.BlockedDomains, new.BlockedDomains)
+	attrs = appendListDiff(attrs, "blocked_patterns", old.BlockedPatterns, new.BlockedPatterns)
+	attrs = appendListDiff(attrs, "rules", ruleStrings(old.Rules), ruleStrings(new.Rules))
+
+	// Rules are compared in full: an edit their strings leave out, such as
+	// a new message, must still be applied
+	if edited := editedRules(old.Rules, new.Rules); len(edited) > 0 {
+		attrs = append(attrs, slog.Any("rules_edited", edited))
+	} else if len(attrs) == 0 && !slices.Equal(old.Rules, new.Rules) {
+		attrs = append(attrs, slog.String("rules", "reordered"))
+	}
+	return attrs
+}
+
+// editedRules lists the rules in both configs that changed in a way their
+// strings do not show
+func editedRules(old, new []utils.Rule) []string {
+	var edited []string
+	for _, rule := range new {
+		i := slices.IndexFunc(old, func(o utils.Rule) bool { return o.String() == rule.String() })
+		if i >= 0 && old[i] != rule {
+			edited = append(edited, rule.String())
+		}
+	}
+	return edited
+}
+
+func ruleStrings(rules []utils.Rule) []string {
+	out := make([]string, len(rules))
+	for i, rule := range rules {
+		out[i] = rule.String()
+	}
+	return out
+}
+
+func appendListDiff(attrs []any, name string, old, new []string) []any {
+	var added, removed []string
+	for _, value := range new {
+		if !slices.Contains(old, value) {
+			added = append(added, value)
+		}
+	}
+	for _, value := range old {
+		if !slices.Contains(new, value) {
+			removed = append(removed, value)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return attrs
+	}
+	return append(attrs, slog.Group(name,
+		slog.Any("added", added), slog.Any("removed", removed)))
+}
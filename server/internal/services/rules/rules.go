@@ -0,0 +1,91 @@
+// Package rules loads URL validator rules from a file and the blocklist
+// table, and hot-swaps them into a running URLValidator.
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// BlocklistStore provides blocklist entries kept in the database
+type BlocklistStore interface {
+	ListBlocklist(ctx context.Context) ([]models.BlocklistEntry, error)
+}
+
+// Loader builds validator configs from the defaults, an optional rules
+// file and an optional blocklist store
+type Loader struct {
+	path  string
+	store BlocklistStore
+}
+
+// NewLoader creates a loader. Either source may be empty/nil.
+func NewLoader(path string, store BlocklistStore) *Loader {
+	return &Loader{path: path, store: store}
+}
+
+// Load returns a fresh config. Lists present in the rules file replace the
+// defaults; database entries are appended on top.
+func (l *Loader) Load(ctx context.Context) (*utils.Config, error) {
+	config := utils.DefaultConfig()
+
+	if l.path != "" {
+		if err := loadFile(l.path, config); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.store != nil {
+		entries, err := l.store.ListBlocklist(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			switch entry.Kind {
+			case "domain":
+				config.BlockedDomains = appendUnique(config.BlockedDomains, entry.Value)
+			case "pattern":
+				config.BlockedPatterns = appendUnique(config.BlockedPatterns, entry.Value)
+			}
+		}
+	}
+
+	if config.MaxURLLength <= 0 {
+		return nil, fmt.Errorf("max_url_length must be positive, got %d", config.MaxURLLength)
+	}
+	if err := config.Compile(); err != nil {
+		return nil, fmt.Errorf("invalid validator rules: %w", err)
+	}
+	return config, nil
+}
+
+// loadFile decodes a YAML (or JSON) rules file over config
+func loadFile(path string, config *utils.Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening rules file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error parsing rules file %s: %w", path, err)
+	}
+	return nil
+}
+
+func appendUnique(list []string, value string) []string {
+	if slices.Contains(list, value) {
+		return list
+	}
+	return append(list, value)
+}
@@ -0,0 +1,134 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/dev4dreams/dev4url/internal/models"
+	"github.com/dev4dreams/dev4url/internal/utils"
+)
+
+type mockStore struct {
+	entries []models.BlocklistEntry
+	err     error
+}
+
+func (m *mockStore) ListBlocklist(ctx context.Context) ([]models.BlocklistEntry, error) {
+	return m.entries, m.err
+}
+
+func writeRules(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write rules file: %v", err)
+	}
+}
+
+func TestLoaderMergesSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules(t, path, "max_url_length: 512\nblocked_domains:\n  - phishing.test\n")
+
+	store := &mockStore{entries: []models.BlocklistEntry{
+		{Kind: "domain", Value: "scam.example"},
+		{Kind: "domain", Value: "phishing.test"},
+		{Kind: "pattern", Value: "evil("},
+	}}
+
+	config, err := NewLoader(path, store).Load(context.Background())
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+
+	if config.MaxURLLength != 512 {
+		t.Errorf("Expected max_url_length 512, got %d", config.MaxURLLength)
+	}
+	wantDomains := []string{"phishing.test", "scam.example"}
+	if !slices.Equal(config.BlockedDomains, wantDomains) {
+		t.Errorf("Expected blocked domains %v, got %v", wantDomains, config.BlockedDomains)
+	}
+	// Patterns were not in the file, so the defaults are kept
+	defaults := utils.DefaultConfig().BlockedPatterns
+	if len(config.BlockedPatterns) != len(defaults)+1 || !slices.Contains(config.BlockedPatterns, "evil(") {
+		t.Errorf("Expected default patterns plus evil(, got %v", config.BlockedPatterns)
+	}
+}
+
+func TestLoaderRejectsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules(t, path, "blocked_domain:\n  - typo.example\n")
+
+	if _, err := NewLoader(path, nil).Load(context.Background()); err == nil {
+		t.Fatal("Expected an error for an unknown key")
+	}
+}
+
+func TestReloaderSwapsRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules(t, path, "blocked_domains:\n  - old.example\n")
+
+	validator := utils.NewURLValidator(nil)
+	reloader := NewReloader(NewLoader(path, nil), validator, 0, slog.Default())
+	ctx := context.Background()
+
+	if err := reloader.Reload(ctx); err != nil {
+		t.Fatalf("Reload() unexpected error: %v", err)
+	}
+	if result := validator.ValidateURL(ctx, "https://old.example/path"); result.IsValid {
+		t.Error("Expected old.example to be blocked after the first load")
+	}
+
+	writeRules(t, path, "blocked_domains:\n  - new.example\n")
+	if err := reloader.Reload(ctx); err != nil {
+		t.Fatalf("Reload() unexpected error: %v", err)
+	}
+	if result := validator.ValidateURL(ctx, "https://old.example/path"); !result.IsValid {
+		t.Errorf("Expected old.example to be allowed after reload, got %v", result.Errors)
+	}
+	if result := validator.ValidateURL(ctx, "https://new.example/path"); result.IsValid {
+		t.Error("Expected new.example to be blocked after reload")
+	}
+
+	// A failing source keeps the current rules
+	before := validator.Config()
+	reloader.loader.store = &mockStore{err: errors.New("database unavailable")}
+	if err := reloader.Reload(ctx); err == nil {
+		t.Fatal("Expected an error from the failing store")
+	}
+	if validator.Config() != before {
+		t.Error("Expected the current rules to stay in place after a failed reload")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := &utils.Config{MaxURLLength: 100, BlockedDomains: []string{"a.example", "b.example"}}
+	new := &utils.Config{MaxURLLength: 100, BlockedDomains: []string{"b.example", "c.example"}}
+
+	if changes := diff(old, old); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v", changes)
+	}
+	if changes := diff(old, new); len(changes) != 1 {
+		t.Errorf("Expected one changed list, got %v", changes)
+	}
+
+	// A new message alone is a change too
+	rule := utils.Rule{ID: "r1", Type: utils.RuleExact, Pattern: "a.example"}
+	edited := rule
+	edited.Message = "Not allowed"
+	if changes := diff(&utils.Config{Rules: []utils.Rule{rule}}, &utils.Config{Rules: []utils.Rule{edited}}); len(changes) != 1 {
+		t.Errorf("Expected the edited rule, got %v", changes)
+	}
+}
+
+func TestLoaderRejectsInvalidRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRules(t, path, "rules:\n  - id: broken\n    type: regex\n    scope: path\n    pattern: \"(unclosed\"\n")
+
+	if _, err := NewLoader(path, nil).Load(context.Background()); err == nil {
+		t.Fatal("Expected an error for an invalid regex rule")
+	}
+}
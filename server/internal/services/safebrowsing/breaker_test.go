@@ -0,0 +1,66 @@
+package safebrowsing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker := NewBreaker(3, 30*time.Second)
+	breaker.now = func() time.Time { return now }
+
+	// Failures below the threshold keep the breaker closed
+	breaker.Failure()
+	breaker.Failure()
+	if state := breaker.State(); state != BreakerClosed {
+		t.Fatalf("Expected closed after 2 failures, got %s", state)
+	}
+
+	breaker.Failure()
+	if state := breaker.State(); state != BreakerOpen {
+		t.Fatalf("Expected open after 3 failures, got %s", state)
+	}
+	if breaker.Allow() {
+		t.Error("Expected open breaker to reject requests")
+	}
+
+	// After the cooldown a single trial request is allowed
+	now = now.Add(31 * time.Second)
+	if !breaker.Allow() {
+		t.Fatal("Expected trial request after cooldown")
+	}
+	if breaker.Allow() {
+		t.Error("Expected only one trial request while half-open")
+	}
+
+	// A failed trial reopens the breaker
+	breaker.Failure()
+	if state := breaker.State(); state != BreakerOpen {
+		t.Fatalf("Expected open after failed trial, got %s", state)
+	}
+
+	// A successful trial closes it
+	now = now.Add(31 * time.Second)
+	if !breaker.Allow() {
+		t.Fatal("Expected trial request after second cooldown")
+	}
+	breaker.Success()
+	if state := breaker.State(); state != BreakerClosed {
+		t.Fatalf("Expected closed after successful trial, got %s", state)
+	}
+	if !breaker.Allow() {
+		t.Error("Expected closed breaker to allow requests")
+	}
+}
+
+func TestNilBreaker(t *testing.T) {
+	var breaker *Breaker
+	breaker.Failure()
+	if !breaker.Allow() {
+		t.Error("Expected nil breaker to allow requests")
+	}
+	if state := breaker.State(); state != BreakerClosed {
+		t.Errorf("Expected nil breaker to be closed, got %s", state)
+	}
+}
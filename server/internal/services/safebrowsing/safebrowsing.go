@@ -2,20 +2,33 @@ package safebrowsing
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/dev4dreams/dev4url/internal/metrics"
+	"github.com/dev4dreams/dev4url/internal/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const defaultBaseURL = "https://safebrowsing.googleapis.com/v4/threatMatches:find"
 
+// Breaker defaults: open after 5 consecutive failures, retry after 30 seconds
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
 type SafeBrowsingChecker interface {
-	IsURLSafe(url string) (bool, error)
-	CheckURL(url string) (*ThreatResponse, error)
+	IsURLSafe(ctx context.Context, url string) (bool, error)
+	CheckURL(ctx context.Context, url string) (*ThreatResponse, error)
 }
 
 // SafeBrowsingService handles communication with the Google Safe Browsing API
@@ -23,17 +36,36 @@ type SafeBrowsingService struct {
 	apiKey     string
 	baseURL    string
 	httpClient *http.Client
+	logger     *slog.Logger
+	breaker    *Breaker
 }
 
 // NewSafeBrowsingService creates a new instance of SafeBrowsingService
-func NewSafeBrowsingService(apiKey string) *SafeBrowsingService {
+func NewSafeBrowsingService(apiKey string, logger *slog.Logger) *SafeBrowsingService {
 	return &SafeBrowsingService{
 		apiKey:  apiKey,
 		baseURL: defaultBaseURL,
 		httpClient: &http.Client{
 			Timeout: 6 * time.Second,
+			// Propagates trace context and records a client span per call
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
 		},
+		logger:  logger,
+		breaker: NewBreaker(defaultBreakerThreshold, defaultBreakerCooldown),
+	}
+}
+
+// BreakerState reports whether calls to the API are currently short-circuited
+func (s *SafeBrowsingService) BreakerState() BreakerState {
+	return s.breaker.State()
+}
+
+// log returns the service logger, falling back to the default logger
+func (s *SafeBrowsingService) log() *slog.Logger {
+	if s.logger == nil {
+		return slog.Default()
 	}
+	return s.logger
 }
 
 // validateURL checks if the provided URL is valid
@@ -55,7 +87,6 @@ func validateURL(urlStr string) error {
 
 	// Block reserved TLDs like .test
 	if strings.HasSuffix(parsedURL.Host, ".test") {
-		fmt.Println("Find error for debuging: ", parsedURL)
 		return fmt.Errorf("URLs with .test domains are not allowed")
 	}
 
@@ -72,7 +103,13 @@ func validateURL(urlStr string) error {
 }
 
 // CheckURL checks a single URL against the Safe Browsing API
-func (s *SafeBrowsingService) CheckURL(url string) (*ThreatResponse, error) {
+func (s *SafeBrowsingService) CheckURL(ctx context.Context, url string) (_ *ThreatResponse, err error) {
+	ctx, span := tracing.Start(ctx, "safebrowsing.check")
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	// Validate URL before making API call
 	if err := validateURL(url); err != nil {
 		return nil, err
@@ -98,36 +135,64 @@ func (s *SafeBrowsingService) CheckURL(url string) (*ThreatResponse, error) {
 		return nil, fmt.Errorf("error marshaling request: %w", err)
 	}
 
-	fullURL := fmt.Sprintf("%s?key=%s", s.baseURL, s.apiKey)
-	req, err := http.NewRequest("POST", fullURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", s.baseURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	// The key goes in a header: the URL ends up in traces and in logged
+	// transport errors
+	req.Header.Set("X-Goog-Api-Key", s.apiKey)
+
+	if !s.breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
 
+	start := time.Now()
 	resp, err := s.httpClient.Do(req)
+	latency := time.Since(start)
+	metrics.SafeBrowsingDuration.Observe(latency.Seconds())
 	if err != nil {
+		metrics.SafeBrowsingErrors.Inc()
+		s.breaker.Failure()
+		s.log().Warn("Safe Browsing request failed",
+			slog.Duration("latency", latency), slog.Any("error", err))
 		return nil, fmt.Errorf("error making request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// Only outages trip the breaker; other statuses prove the API is reachable
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		s.breaker.Failure()
+	} else {
+		s.breaker.Success()
+	}
+
 	if resp.StatusCode != http.StatusOK {
+		metrics.SafeBrowsingErrors.Inc()
 		body, _ := io.ReadAll(resp.Body)
+		s.log().Warn("Safe Browsing returned unexpected status",
+			slog.Int("status", resp.StatusCode), slog.Duration("latency", latency))
 		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
 	}
 
 	var threatResponse ThreatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&threatResponse); err != nil {
+		metrics.SafeBrowsingErrors.Inc()
 		return nil, fmt.Errorf("error decoding response: %w", err)
 	}
 
+	span.SetAttributes(attribute.Int("safebrowsing.matches", len(threatResponse.Matches)))
+	s.log().Debug("Safe Browsing check completed",
+		slog.Int("matches", len(threatResponse.Matches)), slog.Duration("latency", latency))
+
 	return &threatResponse, nil
 }
 
 // IsURLSafe returns true if the URL is safe, false if it's potentially dangerous
-func (s *SafeBrowsingService) IsURLSafe(url string) (bool, error) {
-	response, err := s.CheckURL(url)
+func (s *SafeBrowsingService) IsURLSafe(ctx context.Context, url string) (bool, error) {
+	response, err := s.CheckURL(ctx, url)
 
 	if err != nil {
 		return false, err
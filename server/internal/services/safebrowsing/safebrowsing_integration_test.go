@@ -2,6 +2,8 @@ package safebrowsing
 
 import (
 	"bufio"
+	"context"
+	"log/slog"
 	"os"
 	"strings"
 	"testing"
@@ -65,7 +67,7 @@ func skipIfNoAPIKey(t *testing.T) string {
 
 func TestIntegration_SafeBrowsingService_CheckURL(t *testing.T) {
 	apiKey := skipIfNoAPIKey(t)
-	service := NewSafeBrowsingService(apiKey)
+	service := NewSafeBrowsingService(apiKey, slog.Default())
 
 	tests := []struct {
 		name          string
@@ -117,7 +119,7 @@ func TestIntegration_SafeBrowsingService_CheckURL(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			response, err := service.CheckURL(tt.url)
+			response, err := service.CheckURL(context.Background(), tt.url)
 
 			// Check error expectations
 			if tt.expectError {
@@ -155,7 +157,7 @@ func TestIntegration_SafeBrowsingService_CheckURL(t *testing.T) {
 
 func TestIntegration_SafeBrowsingService_IsURLSafe(t *testing.T) {
 	apiKey := skipIfNoAPIKey(t)
-	service := NewSafeBrowsingService(apiKey)
+	service := NewSafeBrowsingService(apiKey, slog.Default())
 
 	tests := []struct {
 		name          string
@@ -187,7 +189,7 @@ func TestIntegration_SafeBrowsingService_IsURLSafe(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			isSafe, err := service.IsURLSafe(tt.url)
+			isSafe, err := service.IsURLSafe(context.Background(), tt.url)
 
 			// Check error expectations
 			if tt.expectError {
@@ -213,11 +215,11 @@ func TestIntegration_SafeBrowsingService_IsURLSafe(t *testing.T) {
 
 func TestIntegration_RateLimiting(t *testing.T) {
 	apiKey := skipIfNoAPIKey(t)
-	service := NewSafeBrowsingService(apiKey)
+	service := NewSafeBrowsingService(apiKey, slog.Default())
 
 	// Test multiple rapid requests to check rate limiting
 	for i := 0; i < 5; i++ {
-		_, err := service.IsURLSafe("https://www.example.com")
+		_, err := service.IsURLSafe(context.Background(), "https://www.example.com")
 		if err != nil {
 			t.Errorf("Request %d failed: %v", i+1, err)
 		}
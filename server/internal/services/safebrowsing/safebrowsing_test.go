@@ -1,9 +1,13 @@
 package safebrowsing
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -122,6 +126,14 @@ func TestSafeBrowsingService_CheckURL(t *testing.T) {
 					t.Errorf("Expected Content-Type: application/json, got %s", r.Header.Get("Content-Type"))
 				}
 
+				// The key is a header, never part of the URL
+				if r.Header.Get("X-Goog-Api-Key") != "test-api-key" {
+					t.Errorf("Expected the API key header, got %q", r.Header.Get("X-Goog-Api-Key"))
+				}
+				if r.URL.RawQuery != "" {
+					t.Errorf("Expected no query string, got %q", r.URL.RawQuery)
+				}
+
 				// Set response status code
 				w.WriteHeader(tt.mockStatusCode)
 
@@ -140,7 +152,7 @@ func TestSafeBrowsingService_CheckURL(t *testing.T) {
 			}
 
 			// Make request
-			response, err := service.CheckURL(tt.url)
+			response, err := service.CheckURL(context.Background(), tt.url)
 
 			// Check error expectation
 			if tt.expectError && err == nil {
@@ -230,7 +242,7 @@ func TestSafeBrowsingService_IsURLSafe(t *testing.T) {
 			}
 
 			// Make request
-			isSafe, err := service.IsURLSafe(tt.url)
+			isSafe, err := service.IsURLSafe(context.Background(), tt.url)
 
 			// Check error expectation
 			if tt.expectError && err == nil {
@@ -250,7 +262,7 @@ func TestSafeBrowsingService_IsURLSafe(t *testing.T) {
 
 func TestNewSafeBrowsingService(t *testing.T) {
 	apiKey := "test-api-key"
-	service := NewSafeBrowsingService(apiKey)
+	service := NewSafeBrowsingService(apiKey, slog.Default())
 
 	if service == nil {
 		t.Fatal("Expected non-nil service")
@@ -272,3 +284,25 @@ func TestNewSafeBrowsingService(t *testing.T) {
 		t.Errorf("Expected timeout=10s, got %v", service.httpClient.Timeout)
 	}
 }
+
+// A failed request must not leak the API key into logs or errors
+func TestCheckURLFailureHidesKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	var logs bytes.Buffer
+	service := &SafeBrowsingService{
+		apiKey:     "secret-api-key",
+		baseURL:    server.URL,
+		httpClient: &http.Client{Timeout: time.Second},
+		logger:     slog.New(slog.NewJSONHandler(&logs, nil)),
+	}
+
+	_, err := service.CheckURL(context.Background(), "https://example.com")
+	if err == nil {
+		t.Fatal("Expected an error from a closed server")
+	}
+	if strings.Contains(err.Error(), "secret-api-key") || strings.Contains(logs.String(), "secret-api-key") {
+		t.Errorf("Expected the API key to stay out of errors and logs, got %v and %s", err, logs.String())
+	}
+}
@@ -0,0 +1,232 @@
+// Package targeting picks the destination of links with targeting rules,
+// by the visitor's country, operating system, device class and language.
+package targeting
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/dev4dreams/dev4url/internal/models"
+)
+
+// MaxRules bounds the rules of a single link
+const MaxRules = 20
+
+// Operating systems told apart by ParseUserAgent
+const (
+	OSIOS      = "ios"
+	OSAndroid  = "android"
+	OSWindows  = "windows"
+	OSMacOS    = "macos"
+	OSLinux    = "linux"
+	OSChromeOS = "chromeos"
+)
+
+// Device classes told apart by ParseUserAgent
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+)
+
+var (
+	knownOS      = []string{OSIOS, OSAndroid, OSWindows, OSMacOS, OSLinux, OSChromeOS}
+	knownDevices = []string{DeviceMobile, DeviceTablet, DeviceDesktop}
+)
+
+// Visitor is what rules are matched against. Empty fields are unknown and
+// match no condition.
+type Visitor struct {
+	Country  string // upper case ISO 3166-1 alpha-2
+	OS       string
+	Device   string
+	Language string // lower case BCP 47 tag, e.g. en-gb
+}
+
+// CountryLookup finds the country of an address, see geoip.Reader
+type CountryLookup interface {
+	Country(addr netip.Addr) (string, error)
+}
+
+// Locator works out who is visiting from a request
+type Locator struct {
+	countryHeader  string
+	clientIPHeader string
+	geoip          CountryLookup
+}
+
+// NewLocator creates a locator reading the country from countryHeader and
+// falling back to looking up the client address in geoip. Any of them may
+// be empty/nil. The client address is read from clientIPHeader, or the
+// connection when that is empty.
+func NewLocator(countryHeader, clientIPHeader string, geoip CountryLookup) *Locator {
+	return &Locator{
+		countryHeader:  countryHeader,
+		clientIPHeader: clientIPHeader,
+		geoip:          geoip,
+	}
+}
+
+// Visitor describes the visitor making r. A nil Locator tells everything
+// but the country.
+func (l *Locator) Visitor(r *http.Request) Visitor {
+	os, device := ParseUserAgent(r.UserAgent())
+	return Visitor{
+		Country:  l.country(r),
+		OS:       os,
+		Device:   device,
+		Language: PreferredLanguage(r.Header.Get("Accept-Language")),
+	}
+}
+
+func (l *Locator) country(r *http.Request) string {
+	if l == nil {
+		return ""
+	}
+	if l.countryHeader != "" {
+		// Cloudflare sends XX for unknown and T1 for Tor
+		country := strings.ToUpper(strings.TrimSpace(r.Header.Get(l.countryHeader)))
+		if isCountryCode(country) && country != "XX" && country != "T1" {
+			return country
+		}
+	}
+	if l.geoip == nil {
+		return ""
+	}
+	addr, ok := l.clientAddr(r)
+	if !ok {
+		return ""
+	}
+	country, err := l.geoip.Country(addr)
+	if err != nil {
+		return ""
+	}
+	return strings.ToUpper(country)
+}
+
+// clientAddr returns the visitor's address, the first of a list in the
+// client IP header
+func (l *Locator) clientAddr(r *http.Request) (netip.Addr, bool) {
+	raw := r.RemoteAddr
+	if l.clientIPHeader != "" {
+		first, _, _ := strings.Cut(r.Header.Get(l.clientIPHeader), ",")
+		raw = strings.TrimSpace(first)
+	} else if host, _, err := net.SplitHostPort(raw); err == nil {
+		raw = host
+	}
+	addr, err := netip.ParseAddr(raw)
+	return addr, err == nil
+}
+
+// Destination returns the URL of the first rule v matches, or false when
+// none does
+func Destination(rules []models.TargetRule, v Visitor) (string, bool) {
+	for _, rule := range rules {
+		if matches(rule, v) {
+			return rule.URL, true
+		}
+	}
+	return "", false
+}
+
+func matches(rule models.TargetRule, v Visitor) bool {
+	if len(rule.Countries) > 0 && !slices.ContainsFunc(rule.Countries, func(c string) bool {
+		return strings.EqualFold(c, v.Country)
+	}) {
+		return false
+	}
+	if len(rule.OS) > 0 && !slices.ContainsFunc(rule.OS, func(os string) bool {
+		return strings.EqualFold(os, v.OS)
+	}) {
+		return false
+	}
+	if len(rule.Devices) > 0 && !slices.ContainsFunc(rule.Devices, func(d string) bool {
+		return strings.EqualFold(d, v.Device)
+	}) {
+		return false
+	}
+	if len(rule.Languages) > 0 && !slices.ContainsFunc(rule.Languages, func(lang string) bool {
+		return languageMatches(strings.ToLower(lang), v.Language)
+	}) {
+		return false
+	}
+	return true
+}
+
+// languageMatches reports whether tag is rangeTag or a subtag of it, so
+// en matches en-gb but not eo
+func languageMatches(rangeTag, tag string) bool {
+	return tag != "" && (tag == rangeTag || strings.HasPrefix(tag, rangeTag+"-"))
+}
+
+// Validate checks the conditions of rules given at creation or update. The
+// destinations are left to the URL validator.
+func Validate(rules []models.TargetRule) error {
+	if len(rules) > MaxRules {
+		return fmt.Errorf("at most %d targeting rules are allowed", MaxRules)
+	}
+	for i, rule := range rules {
+		if rule.URL == "" {
+			return fmt.Errorf("targets[%d]: url is required", i)
+		}
+		if len(rule.Countries)+len(rule.OS)+len(rule.Devices)+len(rule.Languages) == 0 {
+			return fmt.Errorf("targets[%d]: at least one condition is required", i)
+		}
+		if err := validateRule(rule); err != nil {
+			return fmt.Errorf("targets[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateRule(rule models.TargetRule) error {
+	for _, country := range rule.Countries {
+		if !isCountryCode(country) {
+			return fmt.Errorf("country %q is not a two letter code like DE", country)
+		}
+	}
+	for _, os := range rule.OS {
+		if !slices.Contains(knownOS, os) {
+			return fmt.Errorf("os %q must be one of %s", os, strings.Join(knownOS, ", "))
+		}
+	}
+	for _, device := range rule.Devices {
+		if !slices.Contains(knownDevices, device) {
+			return fmt.Errorf("device %q must be one of %s", device, strings.Join(knownDevices, ", "))
+		}
+	}
+	for _, lang := range rule.Languages {
+		if !isLanguageTag(lang) {
+			return fmt.Errorf("language %q is not a tag like en or pt-BR", lang)
+		}
+	}
+	return nil
+}
+
+func isCountryCode(s string) bool {
+	return len(s) == 2 && s[0] >= 'A' && s[0] <= 'Z' && s[1] >= 'A' && s[1] <= 'Z'
+}
+
+// isLanguageTag accepts language ranges of letter and digit subtags, the
+// first being letters only
+func isLanguageTag(s string) bool {
+	if s == "" || len(s) > 35 {
+		return false
+	}
+	for i, sub := range strings.Split(s, "-") {
+		if len(sub) == 0 || len(sub) > 8 || (i == 0 && len(sub) < 2) {
+			return false
+		}
+		for _, c := range sub {
+			letter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+			if !letter && (i == 0 || c < '0' || c > '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
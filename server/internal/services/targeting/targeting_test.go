@@ -0,0 +1,169 @@
+package targeting
+
+import (
+	"errors"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/dev4dreams/dev4url/internal/models"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name       string
+		ua         string
+		wantOS     string
+		wantDevice string
+	}{
+		{"iphone", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1", OSIOS, DeviceMobile},
+		{"ipad", "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1", OSIOS, DeviceTablet},
+		{"android phone", "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36", OSAndroid, DeviceMobile},
+		{"android tablet", "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", OSAndroid, DeviceTablet},
+		{"windows", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", OSWindows, DeviceDesktop},
+		{"macos", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15", OSMacOS, DeviceDesktop},
+		{"chromeos", "Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", OSChromeOS, DeviceDesktop},
+		{"linux", "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0", OSLinux, DeviceDesktop},
+		{"firefox os style mobile", "Mozilla/5.0 (Mobile; rv:48.0) Gecko/48.0 Firefox/48.0", "", DeviceMobile},
+		{"curl", "curl/8.5.0", "", ""},
+		{"empty", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os, device := ParseUserAgent(tt.ua)
+			if os != tt.wantOS || device != tt.wantDevice {
+				t.Errorf("ParseUserAgent() = %q, %q, want %q, %q", os, device, tt.wantOS, tt.wantDevice)
+			}
+		})
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := map[string]string{
+		"":                                   "",
+		"de-CH,de;q=0.9,en;q=0.8":            "de-ch",
+		"en;q=0.5, fr":                       "fr",
+		"*, es;q=0.1":                        "es",
+		"en;q=0, pt-BR;q=0.3":                "pt-br",
+		"fr;q=0.8, it;q=0.8":                 "fr",
+		"x;q=1, nl":                          "nl",
+		"not a tag!, sv":                     "sv",
+		"en-US;q=bogus, de;q=0.9":            "en-us",
+		"zh-Hant-TW; q=0.9 , ja ; q = 0.95 ": "ja",
+	}
+
+	for header, want := range tests {
+		if got := PreferredLanguage(header); got != want {
+			t.Errorf("PreferredLanguage(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+type fakeGeoIP map[netip.Addr]string
+
+func (f fakeGeoIP) Country(addr netip.Addr) (string, error) {
+	if country, ok := f[addr]; ok {
+		return country, nil
+	}
+	return "", errors.New("not found")
+}
+
+func TestLocatorCountry(t *testing.T) {
+	geoip := fakeGeoIP{
+		netip.MustParseAddr("203.0.113.7"): "au",
+		netip.MustParseAddr("2001:db8::1"): "JP",
+	}
+	tests := []struct {
+		name       string
+		locator    *Locator
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"header", NewLocator("CF-IPCountry", "", geoip), "203.0.113.7:1234", map[string]string{"CF-IPCountry": "de"}, "DE"},
+		{"unknown header falls back", NewLocator("CF-IPCountry", "", geoip), "203.0.113.7:1234", map[string]string{"CF-IPCountry": "XX"}, "AU"},
+		{"missing header falls back", NewLocator("CF-IPCountry", "", geoip), "[2001:db8::1]:443", nil, "JP"},
+		{"client ip header", NewLocator("", "X-Forwarded-For", geoip), "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "203.0.113.7, 10.0.0.2"}, "AU"},
+		{"not in database", NewLocator("", "", geoip), "198.51.100.1:1234", nil, ""},
+		{"nothing configured", NewLocator("", "", nil), "203.0.113.7:1234", map[string]string{"CF-IPCountry": "DE"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/abc", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+			if got := tt.locator.Visitor(r).Country; got != tt.want {
+				t.Errorf("Visitor().Country = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDestination(t *testing.T) {
+	rules := []models.TargetRule{
+		{OS: []string{OSIOS}, URL: "https://apps.apple.com/app/id1"},
+		{OS: []string{OSAndroid}, URL: "https://play.google.com/store/apps/details?id=app"},
+		{Countries: []string{"DE", "AT"}, Languages: []string{"de"}, URL: "https://example.com/de"},
+		{Devices: []string{DeviceTablet}, URL: "https://example.com/tablet"},
+	}
+
+	tests := []struct {
+		name    string
+		visitor Visitor
+		want    string
+		wantOK  bool
+	}{
+		{"ios", Visitor{OS: OSIOS, Device: DeviceTablet}, "https://apps.apple.com/app/id1", true},
+		{"android", Visitor{OS: OSAndroid, Device: DeviceMobile, Country: "DE"}, "https://play.google.com/store/apps/details?id=app", true},
+		{"all conditions", Visitor{OS: OSWindows, Country: "AT", Language: "de-at"}, "https://example.com/de", true},
+		{"one condition fails", Visitor{OS: OSWindows, Country: "CH", Language: "de"}, "", false},
+		{"language prefix only on subtags", Visitor{Country: "DE", Language: "dee"}, "", false},
+		{"later rule", Visitor{OS: OSWindows, Device: DeviceTablet}, "https://example.com/tablet", true},
+		{"unknown visitor", Visitor{}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Destination(rules, tt.visitor)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Destination() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := models.TargetRule{Countries: []string{"US"}, URL: "https://example.com/us"}
+	tooMany := make([]models.TargetRule, MaxRules+1)
+	for i := range tooMany {
+		tooMany[i] = valid
+	}
+
+	tests := []struct {
+		name    string
+		rules   []models.TargetRule
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"valid", []models.TargetRule{valid, {OS: []string{OSIOS}, Devices: []string{DeviceTablet}, Languages: []string{"pt-BR"}, URL: "https://example.com"}}, false},
+		{"too many", tooMany, true},
+		{"no url", []models.TargetRule{{Countries: []string{"US"}}}, true},
+		{"no condition", []models.TargetRule{{URL: "https://example.com"}}, true},
+		{"lower case country", []models.TargetRule{{Countries: []string{"us"}, URL: "https://example.com"}}, true},
+		{"unknown os", []models.TargetRule{{OS: []string{"symbian"}, URL: "https://example.com"}}, true},
+		{"unknown device", []models.TargetRule{{Devices: []string{"watch"}, URL: "https://example.com"}}, true},
+		{"bad language", []models.TargetRule{{Languages: []string{"en_US"}, URL: "https://example.com"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rules); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package targeting
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseUserAgent returns the operating system and device class of a
+// browser's User-Agent. Either is empty when it cannot be told. iPads
+// asking for desktop sites look like Macs and are reported as such.
+func ParseUserAgent(ua string) (os, device string) {
+	if ua == "" {
+		return "", ""
+	}
+
+	switch {
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPod"):
+		return OSIOS, DeviceMobile
+	case strings.Contains(ua, "iPad"):
+		return OSIOS, DeviceTablet
+	case strings.Contains(ua, "Android"):
+		// Android tablets leave Mobile out
+		if strings.Contains(ua, "Mobile") {
+			return OSAndroid, DeviceMobile
+		}
+		return OSAndroid, DeviceTablet
+	case strings.Contains(ua, "Windows Phone"):
+		return OSWindows, DeviceMobile
+	case strings.Contains(ua, "Windows"):
+		os = OSWindows
+	case strings.Contains(ua, "CrOS"):
+		os = OSChromeOS
+	case strings.Contains(ua, "Macintosh"), strings.Contains(ua, "Mac OS X"):
+		os = OSMacOS
+	case strings.Contains(ua, "Linux"), strings.Contains(ua, "X11"):
+		os = OSLinux
+	}
+
+	switch {
+	case strings.Contains(ua, "Tablet"):
+		device = DeviceTablet
+	case strings.Contains(ua, "Mobi"):
+		device = DeviceMobile
+	case os != "":
+		device = DeviceDesktop
+	}
+	return os, device
+}
+
+// PreferredLanguage returns the lower case tag the visitor weighs highest
+// in an Accept-Language header, or "" when there is none. Only the first
+// choice counts, so rules for several languages go to the one the visitor
+// reads best.
+func PreferredLanguage(header string) string {
+	type choice struct {
+		tag string
+		q   float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(name) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 && isLanguageTag(tag) {
+			choices = append(choices, choice{tag, q})
+		}
+	}
+	if len(choices) == 0 {
+		return ""
+	}
+	// Stable, so equal weights keep the order they were sent in
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	return choices[0].tag
+}
@@ -0,0 +1,272 @@
+// Package workerid assigns the short code generator's worker ID from
+// config, from a StatefulSet pod ordinal, or from a lease held in the
+// database.
+package workerid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/config"
+	"github.com/dev4dreams/dev4url/internal/core"
+)
+
+var (
+	ErrNoWorkerID = errors.New("no worker ID available")
+	ErrLeaseLost  = errors.New("worker ID lease lost")
+)
+
+// LeaseStore keeps worker ID leases, see db.Database
+type LeaseStore interface {
+	AcquireWorkerLease(ctx context.Context, holder string, maxID int64, ttl time.Duration) (int64, bool, error)
+	RenewWorkerLease(ctx context.Context, workerID int64, holder string, ttl time.Duration) (bool, error)
+	ReleaseWorkerLease(ctx context.Context, workerID int64, holder string) error
+}
+
+// Assign resolves the worker ID for cfg. For the lease source the returned
+// Lease must be kept alive with Keep and freed with Release; it is nil for
+// the other sources.
+func Assign(ctx context.Context, cfg config.GeneratorConfig, maxID int64, store LeaseStore, logger *slog.Logger) (int64, *Lease, error) {
+	switch cfg.WorkerIDSource {
+	case config.WorkerIDStatic:
+		return checkRange(int64(cfg.WorkerID), maxID)
+	case config.WorkerIDOrdinal:
+		name := cfg.PodName
+		if name == "" {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return 0, nil, fmt.Errorf("%w: reading hostname: %v", ErrNoWorkerID, err)
+			}
+			name = hostname
+		}
+		ordinal, err := Ordinal(name)
+		if err != nil {
+			return 0, nil, err
+		}
+		return checkRange(ordinal, maxID)
+	case config.WorkerIDLease:
+		lease, err := Acquire(ctx, store, maxID, cfg.LeaseTTL, logger)
+		if err != nil {
+			return 0, nil, err
+		}
+		return lease.WorkerID(), lease, nil
+	default:
+		return 0, nil, fmt.Errorf("%w: unknown source %q", ErrNoWorkerID, cfg.WorkerIDSource)
+	}
+}
+
+func checkRange(workerID, maxID int64) (int64, *Lease, error) {
+	if workerID < 0 || workerID > maxID {
+		return 0, nil, fmt.Errorf("%w: %d is outside 0-%d", ErrNoWorkerID, workerID, maxID)
+	}
+	return workerID, nil, nil
+}
+
+// Ordinal parses the trailing ordinal of a StatefulSet pod name such as
+// "api-3"
+func Ordinal(podName string) (int64, error) {
+	i := strings.LastIndexByte(podName, '-')
+	if i < 0 {
+		return 0, fmt.Errorf("%w: pod name %q has no ordinal suffix", ErrNoWorkerID, podName)
+	}
+	ordinal, err := strconv.ParseInt(podName[i+1:], 10, 64)
+	if err != nil || ordinal < 0 {
+		return 0, fmt.Errorf("%w: pod name %q has no ordinal suffix", ErrNoWorkerID, podName)
+	}
+	return ordinal, nil
+}
+
+// Lease is a worker ID held in the database. It stays valid while
+// heartbeats succeed and is considered lost shortly before its TTL passes
+// without one, because another instance may then take the ID over.
+type Lease struct {
+	store    LeaseStore
+	workerID int64
+	holder   string
+	ttl      time.Duration
+	logger   *slog.Logger
+
+	mu        sync.Mutex
+	expiresAt time.Time // local, conservative estimate of the DB expiry
+	lost      chan struct{}
+	lostOnce  sync.Once
+}
+
+// Acquire leases a free worker ID in [0, maxID]
+func Acquire(ctx context.Context, store LeaseStore, maxID int64, ttl time.Duration, logger *slog.Logger) (*Lease, error) {
+	if store == nil {
+		return nil, fmt.Errorf("%w: no lease store configured", ErrNoWorkerID)
+	}
+	holder, err := newHolder()
+	if err != nil {
+		return nil, err
+	}
+
+	// Instances starting together race for the same free ID and all but one
+	// come back empty handed. Each lost race means another instance took an
+	// ID, so after maxID+1 attempts every ID really is leased.
+	var started time.Time
+	var workerID int64
+	var ok bool
+	for attempt := int64(0); attempt <= maxID && !ok; attempt++ {
+		started = time.Now()
+		workerID, ok, err = store.AcquireWorkerLease(ctx, holder, maxID, ttl)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: all %d worker IDs are leased", ErrNoWorkerID, maxID+1)
+	}
+
+	logger.Info("Leased worker ID",
+		slog.Int64("worker_id", workerID),
+		slog.String("holder", holder),
+		slog.Duration("ttl", ttl))
+	return &Lease{
+		store:     store,
+		workerID:  workerID,
+		holder:    holder,
+		ttl:       ttl,
+		logger:    logger,
+		expiresAt: started.Add(ttl),
+		lost:      make(chan struct{}),
+	}, nil
+}
+
+// newHolder identifies this process in the leases table
+func newHolder() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("generating lease holder: %w", err)
+	}
+	return fmt.Sprintf("%s/%d/%s", hostname, os.Getpid(), hex.EncodeToString(suffix)), nil
+}
+
+// WorkerID returns the leased ID
+func (l *Lease) WorkerID() int64 {
+	return l.workerID
+}
+
+// Lost is closed once the lease can no longer be trusted
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Valid reports whether the ID may still be used. It turns false a safety
+// margin before the lease could expire in the database, so the ID is out of
+// use before another instance can take it over.
+func (l *Lease) Valid() bool {
+	select {
+	case <-l.lost:
+		return false
+	default:
+		return time.Now().Before(l.deadline())
+	}
+}
+
+// deadline is when the lease stops being trusted without a renewal
+func (l *Lease) deadline() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// A tenth of the TTL covers clock drift between us and the database
+	return l.expiresAt.Add(-l.ttl / 10)
+}
+
+// Keep renews the lease every third of its TTL until ctx is done or the
+// lease is lost. Failed renewals are retried until the deadline passes.
+func (l *Lease) Keep(ctx context.Context) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	expiry := time.NewTimer(time.Until(l.deadline()))
+	defer expiry.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-expiry.C:
+			cause := errors.New("not renewed before the deadline")
+			if lastErr != nil {
+				cause = fmt.Errorf("%v: %w", cause, lastErr)
+			}
+			l.markLost(cause)
+			return
+		case <-ticker.C:
+			started := time.Now()
+			// A renewal hanging past the deadline is no use
+			renewCtx, cancel := context.WithDeadline(ctx, l.deadline())
+			ok, err := l.store.RenewWorkerLease(renewCtx, l.workerID, l.holder, l.ttl)
+			cancel()
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				lastErr = err
+				l.logger.Warn("Failed to renew worker ID lease, retrying",
+					slog.Int64("worker_id", l.workerID),
+					slog.Time("deadline", l.deadline()),
+					slog.Any("error", err))
+			case !ok:
+				l.markLost(errors.New("lease taken over or expired"))
+				return
+			default:
+				l.mu.Lock()
+				l.expiresAt = started.Add(l.ttl)
+				l.mu.Unlock()
+				expiry.Reset(time.Until(l.deadline()))
+			}
+		}
+	}
+}
+
+func (l *Lease) markLost(cause error) {
+	l.lostOnce.Do(func() {
+		l.logger.Error("Worker ID lease lost",
+			slog.Int64("worker_id", l.workerID),
+			slog.Any("error", fmt.Errorf("%w: %v", ErrLeaseLost, cause)))
+		close(l.lost)
+	})
+}
+
+// Guard makes next fail with ErrLeaseLost once lease is no longer valid,
+// so no code is generated with a worker ID another instance may hold
+func Guard(next core.CodeGenerator, lease *Lease) core.CodeGenerator {
+	return guardedGenerator{next: next, lease: lease}
+}
+
+type guardedGenerator struct {
+	next  core.CodeGenerator
+	lease *Lease
+}
+
+// Generate implements core.CodeGenerator
+func (g guardedGenerator) Generate(ctx context.Context) (string, error) {
+	if !g.lease.Valid() {
+		return "", ErrLeaseLost
+	}
+	return g.next.Generate(ctx)
+}
+
+// Release frees the lease so a new instance can reuse the ID immediately
+func (l *Lease) Release(ctx context.Context) error {
+	if err := l.store.ReleaseWorkerLease(ctx, l.workerID, l.holder); err != nil {
+		return err
+	}
+	l.logger.Info("Released worker ID lease", slog.Int64("worker_id", l.workerID))
+	return nil
+}
@@ -0,0 +1,281 @@
+package workerid
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dev4dreams/dev4url/internal/config"
+	"github.com/dev4dreams/dev4url/internal/core"
+)
+
+type mockStore struct {
+	mu       sync.Mutex
+	held     map[int64]string
+	renewErr error
+	renewOK  bool
+	released []int64
+}
+
+func newMockStore() *mockStore {
+	return &mockStore{held: make(map[int64]string), renewOK: true}
+}
+
+func (m *mockStore) AcquireWorkerLease(ctx context.Context, holder string, maxID int64, ttl time.Duration) (int64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id := int64(0); id <= maxID; id++ {
+		if _, taken := m.held[id]; !taken {
+			m.held[id] = holder
+			return id, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func (m *mockStore) RenewWorkerLease(ctx context.Context, workerID int64, holder string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.renewOK && m.held[workerID] == holder, m.renewErr
+}
+
+func (m *mockStore) ReleaseWorkerLease(ctx context.Context, workerID int64, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.held[workerID] == holder {
+		delete(m.held, workerID)
+		m.released = append(m.released, workerID)
+	}
+	return nil
+}
+
+func TestOrdinal(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    int64
+		wantErr bool
+	}{
+		{name: "api-0", want: 0},
+		{name: "dev4url-api-12", want: 12},
+		{name: "api-7d9f8b-xk2lp", wantErr: true},
+		{name: "laptop", wantErr: true},
+		{name: "api-", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Ordinal(tt.name)
+		if tt.wantErr {
+			if !errors.Is(err, ErrNoWorkerID) {
+				t.Errorf("Ordinal(%q) error = %v, want ErrNoWorkerID", tt.name, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Ordinal(%q) = %d, %v, want %d", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestAssign(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	id, lease, err := Assign(ctx, config.GeneratorConfig{WorkerIDSource: config.WorkerIDStatic, WorkerID: 7}, 255, nil, logger)
+	if err != nil || id != 7 || lease != nil {
+		t.Errorf("static: got %d, %v, %v", id, lease, err)
+	}
+
+	if _, _, err := Assign(ctx, config.GeneratorConfig{WorkerIDSource: config.WorkerIDStatic, WorkerID: 256}, 255, nil, logger); !errors.Is(err, ErrNoWorkerID) {
+		t.Errorf("static out of range: expected ErrNoWorkerID, got %v", err)
+	}
+
+	id, _, err = Assign(ctx, config.GeneratorConfig{WorkerIDSource: config.WorkerIDOrdinal, PodName: "api-4"}, 255, nil, logger)
+	if err != nil || id != 4 {
+		t.Errorf("ordinal: got %d, %v", id, err)
+	}
+
+	store := newMockStore()
+	cfg := config.GeneratorConfig{WorkerIDSource: config.WorkerIDLease, LeaseTTL: time.Minute}
+	first, firstLease, err := Assign(ctx, cfg, 1, store, logger)
+	if err != nil || firstLease == nil {
+		t.Fatalf("lease: unexpected error %v", err)
+	}
+	second, _, err := Assign(ctx, cfg, 1, store, logger)
+	if err != nil || second == first {
+		t.Errorf("lease: expected a second distinct ID, got %d and %d (%v)", first, second, err)
+	}
+	if _, _, err := Assign(ctx, cfg, 1, store, logger); !errors.Is(err, ErrNoWorkerID) {
+		t.Errorf("lease: expected ErrNoWorkerID when all IDs are held, got %v", err)
+	}
+
+	if err := firstLease.Release(ctx); err != nil {
+		t.Fatalf("Release() unexpected error: %v", err)
+	}
+	if id, _, err := Assign(ctx, cfg, 1, store, logger); err != nil || id != first {
+		t.Errorf("lease: expected released ID %d to be reused, got %d (%v)", first, id, err)
+	}
+}
+
+// racingStore lets callers pick a free ID together before claiming it, as
+// concurrent inserts do: only one of them gets the ID
+type racingStore struct {
+	*mockStore
+	picked sync.WaitGroup
+	seen   sync.Map // holders past their first attempt
+}
+
+func (s *racingStore) AcquireWorkerLease(ctx context.Context, holder string, maxID int64, ttl time.Duration) (int64, bool, error) {
+	s.mu.Lock()
+	candidate := int64(-1)
+	for id := int64(0); id <= maxID; id++ {
+		if _, taken := s.held[id]; !taken {
+			candidate = id
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if _, retry := s.seen.LoadOrStore(holder, true); !retry {
+		s.picked.Done()
+		s.picked.Wait()
+	}
+	if candidate < 0 {
+		return 0, false, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, taken := s.held[candidate]; taken {
+		return 0, false, nil
+	}
+	s.held[candidate] = holder
+	return candidate, true, nil
+}
+
+func TestAcquireConcurrent(t *testing.T) {
+	const instances = 4
+	store := &racingStore{mockStore: newMockStore()}
+	store.picked.Add(instances)
+
+	var wg sync.WaitGroup
+	ids := make(chan int64, instances)
+	for i := 0; i < instances; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lease, err := Acquire(context.Background(), store, instances-1, time.Minute, slog.Default())
+			if err != nil {
+				t.Errorf("Acquire() unexpected error: %v", err)
+				return
+			}
+			ids <- lease.WorkerID()
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int64]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("Worker ID %d leased twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != instances {
+		t.Errorf("Expected %d distinct worker IDs, got %v", instances, seen)
+	}
+}
+
+func TestLeaseLost(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("taken over", func(t *testing.T) {
+		store := newMockStore()
+		lease, err := Acquire(ctx, store, 3, 30*time.Millisecond, slog.Default())
+		if err != nil {
+			t.Fatalf("Acquire() unexpected error: %v", err)
+		}
+		go lease.Keep(ctx)
+
+		store.mu.Lock()
+		store.renewOK = false
+		store.mu.Unlock()
+
+		select {
+		case <-lease.Lost():
+		case <-time.After(time.Second):
+			t.Fatal("Expected the lease to be reported lost")
+		}
+	})
+
+	t.Run("renewals fail past the TTL", func(t *testing.T) {
+		store := newMockStore()
+		store.renewErr = errors.New("database unavailable")
+		lease, err := Acquire(ctx, store, 3, 30*time.Millisecond, slog.Default())
+		if err != nil {
+			t.Fatalf("Acquire() unexpected error: %v", err)
+		}
+		go lease.Keep(ctx)
+
+		select {
+		case <-lease.Lost():
+		case <-time.After(time.Second):
+			t.Fatal("Expected the lease to be reported lost")
+		}
+	})
+
+	t.Run("healthy", func(t *testing.T) {
+		store := newMockStore()
+		lease, err := Acquire(ctx, store, 3, 30*time.Millisecond, slog.Default())
+		if err != nil {
+			t.Fatalf("Acquire() unexpected error: %v", err)
+		}
+		go lease.Keep(ctx)
+
+		select {
+		case <-lease.Lost():
+			t.Fatal("Expected a renewed lease to stay valid")
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
+
+func TestGuardRefusesAfterExpiry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := newMockStore()
+	store.renewErr = errors.New("database unavailable")
+	const ttl = 60 * time.Millisecond
+	lease, err := Acquire(ctx, store, 3, ttl, slog.Default())
+	if err != nil {
+		t.Fatalf("Acquire() unexpected error: %v", err)
+	}
+	go lease.Keep(ctx)
+
+	base, err := core.NewGenerator(lease.WorkerID())
+	if err != nil {
+		t.Fatalf("NewGenerator() unexpected error: %v", err)
+	}
+	generator := Guard(base, lease)
+	if _, err := generator.Generate(ctx); err != nil {
+		t.Fatalf("Expected codes while the lease is fresh, got %v", err)
+	}
+
+	// Reported lost before the lease could expire in the database
+	select {
+	case <-lease.Lost():
+	case <-time.After(ttl):
+		t.Fatal("Expected the lease to be lost before its TTL passed")
+	}
+	if lease.Valid() {
+		t.Error("Expected a lost lease to be invalid")
+	}
+	if _, err := generator.Generate(ctx); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Expected ErrLeaseLost after expiry, got %v", err)
+	}
+}
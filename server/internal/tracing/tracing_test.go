@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracesURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+	}{
+		{"http://localhost:4318", "http://localhost:4318/v1/traces"},
+		{"http://localhost:4318/", "http://localhost:4318/v1/traces"},
+		{"https://collector.example.com/otlp/v1/traces", "https://collector.example.com/otlp/v1/traces"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			got, err := tracesURL(tt.endpoint)
+			if err != nil || got != tt.want {
+				t.Errorf("tracesURL() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitExportsToCollector(t *testing.T) {
+	paths := make(chan string, 10)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	shutdown, err := Init(context.Background(), Config{Endpoint: collector.URL, SampleRate: 1})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	_, span := Start(context.Background(), "test")
+	span.End()
+	// Shutdown flushes the batch
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+
+	select {
+	case path := <-paths:
+		if path != "/v1/traces" {
+			t.Errorf("Expected spans at /v1/traces, got %s", path)
+		}
+	default:
+		t.Fatal("Expected the span to reach the collector")
+	}
+}
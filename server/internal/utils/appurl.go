@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// forbiddenAppSchemes can never be allowlisted for app links: they run
+// code or read local data in the browser. http and https links are web
+// URLs and go through URLValidator instead.
+var forbiddenAppSchemes = []string{
+	"http", "https", "javascript", "vbscript", "data", "file", "blob", "about", "filesystem", "view-source",
+}
+
+// maxAppURLLength matches the default web URL limit
+const maxAppURLLength = 2048
+
+// AppURLValidator checks custom scheme URLs that open mobile apps, such as
+// myapp://item/42, against an explicit allowlist of schemes. It is
+// separate from URLValidator so web destinations stay http and https only.
+type AppURLValidator struct {
+	schemes []string
+}
+
+// NewAppURLValidator creates a validator allowing the given schemes. With
+// none, every app URL is refused.
+func NewAppURLValidator(schemes []string) *AppURLValidator {
+	allowed := make([]string, 0, len(schemes))
+	for _, scheme := range schemes {
+		allowed = append(allowed, strings.ToLower(scheme))
+	}
+	return &AppURLValidator{schemes: allowed}
+}
+
+// IsAppScheme reports whether scheme may be allowlisted for app links
+func IsAppScheme(scheme string) bool {
+	if scheme == "" || slices.Contains(forbiddenAppSchemes, strings.ToLower(scheme)) {
+		return false
+	}
+	for i, c := range scheme {
+		letter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+		if !letter && (i == 0 || !(c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.')) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateAppURL returns an error unless urlStr is a well formed URL with
+// an allowed scheme
+func (v *AppURLValidator) ValidateAppURL(urlStr string) error {
+	if strings.TrimSpace(urlStr) == "" {
+		return fmt.Errorf("app URL cannot be empty")
+	}
+	if len(urlStr) > maxAppURLLength {
+		return fmt.Errorf("app URL exceeds maximum length of %d characters", maxAppURLLength)
+	}
+	if err := validateSecurity(urlStr); err != nil {
+		return err
+	}
+
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return fmt.Errorf("invalid app URL format: %w", err)
+	}
+	scheme := strings.ToLower(parsedURL.Scheme)
+	if !IsAppScheme(scheme) || !slices.Contains(v.schemes, scheme) {
+		return fmt.Errorf("app URL scheme %q is not allowed", parsedURL.Scheme)
+	}
+	if parsedURL.Opaque == "" && parsedURL.Host == "" && parsedURL.Path == "" {
+		return fmt.Errorf("app URL must have a path or host")
+	}
+	return nil
+}
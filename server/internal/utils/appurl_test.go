@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidateAppURL(t *testing.T) {
+	validator := NewAppURLValidator([]string{"myapp", "FB", "javascript", "https"})
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{"allowed scheme", "myapp://item/42?ref=short", false},
+		{"allowed scheme, case folded", "fb://profile/123", false},
+		{"opaque", "myapp:item/42", false},
+		{"empty", "", true},
+		{"no scheme", "item/42", true},
+		{"scheme not allowed", "otherapp://item/42", true},
+		{"web URL", "https://example.com", true},
+		{"forbidden even when listed", "javascript://%0Aalert(1)", true},
+		{"nothing after the scheme", "myapp://", true},
+		{"control characters", "myapp://item/\n42", true},
+		{"too long", "myapp://" + strings.Repeat("a", maxAppURLLength), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validator.ValidateAppURL(tt.url); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAppURL(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsAppScheme(t *testing.T) {
+	for scheme, want := range map[string]bool{
+		"myapp":      true,
+		"com.app+v2": true,
+		"":           false,
+		"2app":       false,
+		"my_app":     false,
+		"https":      false,
+		"DATA":       false,
+	} {
+		if got := IsAppScheme(scheme); got != want {
+			t.Errorf("IsAppScheme(%q) = %v, want %v", scheme, got, want)
+		}
+	}
+}
+
+// The web validator keeps refusing app schemes
+func TestURLValidatorRejectsAppScheme(t *testing.T) {
+	result := NewURLValidator(nil).ValidateURL(context.Background(), "myapp://item/42")
+	if result.IsValid {
+		t.Error("Expected an app URL to fail web URL validation")
+	}
+}
@@ -0,0 +1,277 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// RuleType selects how a rule's pattern is matched
+type RuleType string
+
+const (
+	// RuleExact matches the host exactly
+	RuleExact RuleType = "exact"
+	// RuleSuffix matches "*.example.com" against subdomains only, and a
+	// bare "example.com" against the domain and all of its subdomains
+	RuleSuffix RuleType = "suffix"
+	// RuleRegistrable matches every host whose registrable domain
+	// (eTLD+1 per the public suffix list) equals the pattern
+	RuleRegistrable RuleType = "registrable"
+	// RuleRegex matches a regular expression against the rule's scope
+	RuleRegex RuleType = "regex"
+)
+
+// RuleScope is the part of the URL a regex rule is matched against
+type RuleScope string
+
+const (
+	ScopeHost  RuleScope = "host"
+	ScopePath  RuleScope = "path"  // percent-decoded path
+	ScopeQuery RuleScope = "query" // percent-decoded query string
+)
+
+// RuleAction is what happens when a rule matches
+type RuleAction string
+
+const (
+	ActionBlock RuleAction = "block"
+	ActionWarn  RuleAction = "warn"
+	// ActionAllow exempts the URL from every other rule
+	ActionAllow RuleAction = "allow"
+)
+
+// Rule is a single validator rule. Host rules (exact, suffix, registrable)
+// ignore Scope.
+type Rule struct {
+	ID      string     `json:"id" yaml:"id"`
+	Type    RuleType   `json:"type" yaml:"type"`
+	Pattern string     `json:"pattern" yaml:"pattern"`
+	Scope   RuleScope  `json:"scope,omitempty" yaml:"scope,omitempty"`
+	Action  RuleAction `json:"action,omitempty" yaml:"action,omitempty"` // defaults to block
+	// Message replaces the default error or warning text
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// String describes the rule, used when diffing configs
+func (r Rule) String() string {
+	s := fmt.Sprintf("%s(%s %s", r.ID, r.action(), r.Type)
+	if r.Type == RuleRegex {
+		s += " " + string(r.Scope)
+	}
+	return s + " " + r.Pattern + ")"
+}
+
+func (r Rule) action() RuleAction {
+	if r.Action == "" {
+		return ActionBlock
+	}
+	return r.Action
+}
+
+// compiledRule is a validated rule ready for matching
+type compiledRule struct {
+	Rule
+	pattern string // normalized host pattern
+	re      *regexp.Regexp
+	// legacy marks rules derived from BlockedDomains/BlockedPatterns,
+	// which keep their historical error messages
+	legacy bool
+}
+
+// Compile validates the config's rules and prepares them for matching.
+// NewURLValidator and SetConfig compile configs they are given; invalid
+// rules never match, so callers loading user supplied rules should call
+// Compile first and reject the config on error.
+func (c *Config) Compile() error {
+	var problems []error
+	compiled := make([]compiledRule, 0, len(c.Rules)+len(c.BlockedDomains)+len(c.BlockedPatterns))
+	seen := make(map[string]bool, len(c.Rules))
+
+	for i, rule := range c.Rules {
+		if rule.ID == "" {
+			problems = append(problems, fmt.Errorf("rules[%d]: id is required", i))
+			continue
+		}
+		if seen[rule.ID] {
+			problems = append(problems, fmt.Errorf("rule %s: duplicate id", rule.ID))
+			continue
+		}
+		seen[rule.ID] = true
+
+		cr, err := compileRule(rule)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("rule %s: %w", rule.ID, err))
+			continue
+		}
+		compiled = append(compiled, cr)
+	}
+
+	// Legacy lists: a blocked domain covers its subdomains but is no longer
+	// a substring match, and blocked patterns are literal, case-insensitive
+	// matches against the path and query only
+	for _, domain := range c.BlockedDomains {
+		compiled = append(compiled, compiledRule{
+			Rule:    Rule{ID: "blocked_domains:" + domain, Type: RuleSuffix, Pattern: domain, Action: ActionBlock},
+			pattern: normalizeHost(domain),
+			legacy:  true,
+		})
+	}
+	for _, pattern := range c.BlockedPatterns {
+		re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(pattern))
+		for _, scope := range []RuleScope{ScopePath, ScopeQuery} {
+			compiled = append(compiled, compiledRule{
+				Rule:   Rule{ID: "blocked_patterns:" + pattern, Type: RuleRegex, Pattern: pattern, Scope: scope, Action: ActionBlock},
+				re:     re,
+				legacy: true,
+			})
+		}
+	}
+
+	c.compiled = compiled
+	return errors.Join(problems...)
+}
+
+func compileRule(rule Rule) (compiledRule, error) {
+	cr := compiledRule{Rule: rule}
+
+	switch rule.action() {
+	case ActionBlock, ActionWarn, ActionAllow:
+	default:
+		return cr, fmt.Errorf("unknown action %q", rule.Action)
+	}
+	if rule.Pattern == "" {
+		return cr, fmt.Errorf("pattern is required")
+	}
+
+	switch rule.Type {
+	case RuleExact:
+		cr.pattern = normalizeHost(rule.Pattern)
+	case RuleSuffix:
+		cr.pattern = normalizeHost(rule.Pattern)
+		if strings.Contains(strings.TrimPrefix(cr.pattern, "*."), "*") {
+			return cr, fmt.Errorf("suffix pattern may only start with \"*.\"")
+		}
+	case RuleRegistrable:
+		cr.pattern = normalizeHost(rule.Pattern)
+		registrable, err := publicsuffix.EffectiveTLDPlusOne(cr.pattern)
+		if err != nil || registrable != cr.pattern {
+			return cr, fmt.Errorf("%q is not a registrable domain", rule.Pattern)
+		}
+	case RuleRegex:
+		switch rule.Scope {
+		case ScopeHost, ScopePath, ScopeQuery:
+		default:
+			return cr, fmt.Errorf("regex rules need a scope of host, path or query, got %q", rule.Scope)
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return cr, err
+		}
+		cr.re = re
+	default:
+		return cr, fmt.Errorf("unknown type %q", rule.Type)
+	}
+	return cr, nil
+}
+
+// urlParts holds the normalized URL components rules match against
+type urlParts struct {
+	host  string
+	path  string
+	query string
+}
+
+func newURLParts(u *url.URL) urlParts {
+	query, err := url.QueryUnescape(u.RawQuery)
+	if err != nil {
+		query = u.RawQuery
+	}
+	return urlParts{
+		host:  normalizeHost(u.Hostname()),
+		path:  u.Path,
+		query: query,
+	}
+}
+
+func (r compiledRule) matches(parts urlParts) bool {
+	switch r.Type {
+	case RuleExact:
+		return parts.host == r.pattern
+	case RuleSuffix:
+		if suffix, ok := strings.CutPrefix(r.pattern, "*"); ok {
+			return strings.HasSuffix(parts.host, suffix)
+		}
+		return parts.host == r.pattern || strings.HasSuffix(parts.host, "."+r.pattern)
+	case RuleRegistrable:
+		registrable, err := publicsuffix.EffectiveTLDPlusOne(parts.host)
+		return err == nil && registrable == r.pattern
+	case RuleRegex:
+		if r.re == nil {
+			return false
+		}
+		switch r.Scope {
+		case ScopeHost:
+			return r.re.MatchString(parts.host)
+		case ScopePath:
+			return r.re.MatchString(parts.path)
+		case ScopeQuery:
+			return r.re.MatchString(parts.query)
+		}
+	}
+	return false
+}
+
+// message is the error or warning reported when the rule matches
+func (r compiledRule) message() string {
+	if r.Message != "" {
+		return r.Message
+	}
+	if r.legacy {
+		if r.Type == RuleRegex {
+			return "URL contains suspicious pattern: " + r.Pattern
+		}
+		return "domain is blocked"
+	}
+	if r.Type == RuleRegex {
+		return "URL contains suspicious pattern: " + r.ID
+	}
+	return "domain is blocked by rule " + r.ID
+}
+
+// evaluateRules applies the compiled rules to a parsed URL. Any matching
+// allow rule exempts the URL; otherwise the first matching block rule is
+// returned as an error and every matching warn rule as a warning.
+func evaluateRules(rules []compiledRule, u *url.URL) ([]string, error) {
+	parts := newURLParts(u)
+
+	for _, rule := range rules {
+		if rule.action() == ActionAllow && rule.matches(parts) {
+			return nil, nil
+		}
+	}
+
+	var blocked error
+	var warnings []string
+	for _, rule := range rules {
+		switch rule.action() {
+		case ActionBlock:
+			if blocked == nil && rule.matches(parts) {
+				blocked = errors.New(rule.message())
+			}
+		case ActionWarn:
+			if rule.matches(parts) {
+				warnings = append(warnings, rule.message())
+			}
+		}
+	}
+	return warnings, blocked
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestRuleMatching(t *testing.T) {
+	config := &Config{
+		MaxURLLength:   2048,
+		BlockedDomains: []string{"example.com"},
+		Rules: []Rule{
+			{ID: "exact", Type: RuleExact, Pattern: "bad.test"},
+			{ID: "subdomains", Type: RuleSuffix, Pattern: "*.wild.test"},
+			{ID: "registrable", Type: RuleRegistrable, Pattern: "shady.co.uk"},
+			{ID: "admin-path", Type: RuleRegex, Scope: ScopePath, Pattern: `^/wp-admin(/|$)`},
+			{ID: "token-query", Type: RuleRegex, Scope: ScopeQuery, Pattern: `(?i)(^|&)token=`, Action: ActionWarn},
+			{ID: "php-host", Type: RuleRegex, Scope: ScopeHost, Pattern: `^php\.`},
+			{ID: "docs", Type: RuleExact, Pattern: "docs.example.com", Action: ActionAllow},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		valid   bool
+		errors  []string
+		warning string
+	}{
+		{name: "exact host", url: "https://bad.test/x", errors: []string{"domain is blocked by rule exact"}},
+		{name: "exact ignores subdomain", url: "https://www.bad.test/x", valid: true},
+		{name: "wildcard subdomain", url: "https://a.b.wild.test", errors: []string{"domain is blocked by rule subdomains"}},
+		{name: "wildcard skips apex", url: "https://wild.test", valid: true},
+		{name: "registrable domain", url: "https://login.shady.co.uk", errors: []string{"domain is blocked by rule registrable"}},
+		{name: "registrable other domain", url: "https://shady.co.uk.example.org", valid: true},
+		{name: "legacy domain subdomain", url: "https://www.example.com", errors: []string{"domain is blocked"}},
+		{name: "legacy domain is not a substring match", url: "https://notexample.com", valid: true},
+		{name: "path regex", url: "https://site.org/wp-admin/", errors: []string{"URL contains suspicious pattern: admin-path"}},
+		{name: "path regex is scoped", url: "https://site.org/?next=/wp-admin/", valid: true},
+		{name: "host regex", url: "https://php.site.org", errors: []string{"URL contains suspicious pattern: php-host"}},
+		{name: "warn rule", url: "https://site.org/?token=abc", valid: true, warning: "URL contains suspicious pattern: token-query"},
+		{name: "allow overrides block", url: "https://docs.example.com/guide", valid: true},
+	}
+
+	validator := NewURLValidator(config)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := validator.ValidateURL(context.Background(), tt.url)
+			if result.IsValid != tt.valid {
+				t.Errorf("ValidateURL(%q) valid = %v, want %v (errors %v)", tt.url, result.IsValid, tt.valid, result.Errors)
+			}
+			if tt.errors != nil && !slices.Equal(result.Errors, tt.errors) {
+				t.Errorf("ValidateURL(%q) errors = %v, want %v", tt.url, result.Errors, tt.errors)
+			}
+			if tt.warning != "" && !slices.Contains(result.Warnings, tt.warning) {
+				t.Errorf("ValidateURL(%q) warnings = %v, want %q", tt.url, result.Warnings, tt.warning)
+			}
+		})
+	}
+}
+
+func TestDefaultRulesAvoidFalsePositives(t *testing.T) {
+	validator := NewURLValidator(nil)
+	for _, url := range []string{
+		"https://xn--bcher-kva.de/",
+		"https://blog.org/2024/a--b-article",
+		"https://shop.org/index.php?id=5",
+		"https://news.org/?q=drop+shipping",
+		"https://files.org/data/export.csv",
+	} {
+		if result := validator.ValidateURL(context.Background(), url); !result.IsValid {
+			t.Errorf("ValidateURL(%q) unexpectedly invalid: %v", url, result.Errors)
+		}
+	}
+
+	for _, url := range []string{
+		"https://site.org/?redirect=javascript:alert(1)",
+		"https://site.org/a/../../etc/passwd",
+		"https://site.org/?q=1%20UNION%20SELECT%20password",
+		"https://site.org/?file=a%00.png",
+	} {
+		if result := validator.ValidateURL(context.Background(), url); result.IsValid {
+			t.Errorf("ValidateURL(%q) unexpectedly valid", url)
+		}
+	}
+}
+
+func TestCompileRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+	}{
+		{name: "missing id", rule: Rule{Type: RuleExact, Pattern: "a.test"}},
+		{name: "unknown type", rule: Rule{ID: "x", Type: "glob", Pattern: "a.test"}},
+		{name: "unknown action", rule: Rule{ID: "x", Type: RuleExact, Pattern: "a.test", Action: "drop"}},
+		{name: "bad regex", rule: Rule{ID: "x", Type: RuleRegex, Scope: ScopePath, Pattern: "(open"}},
+		{name: "regex without scope", rule: Rule{ID: "x", Type: RuleRegex, Pattern: "a"}},
+		{name: "public suffix", rule: Rule{ID: "x", Type: RuleRegistrable, Pattern: "co.uk"}},
+		{name: "subdomain as registrable", rule: Rule{ID: "x", Type: RuleRegistrable, Pattern: "a.b.com"}},
+		{name: "inner wildcard", rule: Rule{ID: "x", Type: RuleSuffix, Pattern: "a.*.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{MaxURLLength: 100, Rules: []Rule{tt.rule}}
+			if err := config.Compile(); err == nil {
+				t.Errorf("Compile() expected an error for %+v", tt.rule)
+			}
+		})
+	}
+
+	validator := NewURLValidator(nil)
+	before := validator.Config()
+	if err := validator.SetConfig(&Config{MaxURLLength: 100, Rules: []Rule{{ID: "x", Type: "glob"}}}); err == nil {
+		t.Error("SetConfig() expected an error for an invalid rule")
+	}
+	if validator.Config() != before {
+		t.Error("Expected SetConfig to keep the current config on error")
+	}
+}
@@ -6,11 +6,13 @@ import (
 	"net"
 	"net/url"
 	"strings"
+	"sync/atomic"
 )
 
-// URLValidator handles URL validation with configurable rules
+// URLValidator handles URL validation with configurable rules. The config
+// can be swapped at runtime; each validation uses a single snapshot.
 type URLValidator struct {
-	config *Config
+	config atomic.Pointer[Config]
 }
 
 type URLValidatorInterface interface {
@@ -19,36 +21,46 @@ type URLValidatorInterface interface {
 
 // Config holds validation configuration
 type Config struct {
-	MaxURLLength    int      `json:"maxUrlLength"`
-	AllowedDomains  []string `json:"allowedDomains"`
-	BlockedPatterns []string `json:"blockedPatterns"`
-	BlockedDomains  []string `json:"blockedDomains"`
+	MaxURLLength   int      `json:"maxUrlLength" yaml:"max_url_length"`
+	AllowedDomains []string `json:"allowedDomains" yaml:"allowed_domains"`
+	// BlockedPatterns are literal, case-insensitive matches against the
+	// path and query; prefer regex Rules for anything new
+	BlockedPatterns []string `json:"blockedPatterns" yaml:"blocked_patterns"`
+	// BlockedDomains block a domain and all of its subdomains
+	BlockedDomains []string `json:"blockedDomains" yaml:"blocked_domains"`
+	Rules          []Rule   `json:"rules" yaml:"rules"`
+
+	compiled []compiledRule
 }
 
 // ValidationResult contains the validation outcome and any errors
 type ValidationResult struct {
-	IsValid bool     `json:"isValid"`
-	Errors  []string `json:"errors,omitempty"`
+	IsValid  bool     `json:"isValid"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"` // matched warn rules, never fatal
 }
 
 // DefaultConfig provides sensible default settings
 func DefaultConfig() *Config {
 	return &Config{
 		MaxURLLength: 2048,
-		BlockedPatterns: []string{
-			"javascript:", "data:", "vbscript:",
-			"<script", "alert(", "prompt(",
-			"onload=", "onerror=",
-			"eval(", "exec(", "file:", "ftp:",
-			"confirm(", "../", "\\\\",
-			".php?", ".asp?", "eval(", "exec(",
-			"--", "DROP ", "UNION ", "%00", "0x00",
-		},
 		BlockedDomains: []string{
 			"example.com",
 			"test.com",
 			"localhost",
-			"dev4url.cc",
+		},
+		Rules: []Rule{
+			{ID: "self-domain", Type: RuleSuffix, Pattern: "dev4url.cc", Message: "using dev4url as domain"},
+			{ID: "script-tag", Type: RuleRegex, Scope: ScopeQuery, Pattern: `(?i)<\s*/?\s*script`},
+			{ID: "script-tag-path", Type: RuleRegex, Scope: ScopePath, Pattern: `(?i)<\s*/?\s*script`},
+			{ID: "script-uri-param", Type: RuleRegex, Scope: ScopeQuery, Pattern: `(?i)(^|[=&])\s*(javascript|vbscript|data):`},
+			{ID: "event-handler", Type: RuleRegex, Scope: ScopeQuery, Pattern: `(?i)\bon(load|error|click|mouseover|focus)\s*=`},
+			{ID: "js-call", Type: RuleRegex, Scope: ScopeQuery, Pattern: `(?i)\b(alert|prompt|confirm|eval)\s*\(`},
+			{ID: "sql-injection", Type: RuleRegex, Scope: ScopeQuery, Pattern: `(?i)\bunion\s+(all\s+)?select\b|\bdrop\s+table\b`},
+			{ID: "path-traversal", Type: RuleRegex, Scope: ScopePath, Pattern: `(^|/)\.\.(/|$)`},
+			{ID: "null-byte", Type: RuleRegex, Scope: ScopeQuery, Pattern: `\x00`},
+			{ID: "null-byte-path", Type: RuleRegex, Scope: ScopePath, Pattern: `\x00`},
+			{ID: "unc-path", Type: RuleRegex, Scope: ScopePath, Pattern: `\\\\`},
 		},
 	}
 }
@@ -58,9 +70,28 @@ func NewURLValidator(config *Config) *URLValidator {
 	if config == nil {
 		config = DefaultConfig()
 	}
-	return &URLValidator{
-		config: config,
+	config.Compile()
+	v := &URLValidator{}
+	v.config.Store(config)
+	return v
+}
+
+// Config returns the config currently in use
+func (v *URLValidator) Config() *Config {
+	return v.config.Load()
+}
+
+// SetConfig atomically replaces the config used by subsequent validations.
+// A config with invalid rules is rejected and the current one kept.
+func (v *URLValidator) SetConfig(config *Config) error {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if err := config.Compile(); err != nil {
+		return err
 	}
+	v.config.Store(config)
+	return nil
 }
 
 // ValidateURL performs comprehensive URL validation
@@ -69,19 +100,26 @@ func (v *URLValidator) ValidateURL(ctx context.Context, urlStr string) *Validati
 		IsValid: true,
 		Errors:  make([]string, 0),
 	}
+	config := v.config.Load()
 
 	// Perform all validations
-	if err := v.validateBasics(urlStr); err != nil {
+	if err := validateBasics(config, urlStr); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	if err := validateSecurity(urlStr); err != nil {
 		result.Errors = append(result.Errors, err.Error())
 	}
 
-	if err := v.validateSecurity(urlStr); err != nil {
+	if err := validateDomain(config, urlStr); err != nil {
 		result.Errors = append(result.Errors, err.Error())
 	}
 
-	if err := v.validateDomain(urlStr); err != nil {
+	warnings, err := validateRules(config, urlStr)
+	if err != nil {
 		result.Errors = append(result.Errors, err.Error())
 	}
+	result.Warnings = warnings
 
 	// Set final validity
 	result.IsValid = len(result.Errors) == 0
@@ -90,13 +128,13 @@ func (v *URLValidator) ValidateURL(ctx context.Context, urlStr string) *Validati
 }
 
 // validateBasics checks fundamental URL properties
-func (v *URLValidator) validateBasics(urlStr string) error {
+func validateBasics(config *Config, urlStr string) error {
 	if strings.TrimSpace(urlStr) == "" {
 		return fmt.Errorf("URL cannot be empty")
 	}
 
-	if len(urlStr) > v.config.MaxURLLength {
-		return fmt.Errorf("URL exceeds maximum length of %d characters", v.config.MaxURLLength)
+	if len(urlStr) > config.MaxURLLength {
+		return fmt.Errorf("URL exceeds maximum length of %d characters", config.MaxURLLength)
 	}
 
 	parsedURL, err := url.Parse(urlStr)
@@ -116,16 +154,7 @@ func (v *URLValidator) validateBasics(urlStr string) error {
 }
 
 // validateSecurity performs security-related checks
-func (v *URLValidator) validateSecurity(urlStr string) error {
-	urlLower := strings.ToLower(urlStr)
-
-	// Check for suspicious patterns
-	for _, pattern := range v.config.BlockedPatterns {
-		if strings.Contains(urlLower, strings.ToLower(pattern)) {
-			return fmt.Errorf("URL contains suspicious pattern: %s", pattern)
-		}
-	}
-
+func validateSecurity(urlStr string) error {
 	// Check for control characters
 	for _, r := range urlStr {
 		if r < 32 || r == 127 {
@@ -137,8 +166,11 @@ func (v *URLValidator) validateSecurity(urlStr string) error {
 }
 
 // validateDomain performs domain-specific validation
-func (v *URLValidator) validateDomain(urlStr string) error {
-	parsedURL, _ := url.Parse(urlStr)
+func validateDomain(config *Config, urlStr string) error {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return nil
+	}
 	hostname := parsedURL.Hostname()
 
 	// Block localhost in all forms
@@ -153,21 +185,10 @@ func (v *URLValidator) validateDomain(urlStr string) error {
 		return nil
 	}
 
-	// Check blocked domains
-	for _, blockedDomain := range v.config.BlockedDomains {
-		hostName := parsedURL.Hostname()
-		if strings.Contains(hostName, blockedDomain) {
-			if hostName == "dev4url.cc" {
-				return fmt.Errorf("using dev4url as domain")
-			}
-			return fmt.Errorf("domain is blocked")
-		}
-	}
-
 	// Check allowed domains if configured
-	if len(v.config.AllowedDomains) > 0 {
+	if len(config.AllowedDomains) > 0 {
 		allowed := false
-		for _, allowedDomain := range v.config.AllowedDomains {
+		for _, allowedDomain := range config.AllowedDomains {
 			if parsedURL.Hostname() == allowedDomain || strings.HasSuffix(parsedURL.Hostname(), "."+allowedDomain) {
 				allowed = true
 				break
@@ -180,3 +201,16 @@ func (v *URLValidator) validateDomain(urlStr string) error {
 
 	return nil
 }
+
+// validateRules matches the compiled rules against the host, path and query
+func validateRules(config *Config, urlStr string) ([]string, error) {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil || parsedURL.Host == "" {
+		return nil, nil
+	}
+	// IP hosts were already judged by validateDomain
+	if net.ParseIP(parsedURL.Hostname()) != nil {
+		return nil, nil
+	}
+	return evaluateRules(config.compiled, parsedURL)
+}
@@ -46,7 +46,6 @@ func TestURLValidator(t *testing.T) {
 			expected: false,
 			errors: []string{
 				"URL scheme must be http or https",
-				"URL contains suspicious pattern: ftp:",
 				"domain is blocked",
 			},
 		},
@@ -62,15 +61,15 @@ func TestURLValidator(t *testing.T) {
 			config:   DefaultConfig(),
 			url:      "https://domain.com/path?script=<script>alert('xss')</script>",
 			expected: false,
-			errors:   []string{"URL contains suspicious pattern: <script"},
+			errors:   []string{"URL contains suspicious pattern: script-tag"},
 		},
 		{
 			name: "Allowed Domains Test",
 			config: &Config{
-				MaxURLLength:    2048,
-				AllowedDomains:  []string{"trusted.com"},
-				BlockedPatterns: DefaultConfig().BlockedPatterns,
-				BlockedDomains:  DefaultConfig().BlockedDomains,
+				MaxURLLength:   2048,
+				AllowedDomains: []string{"trusted.com"},
+				BlockedDomains: DefaultConfig().BlockedDomains,
+				Rules:          DefaultConfig().Rules,
 			},
 			url:      "https://untrusted.com",
 			expected: false,
@@ -125,8 +124,12 @@ func TestDefaultConfig(t *testing.T) {
 		t.Errorf("DefaultConfig() MaxURLLength = %d, want %d", config.MaxURLLength, 2048)
 	}
 
-	if len(config.BlockedPatterns) == 0 {
-		t.Error("DefaultConfig() BlockedPatterns is empty")
+	if len(config.Rules) == 0 {
+		t.Error("DefaultConfig() Rules is empty")
+	}
+
+	if err := config.Compile(); err != nil {
+		t.Errorf("DefaultConfig() rules do not compile: %v", err)
 	}
 
 	if len(config.BlockedDomains) == 0 {
@@ -141,14 +144,14 @@ func TestNewURLValidator(t *testing.T) {
 			BlockedDomains: []string{"blocked.com"},
 		}
 		validator := NewURLValidator(config)
-		if validator.config != config {
+		if validator.config.Load() != config {
 			t.Error("NewURLValidator() did not set custom config correctly")
 		}
 	})
 
 	t.Run("With Nil Config", func(t *testing.T) {
 		validator := NewURLValidator(nil)
-		if validator.config == nil {
+		if validator.config.Load() == nil {
 			t.Error("NewURLValidator() did not set default config when nil was provided")
 		}
 	})
@@ -0,0 +1,212 @@
+// Package codec converts unsigned integers to and from short strings over
+// an arbitrary alphabet, such as the base58 alphabet dev4url codes use.
+//
+// Decoding uses a 256 entry lookup table, encoding writes into a stack
+// buffer, so each call allocates at most the returned string.
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	ErrInvalidAlphabet = errors.New("invalid alphabet")
+	ErrInvalidChar     = errors.New("character not in alphabet")
+	ErrEmpty           = errors.New("empty input")
+	// ErrOverflow is returned when a value needs more characters than
+	// allowed, or a string decodes past math.MaxUint64
+	ErrOverflow = errors.New("value does not fit")
+)
+
+// Alphabet characters
+const (
+	// Base58Chars leaves out 0, O, I and l, which are easily confused
+	Base58Chars = "123456789abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
+	Base62Chars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	// Crockford32Chars is Crockford's base32, without I, L, O and U
+	Crockford32Chars = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+// Predefined alphabets
+var (
+	Base58 = mustAlphabet(NewAlphabet(Base58Chars))
+	Base62 = mustAlphabet(NewAlphabet(Base62Chars))
+	// Crockford32 decodes case-insensitively and reads I and L as 1 and
+	// O as 0, as the spec asks
+	Crockford32 = mustAlphabet(newAlphabet(Crockford32Chars, map[byte]byte{
+		'I': '1', 'L': '1', 'O': '0',
+	}, true))
+)
+
+// maxWidth is the longest encoding of a uint64, reached in base 2
+const maxWidth = 64
+
+// invalid marks bytes outside the alphabet in the lookup table
+const invalid = 0xFF
+
+// Alphabet encodes and decodes numbers in base len(chars). It is
+// immutable and safe for concurrent use.
+type Alphabet struct {
+	chars string
+	base  uint64
+	index [256]byte
+	// maxWidth is the number of characters math.MaxUint64 needs
+	maxWidth int
+}
+
+// NewAlphabet builds an alphabet from 2 to 255 distinct ASCII characters;
+// a character's position is its digit value
+func NewAlphabet(chars string) (*Alphabet, error) {
+	return newAlphabet(chars, nil, false)
+}
+
+func newAlphabet(chars string, aliases map[byte]byte, foldCase bool) (*Alphabet, error) {
+	if len(chars) < 2 || len(chars) >= invalid {
+		return nil, fmt.Errorf("%w: needs 2 to %d characters, got %d", ErrInvalidAlphabet, invalid-1, len(chars))
+	}
+
+	a := &Alphabet{chars: chars, base: uint64(len(chars))}
+	for i := range a.index {
+		a.index[i] = invalid
+	}
+	for i := 0; i < len(chars); i++ {
+		c := chars[i]
+		if c >= 0x80 {
+			return nil, fmt.Errorf("%w: %q is not ASCII", ErrInvalidAlphabet, c)
+		}
+		if a.index[c] != invalid {
+			return nil, fmt.Errorf("%w: %q appears twice", ErrInvalidAlphabet, c)
+		}
+		a.index[c] = byte(i)
+	}
+
+	for alias, c := range aliases {
+		a.index[alias] = a.index[c]
+	}
+	if foldCase {
+		for c := 'A'; c <= 'Z'; c++ {
+			if upper := a.index[c]; upper != invalid {
+				a.index[c+'a'-'A'] = upper
+			}
+		}
+	}
+
+	a.maxWidth = a.Width(math.MaxUint64)
+	return a, nil
+}
+
+func mustAlphabet(a *Alphabet, err error) *Alphabet {
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// String returns the alphabet's characters
+func (a *Alphabet) String() string {
+	return a.chars
+}
+
+// Base is the number of characters
+func (a *Alphabet) Base() int {
+	return int(a.base)
+}
+
+// Index returns the digit value of c, or -1 if c is not in the alphabet
+func (a *Alphabet) Index(c byte) int {
+	if d := a.index[c]; d != invalid {
+		return int(d)
+	}
+	return -1
+}
+
+// Width is the number of characters Encode uses for n
+func (a *Alphabet) Width(n uint64) int {
+	width := 1
+	for n >= a.base {
+		n /= a.base
+		width++
+	}
+	return width
+}
+
+// Encode returns n in the fewest characters
+func (a *Alphabet) Encode(n uint64) string {
+	var buf [maxWidth]byte
+	i := len(buf)
+	for {
+		i--
+		buf[i] = a.chars[n%a.base]
+		n /= a.base
+		if n == 0 {
+			break
+		}
+	}
+	return string(buf[i:])
+}
+
+// EncodePadded returns n in exactly length characters, left padded with
+// the zero digit. A value needing more characters fails with ErrOverflow
+// instead of being truncated.
+func (a *Alphabet) EncodePadded(n uint64, length int) (string, error) {
+	if length <= 0 {
+		return "", fmt.Errorf("%w: length must be positive", ErrOverflow)
+	}
+	if a.Width(n) > length {
+		return "", fmt.Errorf("%w: %d needs %d characters, length is %d", ErrOverflow, n, a.Width(n), length)
+	}
+	if length > a.maxWidth {
+		// Only padding is left beyond the widest uint64
+		return strings.Repeat(a.chars[:1], length-a.maxWidth) + a.encodeWidth(n, a.maxWidth), nil
+	}
+	return a.encodeWidth(n, length), nil
+}
+
+// encodeWidth encodes n, which fits, in width characters
+func (a *Alphabet) encodeWidth(n uint64, width int) string {
+	var buf [maxWidth]byte
+	for i := width - 1; i >= 0; i-- {
+		buf[i] = a.chars[n%a.base]
+		n /= a.base
+	}
+	return string(buf[:width])
+}
+
+// Decode parses s, failing with ErrInvalidChar on characters outside the
+// alphabet and ErrOverflow past math.MaxUint64
+func (a *Alphabet) Decode(s string) (uint64, error) {
+	if s == "" {
+		return 0, ErrEmpty
+	}
+
+	var n uint64
+	limit := math.MaxUint64 / a.base
+	for i := 0; i < len(s); i++ {
+		d := a.index[s[i]]
+		if d == invalid {
+			return 0, fmt.Errorf("%w: %q at offset %d", ErrInvalidChar, s[i], i)
+		}
+		if n > limit {
+			return 0, fmt.Errorf("%w: %q exceeds 64 bits", ErrOverflow, s)
+		}
+		n *= a.base
+		if n > math.MaxUint64-uint64(d) {
+			return 0, fmt.Errorf("%w: %q exceeds 64 bits", ErrOverflow, s)
+		}
+		n += uint64(d)
+	}
+	return n, nil
+}
+
+// Valid reports whether every character of s is in the alphabet
+func (a *Alphabet) Valid(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if a.index[s[i]] == invalid {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,183 @@
+package codec
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+var alphabets = map[string]*Alphabet{
+	"base58":      Base58,
+	"base62":      Base62,
+	"crockford32": Crockford32,
+}
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		alphabet *Alphabet
+		n        uint64
+		want     string
+	}{
+		{Base58, 0, "1"},
+		{Base58, 57, "Z"},
+		{Base58, 58, "21"},
+		{Base62, 61, "Z"},
+		{Base62, 62, "10"},
+		{Crockford32, 31, "Z"},
+		{Crockford32, 32, "10"},
+		{Base58, math.MaxUint64, "JPwcyDCgEup"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.alphabet.Encode(tt.n); got != tt.want {
+			t.Errorf("Encode(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+		if got, err := tt.alphabet.Decode(tt.want); err != nil || got != tt.n {
+			t.Errorf("Decode(%q) = %d, %v, want %d", tt.want, got, err, tt.n)
+		}
+	}
+}
+
+func TestEncodePadded(t *testing.T) {
+	code, err := Base58.EncodePadded(0, 7)
+	if err != nil || code != "1111111" {
+		t.Errorf("EncodePadded(0, 7) = %q, %v, want 1111111", code, err)
+	}
+
+	max := uint64(1)
+	for i := 0; i < 7; i++ {
+		max *= 58
+	}
+	if _, err := Base58.EncodePadded(max-1, 7); err != nil {
+		t.Errorf("EncodePadded(58^7-1, 7) unexpected error: %v", err)
+	}
+	if _, err := Base58.EncodePadded(max, 7); !errors.Is(err, ErrOverflow) {
+		t.Errorf("EncodePadded(58^7, 7) error = %v, want ErrOverflow", err)
+	}
+
+	code, err = Base62.EncodePadded(math.MaxUint64, 20)
+	if err != nil || len(code) != 20 || !strings.HasPrefix(code, "000000000") {
+		t.Errorf("EncodePadded(MaxUint64, 20) = %q, %v, want zero padded to 20", code, err)
+	}
+	if n, err := Base62.Decode(code); err != nil || n != math.MaxUint64 {
+		t.Errorf("Decode(%q) = %d, %v, want MaxUint64", code, n, err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, err := Base58.Decode(""); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Decode(\"\") error = %v, want ErrEmpty", err)
+	}
+	if _, err := Base58.Decode("abc0"); !errors.Is(err, ErrInvalidChar) {
+		t.Errorf("Decode(\"abc0\") error = %v, want ErrInvalidChar", err)
+	}
+	// One past math.MaxUint64
+	if _, err := Base58.Decode("JPwcyDCgEuq"); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Decode(MaxUint64+1) error = %v, want ErrOverflow", err)
+	}
+	if _, err := Base58.Decode("ZZZZZZZZZZZZ"); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Decode of 12 characters error = %v, want ErrOverflow", err)
+	}
+}
+
+func TestCrockfordAliases(t *testing.T) {
+	want, _ := Crockford32.Decode("1A0")
+	for _, s := range []string{"1a0", "IA0", "la0", "1AO", "ia0"} {
+		if got, err := Crockford32.Decode(s); err != nil || got != want {
+			t.Errorf("Decode(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+	if _, err := Crockford32.Decode("U"); !errors.Is(err, ErrInvalidChar) {
+		t.Errorf("Decode(\"U\") error = %v, want ErrInvalidChar", err)
+	}
+}
+
+func TestNewAlphabet(t *testing.T) {
+	if _, err := NewAlphabet("a"); !errors.Is(err, ErrInvalidAlphabet) {
+		t.Errorf("Expected a single character alphabet to be rejected, got %v", err)
+	}
+	if _, err := NewAlphabet("abca"); !errors.Is(err, ErrInvalidAlphabet) {
+		t.Errorf("Expected duplicate characters to be rejected, got %v", err)
+	}
+	if _, err := NewAlphabet("abé"); !errors.Is(err, ErrInvalidAlphabet) {
+		t.Errorf("Expected non-ASCII characters to be rejected, got %v", err)
+	}
+
+	binary, err := NewAlphabet("01")
+	if err != nil {
+		t.Fatalf("NewAlphabet(\"01\") unexpected error: %v", err)
+	}
+	if got := binary.Encode(5); got != "101" {
+		t.Errorf("Encode(5) = %q, want 101", got)
+	}
+	if got := binary.Encode(math.MaxUint64); len(got) != 64 {
+		t.Errorf("Encode(MaxUint64) has %d binary digits, want 64", len(got))
+	}
+}
+
+func TestAllocations(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		Base58.Encode(math.MaxUint64)
+	})
+	if allocs > 1 {
+		t.Errorf("Encode allocates %v times, want at most 1", allocs)
+	}
+	allocs = testing.AllocsPerRun(100, func() {
+		Base58.Decode("JPwcyDCgEup")
+	})
+	if allocs != 0 {
+		t.Errorf("Decode allocates %v times, want 0", allocs)
+	}
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add(uint64(0), 0)
+	f.Add(uint64(57), 7)
+	f.Add(uint64(math.MaxUint64), 11)
+
+	f.Fuzz(func(t *testing.T, n uint64, length int) {
+		for name, a := range alphabets {
+			code := a.Encode(n)
+			if got, err := a.Decode(code); err != nil || got != n {
+				t.Fatalf("%s: Decode(Encode(%d)) = %d, %v", name, n, got, err)
+			}
+
+			length = int(uint(length)%80) + 1
+			padded, err := a.EncodePadded(n, length)
+			if len(code) > length {
+				if !errors.Is(err, ErrOverflow) {
+					t.Fatalf("%s: EncodePadded(%d, %d) error = %v, want ErrOverflow", name, n, length, err)
+				}
+				continue
+			}
+			if err != nil || len(padded) != length {
+				t.Fatalf("%s: EncodePadded(%d, %d) = %q, %v", name, n, length, padded, err)
+			}
+			if got, err := a.Decode(padded); err != nil || got != n {
+				t.Fatalf("%s: Decode(EncodePadded(%d)) = %d, %v", name, n, got, err)
+			}
+		}
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add("1")
+	f.Add("JPwcyDCgEup")
+	f.Add("JPwcyDCgEuq")
+	f.Add("00000000000000000000000000001")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		for name, a := range alphabets {
+			n, err := a.Decode(s)
+			if err != nil {
+				continue
+			}
+			// Re-encoding yields the canonical form, which must decode the
+			// same; leading zero digits and Crockford aliases are dropped
+			if got, err := a.Decode(a.Encode(n)); err != nil || got != n {
+				t.Fatalf("%s: Decode(%q) = %d does not round-trip: %d, %v", name, s, n, got, err)
+			}
+		}
+	})
+}
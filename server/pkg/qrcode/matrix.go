@@ -0,0 +1,294 @@
+package qrcode
+
+// newCode returns a symbol with its function patterns drawn and the areas
+// for format and version information reserved
+func newCode(version int, level Level) *Code {
+	size := 4*version + 17
+	c := &Code{
+		Version: version,
+		Level:   level,
+		size:    size,
+		modules: make([]bool, size*size),
+	}
+	c.function = make([]bool, size*size)
+
+	// Timing patterns
+	for i := 0; i < size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns with their separators
+	for _, center := range [][2]int{{3, 3}, {size - 4, 3}, {3, size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := center[0]+dx, center[1]+dy
+				if x >= 0 && y >= 0 && x < size && y < size {
+					dist := max(abs(dx), abs(dy))
+					c.setFunction(x, y, dist != 2 && dist != 4)
+				}
+			}
+		}
+	}
+
+	// Alignment patterns, except where they would overlap a finder
+	positions := alignmentPositions(version)
+	last := len(positions) - 1
+	for i, cy := range positions {
+		for j, cx := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	c.drawFormat(0)
+	c.drawVersion()
+	return c
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y*c.size+x] = dark
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.set(x, y, dark)
+	c.function[y*c.size+x] = true
+}
+
+func (c *Code) isFunction(x, y int) bool {
+	return c.function[y*c.size+x]
+}
+
+// formatWord is the 15 bit format information: the level and mask with
+// their BCH error correction
+func formatWord(level Level, mask int) int {
+	data := formatBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// versionWord is the 18 bit version information of version 7 and up
+func versionWord(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	return version<<12 | rem
+}
+
+// drawFormat writes the format information next to the finder patterns
+func (c *Code) drawFormat(mask int) {
+	bits := formatWord(c.Level, mask)
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	// Around the top left finder
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+
+	// Split between the other two finders
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.size-15+i, bit(i))
+	}
+	c.setFunction(8, c.size-8, true) // always dark
+}
+
+// drawVersion writes the version information next to the top right and
+// bottom left finders
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	bits := versionWord(c.Version)
+	for i := 0; i < 18; i++ {
+		dark := bits>>i&1 == 1
+		a, b := c.size-11+i%3, i/3
+		c.setFunction(a, b, dark)
+		c.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords places the codewords in two module wide columns, zigzagging
+// up and down from the bottom right corner
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // skip the vertical timing pattern
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.size; vert++ {
+			y := vert
+			if upward {
+				y = c.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.isFunction(x, y) || i >= len(codewords)*8 {
+					continue
+				}
+				c.set(x, y, codewords[i>>3]>>(7-i&7)&1 == 1)
+				i++
+			}
+		}
+	}
+}
+
+// masks flip data modules where they return true
+var masks = [8]func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+// applyMask flips the data modules selected by mask; applying it twice
+// undoes it
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if !c.isFunction(x, y) && masks[mask](x, y) {
+				c.modules[y*c.size+x] = !c.modules[y*c.size+x]
+			}
+		}
+	}
+}
+
+// applyBestMask tries every mask and keeps the one with the lowest penalty
+func (c *Code) applyBestMask() *Code {
+	best, bestPenalty := 0, -1
+	for mask := range masks {
+		c.applyMask(mask)
+		c.drawFormat(mask)
+		if penalty := c.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask)
+	}
+
+	c.applyMask(best)
+	c.drawFormat(best)
+	c.Mask = best
+	c.function = nil
+	return c
+}
+
+// Penalty weights from the spec
+const (
+	penaltyRun     = 3
+	penaltyBlock   = 3
+	penaltyFinder  = 40
+	penaltyBalance = 10
+)
+
+// finderLike are the 1:1:3:1:1 patterns with four light modules on one
+// side that scanners could mistake for a finder
+var finderLike = [2][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// penalty scores how hard the symbol is to scan, lower is better
+func (c *Code) penalty() int {
+	n := c.size
+	score := 0
+
+	// Runs of five or more modules of one color, in rows and columns
+	line := make([]bool, n)
+	for _, rows := range []bool{true, false} {
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if rows {
+					line[j] = c.Dark(j, i)
+				} else {
+					line[j] = c.Dark(i, j)
+				}
+			}
+			score += runPenalty(line) + finderPenalty(line)
+		}
+	}
+
+	// 2x2 blocks of one color
+	for y := 0; y < n-1; y++ {
+		for x := 0; x < n-1; x++ {
+			dark := c.Dark(x, y)
+			if dark == c.Dark(x+1, y) && dark == c.Dark(x, y+1) && dark == c.Dark(x+1, y+1) {
+				score += penaltyBlock
+			}
+		}
+	}
+
+	// Balance of dark and light modules, per 5% away from half
+	dark := 0
+	for _, module := range c.modules {
+		if module {
+			dark++
+		}
+	}
+	total := n * n
+	score += ((abs(dark*20-total*10)+total-1)/total - 1) * penaltyBalance
+	return score
+}
+
+func runPenalty(line []bool) int {
+	score, run := 0, 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			score += penaltyRun + run - 5
+		}
+		run = 1
+	}
+	return score
+}
+
+func finderPenalty(line []bool) int {
+	score := 0
+	for i := 0; i+11 <= len(line); i++ {
+		for _, pattern := range finderLike {
+			match := true
+			for j, dark := range pattern {
+				if line[i+j] != dark {
+					match = false
+					break
+				}
+			}
+			if match {
+				score += penaltyFinder
+			}
+		}
+	}
+	return score
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
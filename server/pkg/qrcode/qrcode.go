@@ -0,0 +1,190 @@
+// Package qrcode encodes data as QR Code symbols (ISO/IEC 18004) and
+// renders them as PNG or SVG images.
+//
+// Data is always encoded in byte mode, which suits URLs; the smallest
+// version from 1 to 40 that holds it at the requested error correction
+// level is chosen, and the mask with the lowest penalty score is applied.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	ErrTooLong      = errors.New("data does not fit in a QR code")
+	ErrInvalidLevel = errors.New("invalid error correction level")
+)
+
+// Level is the error correction level, the share of the symbol that can be
+// damaged or covered while it still scans
+type Level int
+
+const (
+	Low      Level = iota // about 7%
+	Medium                // about 15%
+	Quartile              // about 25%
+	High                  // about 30%
+)
+
+// formatBits are the level's two bits in the format information
+var formatBits = [4]int{Low: 1, Medium: 0, Quartile: 3, High: 2}
+
+// ParseLevel reads a level from its letter, L, M, Q or H
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return Low, nil
+	case "M":
+		return Medium, nil
+	case "Q":
+		return Quartile, nil
+	case "H":
+		return High, nil
+	}
+	return 0, fmt.Errorf("%w: %q, want L, M, Q or H", ErrInvalidLevel, s)
+}
+
+// String returns the level's letter
+func (l Level) String() string {
+	return string("LMQH"[l])
+}
+
+const (
+	minVersion = 1
+	maxVersion = 40
+	modeByte   = 0x4
+)
+
+// Code is an encoded QR code symbol. It is immutable.
+type Code struct {
+	Version int
+	Level   Level
+	Mask    int
+	size    int
+	modules []bool // dark modules, row by row
+	// function marks finder, timing, alignment and format modules while
+	// the symbol is built
+	function []bool
+}
+
+// Encode builds the smallest QR code holding data at level
+func Encode(data []byte, level Level) (*Code, error) {
+	if level < Low || level > High {
+		return nil, ErrInvalidLevel
+	}
+
+	version := minVersion
+	for ; ; version++ {
+		if version > maxVersion {
+			return nil, fmt.Errorf("%w: %d bytes at level %s", ErrTooLong, len(data), level)
+		}
+		if dataBits(len(data), version) <= dataCodewords(version, level)*8 {
+			break
+		}
+	}
+
+	c := newCode(version, level)
+	c.drawCodewords(c.addECC(c.encodeData(data)))
+	return c.applyBestMask(), nil
+}
+
+// Size is the number of modules on each side, without a quiet zone
+func (c *Code) Size() int {
+	return c.size
+}
+
+// Dark reports whether the module in column x and row y is dark; modules
+// outside the symbol are light
+func (c *Code) Dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < c.size && y < c.size && c.modules[y*c.size+x]
+}
+
+// countBits is the width of the byte mode character count
+func countBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// dataBits is the length of a byte mode segment of n bytes
+func dataBits(n, version int) int {
+	if n >= 1<<countBits(version) {
+		return math.MaxInt
+	}
+	return 4 + countBits(version) + 8*n
+}
+
+// bitBuffer appends bits most significant first
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		*b = append(*b, value>>i&1 == 1)
+	}
+}
+
+// encodeData builds the data codewords: the segment, a terminator and
+// padding up to the version's capacity
+func (c *Code) encodeData(data []byte) []byte {
+	capacity := dataCodewords(c.Version, c.Level) * 8
+
+	var bits bitBuffer
+	bits.append(modeByte, 4)
+	bits.append(len(data), countBits(c.Version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return codewords
+}
+
+// addECC splits data into blocks, appends error correction to each and
+// interleaves the result
+func (c *Code) addECC(data []byte) []byte {
+	blocks := eccBlocks[c.Level][c.Version]
+	eccLen := eccPerBlock[c.Level][c.Version]
+	raw := rawCodewords(c.Version)
+	shortBlocks := blocks - raw%blocks
+	shortLen := raw / blocks
+
+	divisor := rsDivisor(eccLen)
+	all := make([][]byte, blocks)
+	for i, k := 0, 0; i < blocks; i++ {
+		n := shortLen - eccLen
+		if i >= shortBlocks {
+			n++
+		}
+		block := append([]byte(nil), data[k:k+n]...)
+		k += n
+		ecc := rsRemainder(block, divisor)
+		if i < shortBlocks {
+			block = append(block, 0) // placeholder, skipped below
+		}
+		all[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, raw)
+	for i := range all[0] {
+		for j, block := range all {
+			if i != shortLen-eccLen || j >= shortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,294 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" at 1-M, from the worked example in the spec annex
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder() = %v, want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionWords(t *testing.T) {
+	formats := map[Level]int{
+		Low:      0b111011111000100,
+		Medium:   0b101010000010010,
+		Quartile: 0b011010101011111,
+		High:     0b001011010001001,
+	}
+	for level, want := range formats {
+		if got := formatWord(level, 0); got != want {
+			t.Errorf("formatWord(%s, 0) = %015b, want %015b", level, got, want)
+		}
+	}
+
+	versions := map[int]int{
+		7:  0b000111110010010100,
+		21: 0b010101011010000011,
+		40: 0b101000110001101001,
+	}
+	for version, want := range versions {
+		if got := versionWord(version); got != want {
+			t.Errorf("versionWord(%d) = %018b, want %018b", version, got, want)
+		}
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	// Byte mode capacities from the spec's table 7
+	tests := []struct {
+		version int
+		level   Level
+		bytes   int
+	}{
+		{1, Low, 17},
+		{1, High, 7},
+		{2, Medium, 26},
+		{10, Medium, 213},
+		{40, Low, 2953},
+		{40, High, 1273},
+	}
+	for _, tt := range tests {
+		capacity := (dataCodewords(tt.version, tt.level)*8 - 4 - countBits(tt.version)) / 8
+		if capacity != tt.bytes {
+			t.Errorf("Capacity of %d-%s = %d bytes, want %d", tt.version, tt.level, capacity, tt.bytes)
+		}
+	}
+}
+
+func TestAlignmentPositions(t *testing.T) {
+	for version, want := range map[int]string{
+		2:  "[6 18]",
+		7:  "[6 22 38]",
+		32: "[6 34 60 86 112 138]",
+		40: "[6 30 58 86 114 142 170]",
+	} {
+		if got := fmt.Sprint(alignmentPositions(version)); got != want {
+			t.Errorf("alignmentPositions(%d) = %s, want %s", version, got, want)
+		}
+	}
+}
+
+// decode reads a code back without error correction, failing on any
+// codeword that does not match its block's error correction
+func decode(t *testing.T, c *Code) []byte {
+	t.Helper()
+
+	// Format information, from the copy around the top left finder
+	reference := newCode(c.Version, c.Level)
+	reference.drawFormat(c.Mask)
+	for _, pos := range [][2]int{{8, 0}, {8, 5}, {8, 7}, {8, 8}, {7, 8}, {0, 8}, {8, c.size - 1}, {c.size - 1, 8}} {
+		if c.Dark(pos[0], pos[1]) != reference.Dark(pos[0], pos[1]) {
+			t.Fatalf("Format module %v does not match level %s mask %d", pos, c.Level, c.Mask)
+		}
+	}
+
+	// Unmask and read the codewords in placement order
+	raw := rawCodewords(c.Version)
+	codewords := make([]byte, raw)
+	i := 0
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.size; vert++ {
+			y := vert
+			if upward {
+				y = c.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if reference.isFunction(x, y) || i >= raw*8 {
+					continue
+				}
+				if c.Dark(x, y) != masks[c.Mask](x, y) {
+					codewords[i/8] |= 1 << (7 - i%8)
+				}
+				i++
+			}
+		}
+	}
+
+	// Undo the interleaving and check each block
+	blocks := eccBlocks[c.Level][c.Version]
+	eccLen := eccPerBlock[c.Level][c.Version]
+	shortBlocks := blocks - raw%blocks
+	shortLen := raw / blocks
+	split := make([][]byte, blocks)
+	k := 0
+	for pos := 0; pos < shortLen+1; pos++ {
+		for b := range split {
+			if pos == shortLen-eccLen && b < shortBlocks {
+				continue
+			}
+			split[b] = append(split[b], codewords[k])
+			k++
+		}
+	}
+	var data []byte
+	for b, block := range split {
+		n := len(block) - eccLen
+		if ecc := rsRemainder(block[:n], rsDivisor(eccLen)); !bytes.Equal(ecc, block[n:]) {
+			t.Fatalf("Block %d error correction does not match", b)
+		}
+		data = append(data, block[:n]...)
+	}
+
+	// Byte mode segment
+	var bits bitBuffer
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	read := func(n int) int {
+		v := 0
+		for _, bit := range bits[:n] {
+			v <<= 1
+			if bit {
+				v |= 1
+			}
+		}
+		bits = bits[n:]
+		return v
+	}
+	if mode := read(4); mode != modeByte {
+		t.Fatalf("Mode = %d, want byte mode", mode)
+	}
+	out := make([]byte, read(countBits(c.Version)))
+	for i := range out {
+		out[i] = byte(read(8))
+	}
+	return out
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		data    string
+		level   Level
+		version int
+	}{
+		{"https://dev4url.cc/abc1234", Medium, 2},
+		{"https://dev4url.cc/abc1234", High, 4},
+		{strings.Repeat("https://example.org/", 10), Quartile, 12},
+		{strings.Repeat("x", 1000), Low, 22},
+		{strings.Repeat("y", 1273), High, 40},
+	}
+	for _, tt := range tests {
+		code, err := Encode([]byte(tt.data), tt.level)
+		if err != nil {
+			t.Fatalf("Encode(%d bytes, %s) unexpected error: %v", len(tt.data), tt.level, err)
+		}
+		if code.Version != tt.version {
+			t.Errorf("Encode(%d bytes, %s) chose version %d, want %d", len(tt.data), tt.level, code.Version, tt.version)
+		}
+		if code.Size() != 4*tt.version+17 {
+			t.Errorf("Size() = %d for version %d", code.Size(), code.Version)
+		}
+		if got := decode(t, code); string(got) != tt.data {
+			t.Errorf("Decoded %q, want %q", got, tt.data)
+		}
+	}
+
+	if _, err := Encode(make([]byte, 1274), High); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Encode() past version 40 error = %v, want ErrTooLong", err)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if level, err := ParseLevel("q"); err != nil || level != Quartile {
+		t.Errorf("ParseLevel(q) = %v, %v", level, err)
+	}
+	if _, err := ParseLevel("X"); !errors.Is(err, ErrInvalidLevel) {
+		t.Errorf("ParseLevel(X) error = %v, want ErrInvalidLevel", err)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	code, _ := Encode([]byte("https://dev4url.cc/abc1234"), Quartile)
+	total := code.Size() + 2*DefaultMargin
+	opts := Options{Size: 300, Margin: DefaultMargin, Foreground: color.Black, Background: color.White}
+
+	var buf bytes.Buffer
+	if err := PNG(&buf, code, opts); err != nil {
+		t.Fatalf("PNG() unexpected error: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+	if img.Bounds().Dx() != 300 || img.Bounds().Dy() != 300 {
+		t.Errorf("Expected 300x300, got %v", img.Bounds())
+	}
+
+	// Every module is a solid square of its color
+	scale := 300 / total
+	offset := (300-scale*total)/2 + DefaultMargin*scale
+	for y := 0; y < code.Size(); y++ {
+		for x := 0; x < code.Size(); x++ {
+			r, _, _, _ := img.At(offset+x*scale+scale/2, offset+y*scale+scale/2).RGBA()
+			if dark := r == 0; dark != code.Dark(x, y) {
+				t.Fatalf("Module %d,%d drawn dark=%v", x, y, dark)
+			}
+		}
+	}
+
+	if err := PNG(&buf, code, Options{Size: code.Size() - 1}); !errors.Is(err, ErrTooSmall) {
+		t.Errorf("PNG() below one pixel per module error = %v, want ErrTooSmall", err)
+	}
+
+	logo := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for i := range logo.Pix {
+		logo.Pix[i] = 0xFF // white
+	}
+	opts.Logo = logo
+	buf.Reset()
+	if err := PNG(&buf, code, opts); err != nil {
+		t.Fatalf("PNG() with logo unexpected error: %v", err)
+	}
+	img, _ = png.Decode(&buf)
+	if r, _, _, _ := img.At(150, 150).RGBA(); r != 0xFFFF {
+		t.Error("Expected the center covered by the white logo")
+	}
+}
+
+func TestSVG(t *testing.T) {
+	code, _ := Encode([]byte("https://dev4url.cc/abc1234"), Medium)
+	var buf bytes.Buffer
+	err := SVG(&buf, code, Options{
+		Size:       256,
+		Margin:     2,
+		Foreground: color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xFF},
+		Background: color.Transparent,
+	})
+	if err != nil {
+		t.Fatalf("SVG() unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	total := code.Size() + 4
+	for _, want := range []string{
+		fmt.Sprintf(`viewBox="0 0 %d %d"`, total, total),
+		`width="256"`,
+		`fill="#112233"`,
+		"M2 2h7v1h-7z", // top row of the top left finder
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %s in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "<rect") {
+		t.Error("Expected no background rectangle for a transparent background")
+	}
+}
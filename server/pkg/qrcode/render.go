@@ -0,0 +1,229 @@
+package qrcode
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// ErrTooSmall is returned when the image is smaller than one pixel per
+// module
+var ErrTooSmall = errors.New("image too small for the code")
+
+// DefaultMargin is the quiet zone the spec asks for, in modules
+const DefaultMargin = 4
+
+// Options control how a code is drawn
+type Options struct {
+	// Size is the width and height of the image in pixels. PNG modules are
+	// whole pixels, so the code is centered with a slightly wider margin
+	// when Size is not a multiple of the module count.
+	Size int
+	// Margin is the light border around the code, in modules
+	Margin int
+	// Foreground and Background default to black and white
+	Foreground color.Color
+	Background color.Color
+	// Logo is drawn over the center of the code, hiding about 4% of it;
+	// encode at level Quartile or High when using one
+	Logo image.Image
+}
+
+// logoBox returns the first module and width of the centered square the
+// logo covers, or a zero width without a logo
+func (c *Code) logoBox(logo image.Image) (start, width int) {
+	if logo == nil {
+		return 0, 0
+	}
+	width = c.size / 5
+	if (c.size-width)%2 != 0 {
+		width++
+	}
+	return (c.size - width) / 2, width
+}
+
+// inBox reports whether module x, y is covered by the logo
+func inBox(x, y, start, width int) bool {
+	return x >= start && y >= start && x < start+width && y < start+width
+}
+
+func (o *Options) defaults() {
+	if o.Foreground == nil {
+		o.Foreground = color.Black
+	}
+	if o.Background == nil {
+		o.Background = color.White
+	}
+}
+
+// PNG writes the code as a PNG image
+func PNG(w io.Writer, c *Code, opts Options) error {
+	opts.defaults()
+	total := c.size + 2*opts.Margin
+	scale := opts.Size / total
+	if scale < 1 {
+		return fmt.Errorf("%w: %d pixels for %d modules", ErrTooSmall, opts.Size, total)
+	}
+	offset := (opts.Size-scale*total)/2 + opts.Margin*scale
+	bounds := image.Rect(0, 0, opts.Size, opts.Size)
+
+	// Two colors compress far better as a paletted image
+	var img interface {
+		image.Image
+		Set(x, y int, c color.Color)
+	}
+	if opts.Logo == nil {
+		img = image.NewPaletted(bounds, color.Palette{opts.Background, opts.Foreground})
+	} else {
+		rgba := image.NewNRGBA(bounds)
+		fillRect(rgba, bounds, opts.Background)
+		img = rgba
+	}
+
+	start, width := c.logoBox(opts.Logo)
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if !c.Dark(x, y) || inBox(x, y, start, width) {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				for px := 0; px < scale; px++ {
+					img.Set(offset+x*scale+px, offset+y*scale+py, opts.Foreground)
+				}
+			}
+		}
+	}
+
+	if opts.Logo != nil {
+		// Half a module of padding keeps the logo off the nearest modules
+		pad := scale / 2
+		box := image.Rect(offset+start*scale+pad, offset+start*scale+pad,
+			offset+(start+width)*scale-pad, offset+(start+width)*scale-pad)
+		drawScaled(img.(*image.NRGBA), fitRect(box, opts.Logo.Bounds()), opts.Logo)
+	}
+
+	return png.Encode(w, img)
+}
+
+// SVG writes the code as an SVG document. Dark modules are merged into
+// horizontal runs of a single path; the logo is embedded as a PNG data
+// URI.
+func SVG(w io.Writer, c *Code, opts Options) error {
+	opts.defaults()
+	total := c.size + 2*opts.Margin
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, total, total)
+	if fill, ok := svgColor(opts.Background); ok {
+		fmt.Fprintf(bw, `<rect width="%d" height="%d" %s/>`, total, total, fill)
+	}
+
+	start, width := c.logoBox(opts.Logo)
+	fill, _ := svgColor(opts.Foreground)
+	fmt.Fprintf(bw, `<path %s d="`, fill)
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; {
+			if !c.Dark(x, y) || inBox(x, y, start, width) {
+				x++
+				continue
+			}
+			run := 1
+			for c.Dark(x+run, y) && !inBox(x+run, y, start, width) {
+				run++
+			}
+			fmt.Fprintf(bw, "M%d %dh%dv1h-%dz", x+opts.Margin, y+opts.Margin, run, run)
+			x += run
+		}
+	}
+	bw.WriteString(`"/>`)
+
+	if opts.Logo != nil {
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, opts.Logo); err != nil {
+			return fmt.Errorf("encoding logo: %w", err)
+		}
+		fmt.Fprintf(bw, `<image x="%g" y="%g" width="%g" height="%g" href="data:image/png;base64,%s"/>`,
+			float64(start+opts.Margin)+0.5, float64(start+opts.Margin)+0.5, float64(width)-1, float64(width)-1,
+			base64.StdEncoding.EncodeToString(encoded.Bytes()))
+	}
+
+	bw.WriteString(`</svg>`)
+	return bw.Flush()
+}
+
+// svgColor returns the fill attributes for col, or false when it is fully
+// transparent
+func svgColor(col color.Color) (string, bool) {
+	c := color.NRGBAModel.Convert(col).(color.NRGBA)
+	if c.A == 0 {
+		return `fill="none"`, false
+	}
+	fill := fmt.Sprintf(`fill="#%02x%02x%02x"`, c.R, c.G, c.B)
+	if c.A < 0xFF {
+		fill += fmt.Sprintf(` fill-opacity="%.3f"`, float64(c.A)/0xFF)
+	}
+	return fill, true
+}
+
+func fillRect(img *image.NRGBA, r image.Rectangle, col color.Color) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, y, col)
+		}
+	}
+}
+
+// fitRect returns the largest rectangle with src's aspect ratio centered
+// in box
+func fitRect(box, src image.Rectangle) image.Rectangle {
+	bw, bh, sw, sh := box.Dx(), box.Dy(), src.Dx(), src.Dy()
+	if sw == 0 || sh == 0 {
+		return image.Rectangle{}
+	}
+	w, h := bw, sh*bw/sw
+	if h > bh {
+		w, h = sw*bh/sh, bh
+	}
+	x, y := box.Min.X+(bw-w)/2, box.Min.Y+(bh-h)/2
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// drawScaled draws src into dst's rectangle r, averaging the source pixels
+// under each destination pixel and blending over what is already there
+func drawScaled(dst *image.NRGBA, r image.Rectangle, src image.Image) {
+	sb := src.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sy0 := sb.Min.Y + (y-r.Min.Y)*sb.Dy()/r.Dy()
+		sy1 := max(sb.Min.Y+(y-r.Min.Y+1)*sb.Dy()/r.Dy(), sy0+1)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sx0 := sb.Min.X + (x-r.Min.X)*sb.Dx()/r.Dx()
+			sx1 := max(sb.Min.X+(x-r.Min.X+1)*sb.Dx()/r.Dx(), sx0+1)
+
+			var sr, sg, sbl, sa, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					// Premultiplied, so transparent pixels add no color
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					sr, sg, sbl, sa, n = sr+uint64(pr), sg+uint64(pg), sbl+uint64(pb), sa+uint64(pa), n+1
+				}
+			}
+			sr, sg, sbl, sa = sr/n, sg/n, sbl/n, sa/n
+
+			dr, dg, db, da := dst.At(x, y).RGBA()
+			inv := 0xFFFF - sa
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(sr + uint64(dr)*inv/0xFFFF),
+				G: uint16(sg + uint64(dg)*inv/0xFFFF),
+				B: uint16(sbl + uint64(db)*inv/0xFFFF),
+				A: uint16(sa + uint64(da)*inv/0xFFFF),
+			})
+		}
+	}
+}